@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/citation"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var citeFormat string
+
+var citeCmd = &cobra.Command{
+	Use:   "cite <note|url|citekey>",
+	Short: "Print the bibliography entry of an exported bookmark",
+	Long: `Print the citation of a bookmark, as BibTeX (biblatex) or CSL-JSON.
+
+The bookmark is found by the path of one of its notes, its URL, its citekey or its
+Readdeck ID. Citekeys are assigned on export, so only exported bookmarks can be cited.
+
+Examples:
+  readdeck-highlight-exporter cite ~/notes/fleeting/1742760960-schlep-blindness.md
+  readdeck-highlight-exporter cite https://paulgraham.com/schlep.html
+  readdeck-highlight-exporter cite graham2012schlep --format=csl`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		format, err := citation.ParseFormat(citeFormat)
+		if err != nil {
+			fatalf("Invalid format: %v", err)
+		}
+
+		citations, err := citation.Load(state.NewStore(config.StateHome()))
+		if err != nil {
+			fatalf("Could not load the citations: %v", err)
+		}
+
+		query := args[0]
+		if info, err := os.Stat(query); err == nil && !info.IsDir() {
+			query = noteBookmarkID(query)
+		}
+
+		entry, ok := citations.Find(query)
+		if !ok {
+			fatalf("No exported bookmark found for %q, run 'highlight-exporter export' first.", args[0])
+		}
+
+		if err := citation.Write(os.Stdout, format, []citation.Entry{entry}); err != nil {
+			fatalf("Could not write the citation: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(citeCmd)
+	citeCmd.Flags().StringVar(&citeFormat, "format", "bibtex", "Citation format (bibtex, csl)")
+}
+
+// noteBookmarkID reads the Readdeck ID from the metadata of a note, in the format of its extension
+func noteBookmarkID(path string) string {
+	format, err := repository.ParseNoteFormat(viper.GetString("export.format"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+	if filepath.Ext(path) == repository.OrgFormat.Extension() {
+		format = repository.OrgFormat
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fatalf("Could not read %s: %v", path, err)
+	}
+
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	parsed, err := repository.NewFormatNoteService(format, formatter, viper.GetString("readdeck.base_url")).ParseNote(content, path)
+	if err != nil {
+		fatalf("Could not read the metadata of %s: %v", path, err)
+	}
+	if parsed.Metadata.ReaddeckID == "" {
+		fatalf("%s is not a Readdeck note", path)
+	}
+	return parsed.Metadata.ReaddeckID
+}
@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/anki"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/git"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/service"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,6 +23,27 @@ var (
 	bookmarksPerPage int
 	timeout          time.Duration
 	fleetingPath     string
+	sortOrder        string
+	grouping         string
+	noteMode         string
+	noteFormat       string
+	ankiColors       []string
+	ankiDeck         string
+	ankiFront        string
+	reviewLimit      int
+	feedPath         string
+	feedRetention    int
+	feedTitle        string
+	bibtexPath       string
+	cslPath          string
+	webhookAddress   string
+	webhookSecret    string
+	webhookDebounce  time.Duration
+	exportStages     []string
+	hookTimeout      time.Duration
+	gitCommit        bool
+	gitMessage       string
+	gitPush          bool
 )
 
 // configCmd represents the config command
@@ -38,6 +66,45 @@ Examples:
   
   # Update timeout
   readdeck-highlight-exporter config --timeout=45s
+
+  # Order highlights by creation time instead of their position in the article
+  readdeck-highlight-exporter config --sort=created
+
+  # Group highlights by the day they were made instead of by colour
+  readdeck-highlight-exporter config --grouping=date
+
+  # Write every highlight to its own Zettelkasten note
+  readdeck-highlight-exporter config --mode=atomic
+
+  # Write Logseq pages instead of markdown notes with frontmatter
+  readdeck-highlight-exporter config --format=logseq
+
+  # Write org-roam nodes (.org files) for Emacs
+  readdeck-highlight-exporter config --format=org
+
+  # Turn green and red highlights into Anki cloze cards
+  readdeck-highlight-exporter config --anki-colors=green,red --anki-front=cloze
+
+  # Review 20 highlights a day
+  readdeck-highlight-exporter config --review-limit=20
+
+  # Keep an Atom feed of the 100 latest new highlights, served by a web server
+  readdeck-highlight-exporter config --feed-path=/srv/www/highlights.xml --feed-retention=100
+
+  # Keep a bibliography of all exported bookmarks
+  readdeck-highlight-exporter config --bibtex-path=/home/user/writing/readdeck.bib --csl-path=/home/user/writing/readdeck.json
+
+  # Clean up the whitespace of highlights and drop duplicates before writing them
+  readdeck-highlight-exporter config --stages=clean-whitespace,dedupe
+
+  # Receive Readdeck webhooks on all interfaces
+  readdeck-highlight-exporter config --webhook-address=:8421 --webhook-secret=$(openssl rand -hex 32)
+
+  # Give slow hooks more time, the commands themselves are set in settings.yaml
+  readdeck-highlight-exporter config --hook-timeout=2m
+
+  # Commit the exported notes to the git repository of the vault and push them
+  readdeck-highlight-exporter config --git-commit --git-push --git-message='Highlights of {{.Time.Format "2006-01-02"}}'
   
   # Revert to default bookmarks per page
   readdeck-highlight-exporter config --unset=readdeck.bookmarks_per_page
@@ -48,7 +115,28 @@ Examples:
 			!cmd.Flags().Changed("token") &&
 			!cmd.Flags().Changed("bookmarks-per-page") &&
 			!cmd.Flags().Changed("timeout") &&
-			!cmd.Flags().Changed("fleeting-path") {
+			!cmd.Flags().Changed("fleeting-path") &&
+			!cmd.Flags().Changed("sort") &&
+			!cmd.Flags().Changed("grouping") &&
+			!cmd.Flags().Changed("mode") &&
+			!cmd.Flags().Changed("format") &&
+			!cmd.Flags().Changed("stages") &&
+			!cmd.Flags().Changed("anki-colors") &&
+			!cmd.Flags().Changed("anki-deck") &&
+			!cmd.Flags().Changed("anki-front") &&
+			!cmd.Flags().Changed("review-limit") &&
+			!cmd.Flags().Changed("feed-path") &&
+			!cmd.Flags().Changed("feed-retention") &&
+			!cmd.Flags().Changed("feed-title") &&
+			!cmd.Flags().Changed("bibtex-path") &&
+			!cmd.Flags().Changed("csl-path") &&
+			!cmd.Flags().Changed("webhook-address") &&
+			!cmd.Flags().Changed("webhook-secret") &&
+			!cmd.Flags().Changed("webhook-debounce") &&
+			!cmd.Flags().Changed("hook-timeout") &&
+			!cmd.Flags().Changed("git-commit") &&
+			!cmd.Flags().Changed("git-message") &&
+			!cmd.Flags().Changed("git-push") {
 			showConfig()
 			return nil
 		}
@@ -58,6 +146,19 @@ Examples:
 			defaults := config.DefaultSettings()
 			viper.SetDefault("readdeck.bookmarks_per_page", defaults.Readdeck.BookmarksPerPage)
 			viper.SetDefault("readdeck.request_timeout", defaults.Readdeck.RequestTimeout)
+			viper.SetDefault("export.sort", defaults.Export.Sort)
+			viper.SetDefault("export.grouping", defaults.Export.Grouping)
+			viper.SetDefault("export.mode", defaults.Export.Mode)
+			viper.SetDefault("export.format", defaults.Export.Format)
+			viper.SetDefault("anki.colors", defaults.Anki.Colors)
+			viper.SetDefault("anki.deck", defaults.Anki.Deck)
+			viper.SetDefault("anki.front", defaults.Anki.Front)
+			viper.SetDefault("review.daily_limit", defaults.Review.DailyLimit)
+			viper.SetDefault("feed.retention", defaults.Feed.Retention)
+			viper.SetDefault("feed.title", defaults.Feed.Title)
+			viper.SetDefault("webhook.address", defaults.Webhook.Address)
+			viper.SetDefault("webhook.debounce", defaults.Webhook.Debounce)
+			viper.SetDefault("hooks.timeout", defaults.Hooks.Timeout)
 		}
 
 		// Set new values from flags
@@ -79,6 +180,119 @@ Examples:
 		if cmd.Flags().Changed("fleeting-path") {
 			viper.Set("export.fleeting_path", fleetingPath)
 		}
+		if cmd.Flags().Changed("sort") {
+			order, err := readdeck.ParseHighlightOrder(sortOrder)
+			if err != nil {
+				return err
+			}
+			viper.Set("export.sort", string(order))
+		}
+		if cmd.Flags().Changed("grouping") {
+			mode, err := repository.ParseGroupingMode(grouping)
+			if err != nil {
+				return err
+			}
+			viper.Set("export.grouping", string(mode))
+		}
+		if cmd.Flags().Changed("mode") {
+			mode, err := repository.ParseNoteMode(noteMode)
+			if err != nil {
+				return err
+			}
+			viper.Set("export.mode", string(mode))
+		}
+		if cmd.Flags().Changed("format") {
+			format, err := repository.ParseNoteFormat(noteFormat)
+			if err != nil {
+				return err
+			}
+			viper.Set("export.format", string(format))
+		}
+		if cmd.Flags().Changed("stages") {
+			if _, err := service.NewStages(exportStages); err != nil {
+				return err
+			}
+			viper.Set("export.stages", exportStages)
+		}
+		if cmd.Flags().Changed("anki-colors") {
+			if len(ankiColors) == 0 {
+				return fmt.Errorf("anki-colors needs at least one colour")
+			}
+			viper.Set("anki.colors", ankiColors)
+		}
+		if cmd.Flags().Changed("anki-deck") {
+			if ankiDeck == "" {
+				return fmt.Errorf("anki-deck can't be empty")
+			}
+			viper.Set("anki.deck", ankiDeck)
+		}
+		if cmd.Flags().Changed("anki-front") {
+			front, err := anki.ParseFrontStyle(ankiFront)
+			if err != nil {
+				return err
+			}
+			viper.Set("anki.front", string(front))
+		}
+		if cmd.Flags().Changed("review-limit") {
+			if reviewLimit < 1 {
+				return fmt.Errorf("review-limit must be at least 1")
+			}
+			viper.Set("review.daily_limit", reviewLimit)
+		}
+		if cmd.Flags().Changed("feed-path") {
+			viper.Set("feed.path", feedPath)
+		}
+		if cmd.Flags().Changed("feed-retention") {
+			if feedRetention < 1 {
+				return fmt.Errorf("feed-retention must be at least 1")
+			}
+			viper.Set("feed.retention", feedRetention)
+		}
+		if cmd.Flags().Changed("feed-title") {
+			if feedTitle == "" {
+				return fmt.Errorf("feed-title can't be empty")
+			}
+			viper.Set("feed.title", feedTitle)
+		}
+		if cmd.Flags().Changed("bibtex-path") {
+			viper.Set("citation.bibtex_path", bibtexPath)
+		}
+		if cmd.Flags().Changed("csl-path") {
+			viper.Set("citation.csl_path", cslPath)
+		}
+		if cmd.Flags().Changed("webhook-address") {
+			if webhookAddress == "" {
+				return fmt.Errorf("webhook-address can't be empty")
+			}
+			viper.Set("webhook.address", webhookAddress)
+		}
+		if cmd.Flags().Changed("webhook-secret") {
+			viper.Set("webhook.secret", webhookSecret)
+		}
+		if cmd.Flags().Changed("webhook-debounce") {
+			if webhookDebounce < 0 {
+				return fmt.Errorf("webhook-debounce can't be negative")
+			}
+			viper.Set("webhook.debounce", webhookDebounce)
+		}
+		if cmd.Flags().Changed("hook-timeout") {
+			if hookTimeout <= 0 {
+				return fmt.Errorf("hook-timeout must be positive")
+			}
+			viper.Set("hooks.timeout", hookTimeout)
+		}
+		if cmd.Flags().Changed("git-commit") {
+			viper.Set("git.commit", gitCommit)
+		}
+		if cmd.Flags().Changed("git-message") {
+			if _, err := git.ParseMessage(gitMessage); err != nil {
+				return err
+			}
+			viper.Set("git.message", gitMessage)
+		}
+		if cmd.Flags().Changed("git-push") {
+			viper.Set("git.push", gitPush)
+		}
 
 		// Validate required fields for a new configuration
 		if !configExists() {
@@ -94,13 +308,13 @@ Examples:
 		} else {
 			// For existing configs, warn about empty required fields but don't error
 			if cmd.Flags().Changed("base-url") && baseURL == "" {
-				fmt.Println("Warning: Setting empty base-url")
+				slog.Warn("setting an empty base-url")
 			}
 			if cmd.Flags().Changed("token") && token == "" {
-				fmt.Println("Warning: Setting empty token")
+				slog.Warn("setting an empty token")
 			}
 			if cmd.Flags().Changed("fleeting-path") && fleetingPath == "" {
-				fmt.Println("Warning: Setting empty fleeting-path")
+				slog.Warn("setting an empty fleeting-path")
 			}
 		}
 
@@ -114,7 +328,7 @@ Examples:
 			return fmt.Errorf("failed to save configuration: %w", err)
 		}
 
-		fmt.Printf("Configuration saved to %s\n", configFile)
+		slog.Info("configuration saved", "path", configFile)
 		return nil
 	},
 }
@@ -128,6 +342,27 @@ func init() {
 	configCmd.Flags().IntVar(&bookmarksPerPage, "bookmarks-per-page", 100, "Number of bookmarks to fetch per request (min 10)")
 	configCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "HTTP request timeout")
 	configCmd.Flags().StringVar(&fleetingPath, "fleeting-path", "", "Path to fleeting notes directory")
+	configCmd.Flags().StringVar(&sortOrder, "sort", "position", "Order of highlights within a section (position, created, api)")
+	configCmd.Flags().StringVar(&grouping, "grouping", "color", "How highlights are grouped into sections (color, flat, date, section)")
+	configCmd.Flags().StringVar(&noteMode, "mode", "bookmark", "Write a note per bookmark, or an atomic note per highlight (bookmark, atomic)")
+	configCmd.Flags().StringVar(&noteFormat, "format", "markdown", "Flavour of the notes (markdown, logseq, org)")
+	configCmd.Flags().StringSliceVar(&exportStages, "stages", nil, "Transform stages that run before notes are written, in order ("+strings.Join(service.StageNames(), ", ")+")")
+	configCmd.Flags().StringSliceVar(&ankiColors, "anki-colors", []string{"green"}, "Highlight colours that become Anki cards")
+	configCmd.Flags().StringVar(&ankiDeck, "anki-deck", "Readdeck", "Anki deck the cards are imported into")
+	configCmd.Flags().StringVar(&ankiFront, "anki-front", "title", "Front of the Anki cards (title, cloze)")
+	configCmd.Flags().IntVar(&reviewLimit, "review-limit", 10, "Number of highlights to review per day")
+	configCmd.Flags().StringVar(&feedPath, "feed-path", "", "Atom feed of new highlights, updated after each export (empty to disable)")
+	configCmd.Flags().IntVar(&feedRetention, "feed-retention", 50, "Number of entries kept in the feed")
+	configCmd.Flags().StringVar(&feedTitle, "feed-title", "Readdeck highlights", "Title of the feed")
+	configCmd.Flags().StringVar(&bibtexPath, "bibtex-path", "", "BibTeX file of all exported bookmarks, updated after each export (empty to disable)")
+	configCmd.Flags().StringVar(&cslPath, "csl-path", "", "CSL-JSON file of all exported bookmarks, updated after each export (empty to disable)")
+	configCmd.Flags().StringVar(&webhookAddress, "webhook-address", "127.0.0.1:8421", "Address the serve command listens on")
+	configCmd.Flags().StringVar(&webhookSecret, "webhook-secret", "", "Shared secret webhook notifications have to carry")
+	configCmd.Flags().DurationVar(&webhookDebounce, "webhook-debounce", 10*time.Second, "Quiet time after the last notification before exporting")
+	configCmd.Flags().DurationVar(&hookTimeout, "hook-timeout", 30*time.Second, "Time every hook command gets before it is stopped")
+	configCmd.Flags().BoolVar(&gitCommit, "git-commit", false, "Commit the exported notes to the git repository of the fleeting path")
+	configCmd.Flags().StringVar(&gitMessage, "git-message", "", "Template of the commit message, empty for the default message")
+	configCmd.Flags().BoolVar(&gitPush, "git-push", false, "Push the commit of the exported notes")
 }
 
 func configExists() bool {
@@ -153,6 +388,45 @@ func GetConfig() (config.Settings, error) {
 	if settings.Readdeck.RequestTimeout == 0 {
 		settings.Readdeck.RequestTimeout = defaults.Readdeck.RequestTimeout
 	}
+	if settings.Export.Sort == "" {
+		settings.Export.Sort = defaults.Export.Sort
+	}
+	if settings.Export.Grouping == "" {
+		settings.Export.Grouping = defaults.Export.Grouping
+	}
+	if settings.Export.Mode == "" {
+		settings.Export.Mode = defaults.Export.Mode
+	}
+	if settings.Export.Format == "" {
+		settings.Export.Format = defaults.Export.Format
+	}
+	if len(settings.Anki.Colors) == 0 {
+		settings.Anki.Colors = defaults.Anki.Colors
+	}
+	if settings.Anki.Deck == "" {
+		settings.Anki.Deck = defaults.Anki.Deck
+	}
+	if settings.Anki.Front == "" {
+		settings.Anki.Front = defaults.Anki.Front
+	}
+	if settings.Review.DailyLimit == 0 {
+		settings.Review.DailyLimit = defaults.Review.DailyLimit
+	}
+	if settings.Feed.Retention == 0 {
+		settings.Feed.Retention = defaults.Feed.Retention
+	}
+	if settings.Feed.Title == "" {
+		settings.Feed.Title = defaults.Feed.Title
+	}
+	if settings.Webhook.Address == "" {
+		settings.Webhook.Address = defaults.Webhook.Address
+	}
+	if settings.Webhook.Debounce == 0 {
+		settings.Webhook.Debounce = defaults.Webhook.Debounce
+	}
+	if settings.Hooks.Timeout == 0 {
+		settings.Hooks.Timeout = defaults.Hooks.Timeout
+	}
 
 	return settings, nil
 }
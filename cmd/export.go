@@ -2,23 +2,62 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/anki"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/citation"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/display"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/epub"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/export"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/feed"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/git"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/history"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/hooks"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/service"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/site"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	verbose bool
+	verbose      bool
+	exportFormat string
+	exportOutput string
+	exportAll    bool
+	exportSince  string
+	exportUntil  string
+	exportLabels []string
+	// exportBookmarkIDs, exportSites, exportTypes, exportColors and exportLimit narrow the export down
+	exportBookmarkIDs []string
+	exportSites       []string
+	exportTypes       []string
+	exportColors      []string
+	exportLimit       int
+	noProgress        bool
+	summaryFormat     string
 )
 
+// jsonSummary is the --summary-format that prints the summary of the export as JSON
+const jsonSummary = "json"
+
+// errExportFailures is returned by an export that finished, but not for every note or hook
+var errExportFailures = errors.New("some notes or hooks failed, see the summary")
+
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "Export highlights from Readdeck to Zettelkasten notes",
@@ -31,55 +70,373 @@ This command:
 - Preserves all metadata such as URLs, publication dates, and authors
 - Groups highlights by color
 
+With --format json, ndjson or csv the highlights are written as data instead,
+to a file or to stdout, and the notes are left untouched.
+
+With --format html a static, browsable site is generated in the --output directory.
+Regenerating it only rewrites the pages that changed.
+
+With --format anki the highlights of the configured colours become flashcards,
+in a TSV file that Anki can import. Only new cards are written, unless --all is set.
+
+With --format epub the highlights are packaged as an EPUB digest for e-readers,
+with a chapter per bookmark.
+
+When feed.path is configured, the new highlights are also added to an Atom feed.
+
+The commands of hooks.pre_write and hooks.post_write run around every note that is
+written, those of hooks.post_run once the export is done. Their failures are listed
+in the summary.
+
+With git.commit set, the notes written by the export are committed to the git
+repository of the fleeting path, and pushed when git.push is set too.
+
+With --summary-format json the summary is printed as JSON instead, with every note
+that was created, updated, left unchanged, vetoed or that failed. It is not an
+output format: --format and --output choose what is exported and where to, the
+summary only reports on the notes written to the vault. Notes or hooks that
+failed make the command exit with a non-zero status. Every run is recorded, see
+'history' to look it up later.
+
+Only one export runs at a time, see 'sync' to export periodically.
+
+--since, --until and --label limit the highlights of any format to a period or to
+bookmarks with one of the labels. Times are dates (2006-01-02) or durations back
+from now in days, weeks, months or years (30d, 2w, 1m, 1y).
+
+--bookmark, --site, --type and --color narrow the export down further, --limit
+keeps the most recently highlighted bookmarks only. Filters that apply to every
+export are set in settings.yaml, under export.include and export.exclude; the
+flags replace the include rules of the configuration.
+
 Examples:
   readdeck-highlight-exporter export
   readdeck-highlight-exporter export --verbose
-  readdeck-highlight-exporter export --timing`,
+  readdeck-highlight-exporter export --summary-format=json
+  readdeck-highlight-exporter export --format=org
+  readdeck-highlight-exporter export --format=json --output=highlights.json
+  readdeck-highlight-exporter export --format=csv --output=- > highlights.csv
+  readdeck-highlight-exporter export --format=anki --output=cards.txt
+  readdeck-highlight-exporter export --format=html --output=/srv/highlights
+  readdeck-highlight-exporter export --format=epub --since=1m --output=digest.epub
+  readdeck-highlight-exporter export --format=epub --label=books --output=books.epub
+  readdeck-highlight-exporter export --bookmark=Nq4vSrSRGk6WeMPpEUxqaB
+  readdeck-highlight-exporter export --site=paulgraham.com --type=article --limit=5`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Clear standard log prefix for cleaner output
-		log.SetFlags(0)
 
-		// Setting config
-		if viper.GetString("readdeck.base_url") == "" ||
-			viper.GetString("readdeck.token") == "" ||
-			viper.GetString("export.fleeting_path") == "" {
-			log.Fatalf("Missing required configuration. Run 'highlight-exporter config --help' to get started.")
-		}
+		if cmd.Flags().Changed("format") {
+			switch strings.ToLower(strings.TrimSpace(exportFormat)) {
+			case "anki":
+				runAnkiExport(exportOutput, exportAll)
+				return
+			case "html":
+				runSiteExport(exportOutput)
+				return
+			case "epub":
+				runEpubExport(exportOutput)
+				return
+			}
 
-		startTime := time.Now()
+			if format, ok := export.ParseFormat(exportFormat); ok {
+				runDataExport(format, exportOutput)
+				return
+			}
 
-		exporter := getExporter()
-		ctx := context.Background()
+			// Any other format is a note flavour, overriding the configured one for this run
+			noteFormat, err := repository.ParseNoteFormat(exportFormat)
+			if err != nil {
+				fatalf("Invalid format %q, expected one of: markdown, logseq, org, json, ndjson, csv, anki, html, epub", exportFormat)
+			}
+			viper.Set("export.format", string(noteFormat))
+		}
+
+		if cmd.Flags().Changed("output") {
+			fatalf("--output is only used with --format json, ndjson, csv, anki, html or epub")
+		}
+		if cmd.Flags().Changed("all") {
+			fatalf("--all is only used with --format anki")
+		}
+		checkSummaryFormat()
 
-		fmt.Println("Starting export from Readdeck...")
-		results, err := exporter.Export(ctx)
+		// Setting config
+		requireConfig(true)
 
+		// Only the notes of the vault need the lock, data formats write their own output
+		lock, err := lockExport()
 		if err != nil {
-			log.Fatalf("Export failed:\n\n%v", err)
+			fatalf("Could not start the export: %v", err)
 		}
+		onExit(func() { lock.Release() })
+		defer lock.Release()
 
-		display.PrintSummary(results, true, time.Since(startTime))
+		startTime := time.Now()
+		ctx, stop := signalContext()
+		defer stop()
 
-		if verbose {
-			display.PrintDetails(results)
+		slog.Info("starting export", "path", viper.GetString("export.fleeting_path"))
+		runner := newHookRunner()
+		results, err := syncNotes(ctx, "export", runner, newProgressRenderer())
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fatalf("Export failed:\n\n%v", err)
 		}
 
-		fmt.Println("\n✅ Export completed successfully!")
+		failed := printExportSummary(results, runner, time.Since(startTime), err != nil)
+		if err != nil {
+			fatalf("\nExport interrupted, the notes written so far are kept.")
+		}
+		if failed {
+			fatalf("Export finished, but %v", errExportFailures)
+		}
+		slog.Info("export finished", "notes", len(results), "duration", time.Since(startTime))
+		if summaryFormat != jsonSummary {
+			fmt.Println("\n✅ Export completed successfully!")
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "Note flavour (markdown, logseq, org) or data format (json, ndjson, csv, anki, html, epub)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "-", "File to write the data format to, - for stdout. A directory for html")
+	exportCmd.Flags().BoolVar(&exportAll, "all", false, "With --format anki, also write the cards that were exported before")
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "Only highlights created since this date (2006-01-02) or duration (30d, 2w, 1m, 1y)")
+	exportCmd.Flags().StringVar(&exportUntil, "until", "", "Only highlights created before this date (2006-01-02) or duration (30d, 2w, 1m, 1y)")
+	exportCmd.Flags().StringSliceVar(&exportLabels, "label", nil, "Only bookmarks with one of these labels, can be repeated")
+	exportCmd.Flags().StringSliceVar(&exportBookmarkIDs, "bookmark", nil, "Only the bookmark with this Readdeck ID, can be repeated")
+	exportCmd.Flags().StringSliceVar(&exportSites, "site", nil, "Only bookmarks from this domain or its subdomains, can be repeated")
+	exportCmd.Flags().StringSliceVar(&exportTypes, "type", nil, "Only bookmarks of this type (article, video, photo), can be repeated")
+	exportCmd.Flags().StringSliceVar(&exportColors, "color", nil, "Only highlights of this colour, can be repeated")
+	exportCmd.Flags().IntVar(&exportLimit, "limit", 0, "Only the most recently highlighted bookmarks, 0 for all")
+	exportCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Don't show the progress of the export")
+	exportCmd.Flags().StringVar(&summaryFormat, "summary-format", "text", "Format of the report printed after a vault export (text, json), unlike --format it doesn't change what is exported")
+}
+
+// syncNotes exports the highlights to the vault and records the run in the history, the trigger
+// tells what started it. An interrupted export returns the notes that were written with the error.
+func syncNotes(ctx context.Context, trigger string, runner *hooks.Runner, renderer *progress.Renderer, opts ...service.ExporterOption) ([]repository.OperationResult, error) {
+	started := time.Now()
+	recorder := progress.NewRecorder()
+	results, err := exportNotes(ctx, runner, renderer, recorder, opts...)
+
+	run := history.NewRun(trigger, started, time.Now(), recorder.Phases(), results, runner.Failures(), err)
+	if err := recordRun(run); err != nil {
+		slog.Warn("could not record the run in the history", "error", err)
+	}
+	return results, err
+}
+
+// exportNotes exports the highlights to the vault and brings the bibliography, library, search index
+// and feed up to date. The hooks of the runner run around every note, and after the export when it
+// wasn't interrupted. Once started, the commit and the post-run hooks aren't cut short by an interrupt.
+func exportNotes(ctx context.Context, runner *hooks.Runner, renderer *progress.Renderer, recorder *progress.Recorder, opts ...service.ExporterOption) ([]repository.OperationResult, error) {
+	store := state.NewStore(config.StateHome())
+	citations, err := citation.Load(store)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the citations: %w", err)
+	}
+
+	reporter := progress.Multi(renderer, recorder)
+	results, err := getExporter(runner, reporter, append(opts, service.WithCitations(citations))...).Export(ctx)
+	renderer.Finish()
+	if err != nil && len(results) == 0 {
+		return nil, err
+	}
+
+	if err := updateCitations(store, citations); err != nil {
+		slog.Warn("could not update the bibliography", "error", err)
+	}
+
+	if lib, err := recordLibrary(results); err != nil {
+		slog.Warn("could not update the local library", "error", err)
+	} else if _, err := updateSearchIndex(store, lib); err != nil {
+		slog.Warn("could not update the search index", "error", err)
+	}
+
+	if path := viper.GetString("feed.path"); path != "" {
+		if err := updateFeed(path, results); err != nil {
+			slog.Warn("could not update the feed", "path", path, "error", err)
+		}
+	}
+
+	if err == nil && viper.GetBool("git.commit") {
+		if err := commitNotes(context.WithoutCancel(ctx), results); err != nil {
+			slog.Warn("could not commit the notes", "error", err)
+		}
+	}
+
+	if err == nil {
+		runner.AfterRun(context.WithoutCancel(ctx), results)
+	}
+	return results, err
 }
 
-func getExporter() *service.Exporter {
-	client := getClient()
-	repo := getRepository()
-	return service.NewExporter(client, repo)
+// commitNotes commits the notes the export created or updated to the git repository
+// of the fleeting path, and pushes the commit when configured
+func commitNotes(ctx context.Context, results []repository.OperationResult) error {
+	paths := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.Type == "created" || r.Type == "updated" {
+			paths = append(paths, r.Note.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	tmpl, err := git.ParseMessage(viper.GetString("git.message"))
+	if err != nil {
+		return err
+	}
+	message, err := git.NewMessage(tmpl, git.NewMessageData(results, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	repo, err := git.Open(ctx, viper.GetString("export.fleeting_path"))
+	if err != nil {
+		return err
+	}
+
+	commit, err := repo.Commit(ctx, paths, message)
+	if err != nil || commit.Files == 0 {
+		return err
+	}
+	slog.Info("committed the notes", "path", repo.Root(), "files", commit.Files, "commit", commit.Hash)
+
+	if !viper.GetBool("git.push") {
+		return nil
+	}
+	if err := repo.Push(ctx); err != nil {
+		return fmt.Errorf("the commit is kept, but could not be pushed: %w", err)
+	}
+	slog.Info("pushed the notes", "path", repo.Root(), "commit", commit.Hash)
+	return nil
 }
 
-func getClient() readdeck.Client {
+// newProgressRenderer shows the progress on stdout, unless --no-progress is set
+func newProgressRenderer() *progress.Renderer {
+	// The progress would end up in the JSON summary on stdout
+	if noProgress || summaryFormat == jsonSummary {
+		return progress.NewRenderer(io.Discard, false)
+	}
+	return progress.NewStdoutRenderer()
+}
+
+// checkSummaryFormat stops the command on an unknown --summary-format
+func checkSummaryFormat() {
+	if summaryFormat != "text" && summaryFormat != jsonSummary {
+		fatalf("Invalid summary format %q, expected text or json", summaryFormat)
+	}
+}
+
+// printExportSummary prints the summary of an export to the vault in the --summary-format,
+// and tells whether a note or a hook failed
+func printExportSummary(results []repository.OperationResult, runner *hooks.Runner, duration time.Duration, interrupted bool) bool {
+	report := display.NewReport(results, runner.Failures(), duration, interrupted)
+	if summaryFormat == jsonSummary {
+		if err := display.WriteReport(os.Stdout, report); err != nil {
+			fatalf("Could not write the summary: %v", err)
+		}
+		return report.Failed()
+	}
+
+	display.PrintSummary(results, true, duration)
+	display.PrintHookFailures(runner.Failures())
+	if verbose {
+		display.PrintDetails(results)
+	}
+	return report.Failed()
+}
+
+// newHookRunner returns a runner for the hooks of the configuration
+func newHookRunner() *hooks.Runner {
+	return hooks.NewRunner(hooks.Config{
+		PreWrite:  viper.GetStringSlice("hooks.pre_write"),
+		PostWrite: viper.GetStringSlice("hooks.post_write"),
+		PostRun:   viper.GetStringSlice("hooks.post_run"),
+		Timeout:   viper.GetDuration("hooks.timeout"),
+	})
+}
+
+// lockExport makes sure only one export writes to the vault and the state at a time
+func lockExport() (*state.Lock, error) {
+	return state.NewStore(config.StateHome()).Lock("export.lock")
+}
+
+func getExporter(hook repository.WriteHook, reporter progress.Reporter, opts ...service.ExporterOption) *service.Exporter {
+	client := getClient(readdeck.WithProgress(reporter))
+	repo, groupings := getRepository(getGroupingConfig(), repository.WithWriteHook(hook), repository.WithProgress(reporter))
+	opts = append(opts, service.WithProgress(reporter))
+	return service.NewExporter(client, repo, append(exporterOptions(groupings), opts...)...)
+}
+
+func exporterOptions(groupings []repository.GroupingConfig) []service.ExporterOption {
+	opts := append(filterOptions(), stageOptions()...)
+	if order, _ := readdeck.ParseHighlightOrder(viper.GetString("export.sort")); order == readdeck.OrderPosition {
+		opts = append(opts, service.WithSelectors())
+	}
+	for _, grouping := range groupings {
+		if grouping.Uses(repository.GroupBySection) {
+			opts = append(opts, service.WithChapters())
+			break
+		}
+	}
+	return opts
+}
+
+// runDataExport writes the highlights in a data format, the vault is not touched.
+// Progress goes to stderr, so stdout only holds the data.
+func runDataExport(format export.Format, output string) {
+	client, order := newDataClient()
+
+	grouping := getGroupingConfig()
+	exporter := service.NewExporter(client, nil, exporterOptions([]repository.GroupingConfig{grouping})...)
+
+	slog.Info("collecting highlights", "format", format)
+	start := time.Now()
+	notes, err := exporter.Collect(context.Background())
+	if err != nil {
+		fatalf("Export failed:\n\n%v", err)
+	}
+
+	document := export.NewDocument(notes, order, time.Now())
+
+	if err := writeOutput(output, func(w io.Writer) error { return export.Write(w, format, document) }); err != nil {
+		fatalf("Export failed: %v", err)
+	}
+
+	highlights := 0
+	for _, b := range document.Bookmarks {
+		highlights += len(b.Highlights)
+	}
+	slog.Info("exported highlights", "format", format, "highlights", highlights, "bookmarks", len(document.Bookmarks), "duration", time.Since(start))
+}
+
+// requireConfig stops the command when the Readdeck settings are missing or invalid, and
+// returns the order of the highlights. Exports to the vault also need the fleeting path.
+func requireConfig(vault bool) readdeck.HighlightOrder {
+	if viper.GetString("readdeck.base_url") == "" ||
+		viper.GetString("readdeck.token") == "" ||
+		(vault && viper.GetString("export.fleeting_path") == "") {
+		fatalf("Missing required configuration. Run 'highlight-exporter config --help' to get started.")
+	}
+
+	order, err := readdeck.ParseHighlightOrder(viper.GetString("export.sort"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+	return order
+}
+
+// newDataClient checks the configuration of the formats that don't write to the vault,
+// and returns the client to collect their highlights with
+func newDataClient() (readdeck.Client, readdeck.HighlightOrder) {
+	order := requireConfig(false)
+	return getClient(), order
+}
+
+func getClient(opts ...readdeck.HttpClientOption) readdeck.Client {
 	timeout := viper.GetDuration("readdeck.request_timeout")
 	baseURL := viper.GetString("readdeck.base_url")
 	token := viper.GetString("readdeck.token")
@@ -87,18 +444,430 @@ func getClient() readdeck.Client {
 	httpClient := http.Client{
 		Timeout: timeout,
 	}
-	return readdeck.NewHttpClient(httpClient, baseURL, token, 100)
+	return readdeck.NewHttpClient(httpClient, baseURL, token, 100, opts...)
 }
 
-func getRepository() repository.NoteRepository {
-	baseURL := viper.GetString("readdeck.base_url")
+// getRepository returns the repository to write to, along with the groupings
+// used by its destinations. The options apply to every destination.
+func getRepository(grouping repository.GroupingConfig, opts ...repository.FileNoteRepositoryOption) (repository.NoteRepository, []repository.GroupingConfig) {
 	fleetingPath := viper.GetString("export.fleeting_path")
 
-	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig())
-	parser := repository.NewYAMLNoteParser()
-	generator := repository.NewYAMLNoteGenerator(formatter, baseURL)
-	updater := repository.NewYAMLNoteUpdater(generator, parser)
-	noteService := repository.NewCustomNoteService(parser, generator, updater)
-	fmt.Printf("Saving to: %s\n", fleetingPath)
-	return repository.NewFileNoteRepository(fleetingPath, noteService, verbose)
+	mode, err := repository.ParseNoteMode(viper.GetString("export.mode"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
+	var routeSettings []config.RouteSettings
+	if err := viper.UnmarshalKey("export.routes", &routeSettings); err != nil {
+		fatalf("Invalid configuration for export.routes: %v", err)
+	}
+
+	groupings := []repository.GroupingConfig{grouping}
+	destinations := make(map[string]*repository.FileNoteRepository)
+	destinationModes := make(map[string]repository.NoteMode)
+	routes := make([]repository.Route, 0, len(routeSettings))
+	routePaths := make([]string, 0, len(routeSettings))
+
+	for i, rs := range routeSettings {
+		if rs.Path == "" {
+			fatalf("Invalid configuration: export.routes[%d].path is required", i)
+		}
+		if rs.Color == "" && rs.Label == "" && rs.Type == "" {
+			fatalf("Invalid configuration: export.routes[%d] needs a color, label or type to match on", i)
+		}
+
+		routeMode := mode
+		if rs.Mode != "" {
+			if routeMode, err = repository.ParseNoteMode(rs.Mode); err != nil {
+				fatalf("Invalid configuration for export.routes[%d]: %v", i, err)
+			}
+		}
+
+		routeGrouping := grouping
+		if rs.Grouping != "" {
+			groupingMode, err := repository.ParseGroupingMode(rs.Grouping)
+			if err != nil {
+				fatalf("Invalid configuration for export.routes[%d]: %v", i, err)
+			}
+			routeGrouping = repository.GroupingConfig{Default: groupingMode}
+		}
+
+		// Routes to the same folder share a repository, so they share its lookup
+		path := filepath.Clean(rs.Path)
+		destination, ok := destinations[path]
+		if !ok {
+			destination = newFileRepository(path, routeMode, routeGrouping, opts...)
+			destinations[path] = destination
+			destinationModes[path] = routeMode
+			groupings = append(groupings, routeGrouping)
+			routePaths = append(routePaths, path)
+		} else if destinationModes[path] != routeMode {
+			fatalf("Invalid configuration: routes to %s use different modes", path)
+		}
+
+		routes = append(routes, repository.Route{
+			Color:      rs.Color,
+			Label:      rs.Label,
+			Type:       rs.Type,
+			Repository: destination,
+		})
+	}
+
+	slog.Info("saving notes", "path", fleetingPath)
+	for _, path := range routePaths {
+		slog.Info("routing notes", "path", path)
+	}
+
+	fallback := newFileRepository(fleetingPath, mode, grouping, append(opts, repository.WithExcludedPaths(routePaths...))...)
+	if len(routes) == 0 {
+		return fallback, groupings
+	}
+
+	return repository.NewRoutingNoteRepository(fallback, routes), groupings
+}
+
+func newFileRepository(path string, mode repository.NoteMode, grouping repository.GroupingConfig, opts ...repository.FileNoteRepositoryOption) *repository.FileNoteRepository {
+	baseURL := viper.GetString("readdeck.base_url")
+
+	order, err := readdeck.ParseHighlightOrder(viper.GetString("export.sort"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
+	format, err := repository.ParseNoteFormat(viper.GetString("export.format"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
+	if mode == repository.AtomicNotes && format != repository.MarkdownFormat {
+		fatalf("Invalid configuration: atomic mode is only available for the markdown format")
+	}
+
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), order)
+	formatter.Grouping = grouping
+	formatter.LinkHighlights = mode == repository.AtomicNotes
+	noteService := repository.NewFormatNoteService(format, formatter, baseURL)
+
+	if mode == repository.AtomicNotes {
+		generator := repository.NewYAMLNoteGenerator(formatter, baseURL)
+		opts = append(opts, repository.WithHighlightNotes(repository.NewHighlightNoteGenerator(generator)))
+	}
+
+	opts = append(opts, repository.WithExtension(format.Extension()))
+	return repository.NewFileNoteRepository(path, noteService, verbose, opts...)
+}
+
+func getGroupingConfig() repository.GroupingConfig {
+	grouping := repository.DefaultGroupingConfig()
+
+	mode, err := repository.ParseGroupingMode(viper.GetString("export.grouping"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+	grouping.Default = mode
+
+	for bookmarkType, value := range viper.GetStringMapString("export.grouping_by_type") {
+		mode, err := repository.ParseGroupingMode(value)
+		if err != nil {
+			fatalf("Invalid configuration for %s: %v", bookmarkType, err)
+		}
+		grouping.ByType[bookmarkType] = mode
+	}
+
+	return grouping
+}
+
+// runSiteExport renders the highlights as a static site in the output directory
+func runSiteExport(output string) {
+	if output == "" || output == "-" {
+		fatalf("--format html needs an --output directory")
+	}
+	client, order := newDataClient()
+
+	grouping := getGroupingConfig()
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), order)
+	formatter.Grouping = grouping
+
+	generator, err := site.NewGenerator(formatter, "Readdeck highlights")
+	if err != nil {
+		fatalf("Could not load the site templates: %v", err)
+	}
+
+	exporter := service.NewExporter(client, nil, exporterOptions([]repository.GroupingConfig{grouping})...)
+
+	slog.Info("collecting highlights", "format", "html")
+	start := time.Now()
+	notes, err := exporter.Collect(context.Background())
+	if err != nil {
+		fatalf("Export failed:\n\n%v", err)
+	}
+
+	pages, err := generator.Build(notes)
+	if err != nil {
+		fatalf("Export failed: %v", err)
+	}
+
+	result, err := site.Write(output, pages)
+	if err != nil {
+		fatalf("Export failed: %v", err)
+	}
+
+	slog.Info("generated the site", "path", output, "written", result.Written, "unchanged", result.Unchanged, "removed", result.Removed, "duration", time.Since(start))
+}
+
+// recordRun adds the run to the history of exports, used by the history command
+func recordRun(run history.Run) error {
+	store := state.NewStore(config.StateHome())
+	h, err := history.Load(store)
+	if err != nil {
+		return err
+	}
+
+	h.Add(run)
+	return h.Save(store)
+}
+
+// recordLibrary keeps a local copy of the exported highlights, used by the review and search commands
+func recordLibrary(results []repository.OperationResult) (library.Library, error) {
+	store := state.NewStore(config.StateHome())
+	lib, err := library.Load(store)
+	if err != nil {
+		return library.Library{}, err
+	}
+
+	notes := make([]model.Note, 0, len(results))
+	for _, r := range results {
+		if r.Type != "failed" && r.Type != "vetoed" {
+			notes = append(notes, r.Note)
+		}
+	}
+
+	lib.Record(notes, time.Now())
+	return lib, lib.Save(store)
+}
+
+// updateCitations saves the citekeys and rewrites the configured bibliography files
+func updateCitations(store *state.Store, citations *citation.Registry) error {
+	if err := citations.Save(store); err != nil {
+		return err
+	}
+
+	entries := citations.Sorted()
+	for _, target := range []struct {
+		path   string
+		format citation.Format
+	}{
+		{viper.GetString("citation.bibtex_path"), citation.BibTeXFormat},
+		{viper.GetString("citation.csl_path"), citation.CSLFormat},
+	} {
+		if target.path == "" {
+			continue
+		}
+		if err := citation.WriteFile(target.path, target.format, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateFeed adds the new highlights of this run to the Atom feed
+func updateFeed(path string, results []repository.OperationResult) error {
+	order, err := readdeck.ParseHighlightOrder(viper.GetString("export.sort"))
+	if err != nil {
+		return err
+	}
+
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), order)
+	formatter.Grouping = getGroupingConfig()
+
+	now := time.Now()
+	entries, err := feed.NewEntries(results, formatter, now)
+	if err != nil {
+		return err
+	}
+
+	return feed.Update(path, entries, feed.Options{
+		Title:     viper.GetString("feed.title"),
+		Link:      viper.GetString("readdeck.base_url"),
+		Retention: viper.GetInt("feed.retention"),
+	}, now)
+}
+
+// runAnkiExport writes the highlights of the configured colours as Anki cards.
+// The exported highlights are remembered in the state directory, once the file is written.
+func runAnkiExport(output string, all bool) {
+	client, order := newDataClient()
+
+	front, err := anki.ParseFrontStyle(viper.GetString("anki.front"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
+	store := state.NewStore(config.StateHome())
+	ankiState, err := anki.LoadState(store)
+	if err != nil {
+		fatalf("Could not load the Anki state: %v", err)
+	}
+
+	slog.Info("collecting highlights", "format", "anki")
+	notes, err := service.NewExporter(client, nil, append(filterOptions(), stageOptions()...)...).Collect(context.Background())
+	if err != nil {
+		fatalf("Export failed:\n\n%v", err)
+	}
+
+	cards := anki.NewCards(notes, anki.Config{
+		Colors: viper.GetStringSlice("anki.colors"),
+		Deck:   viper.GetString("anki.deck"),
+		Front:  front,
+		Order:  order,
+	})
+	if !all {
+		cards = ankiState.New(cards)
+	}
+
+	if err := writeOutput(output, func(w io.Writer) error { return anki.WriteTSV(w, cards) }); err != nil {
+		fatalf("Export failed: %v", err)
+	}
+
+	ankiState.MarkExported(cards, time.Now())
+	if err := ankiState.Save(store); err != nil {
+		fatalf("Could not save the Anki state: %v", err)
+	}
+
+	slog.Info("exported cards", "path", output, "cards", len(cards))
+}
+
+// filterOptions limits the exporter to the filters and the bookmarks of the flags
+func filterOptions() []service.ExporterOption {
+	opts := []service.ExporterOption{service.WithFilter(getFilter())}
+	if len(exportBookmarkIDs) > 0 {
+		opts = append(opts, service.WithBookmarks(exportBookmarkIDs...))
+	}
+	return opts
+}
+
+// stageOptions adds the transform stages of the configuration, their reports are printed with --verbose
+func stageOptions() []service.ExporterOption {
+	stages, err := service.NewStages(viper.GetStringSlice("export.stages"))
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
+	opts := []service.ExporterOption{service.WithStages(stages...)}
+	// With the JSON summary stdout is kept for the summary
+	if verbose && summaryFormat != jsonSummary {
+		opts = append(opts, service.WithStageReport(display.PrintStageReport))
+	}
+	return opts
+}
+
+// getFilter combines the include and exclude rules of the configuration with the filter flags.
+// A flag replaces the include rule of the configuration.
+func getFilter() service.Filter {
+	now := time.Now()
+	filter := service.Filter{
+		Labels: flagOrConfig(exportLabels, "export.include.labels"),
+		Sites:  flagOrConfig(exportSites, "export.include.sites"),
+		Types:  flagOrConfig(exportTypes, "export.include.types"),
+		Colors: flagOrConfig(exportColors, "export.include.colors"),
+		Exclude: service.Rules{
+			Labels: viper.GetStringSlice("export.exclude.labels"),
+			Sites:  viper.GetStringSlice("export.exclude.sites"),
+			Types:  viper.GetStringSlice("export.exclude.types"),
+			Colors: viper.GetStringSlice("export.exclude.colors"),
+		},
+		Limit: exportLimit,
+	}
+
+	if exportLimit < 0 {
+		fatalf("--limit can't be negative")
+	}
+
+	if exportSince != "" {
+		since, err := service.ParseTime(exportSince, now)
+		if err != nil {
+			fatalf("Invalid --since: %v", err)
+		}
+		filter.Since = &since
+	}
+	if exportUntil != "" {
+		until, err := service.ParseTime(exportUntil, now)
+		if err != nil {
+			fatalf("Invalid --until: %v", err)
+		}
+		filter.Until = &until
+	}
+	if filter.Since != nil && filter.Until != nil && !filter.Since.Before(*filter.Until) {
+		fatalf("--since has to be before --until")
+	}
+
+	return filter
+}
+
+func flagOrConfig(values []string, key string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return viper.GetStringSlice(key)
+}
+
+// runEpubExport packages the highlights as an EPUB digest, a chapter per bookmark
+func runEpubExport(output string) {
+	client, order := newDataClient()
+
+	grouping := getGroupingConfig()
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), order)
+	formatter.Grouping = grouping
+
+	exporter := service.NewExporter(client, nil, exporterOptions([]repository.GroupingConfig{grouping})...)
+
+	slog.Info("collecting highlights", "format", "epub")
+	notes, err := exporter.Collect(context.Background())
+	if err != nil {
+		fatalf("Export failed:\n\n%v", err)
+	}
+	if len(notes) == 0 {
+		fatalf("No highlights to export, the digest would be empty")
+	}
+
+	book := epub.NewBook(notes, formatter, digestTitle(getFilter()), time.Now())
+
+	if err := writeOutput(output, func(w io.Writer) error { return epub.Write(w, book) }); err != nil {
+		fatalf("Export failed: %v", err)
+	}
+
+	slog.Info("exported the digest", "path", output, "bookmarks", len(book.Chapters))
+}
+
+func digestTitle(filter service.Filter) string {
+	title := "Readdeck highlights"
+	if filter.Since != nil {
+		title += " since " + filter.Since.Format("2006-01-02")
+	}
+	if filter.Until != nil {
+		title += " until " + filter.Until.Format("2006-01-02")
+	}
+	if len(filter.Labels) > 0 {
+		title += " (" + strings.Join(filter.Labels, ", ") + ")"
+	}
+	return title
+}
+
+// writeOutput writes to the given file, or to stdout for "-"
+func writeOutput(output string, write func(io.Writer) error) error {
+	if output == "-" || output == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", output, err)
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
 }
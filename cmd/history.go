@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/display"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/history"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyLimit int
+	historyNote  string
+	historyJSON  bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the past exports to your notes",
+	Long: `List the past exports to your notes, most recent first: when they ran, what
+started them (export, sync or webhook), how long they took and what they did.
+
+Every export, sync and webhook export is recorded in the state directory, the
+last 100 runs are kept. --note only lists the runs that wrote a note whose path
+contains the value, to find out when a note changed. 'history show' reprints the
+summary of a run, with the notes it wrote and any errors.
+
+Examples:
+  readdeck-highlight-exporter history
+  readdeck-highlight-exporter history --limit=5
+  readdeck-highlight-exporter history --note=1742757360-schlep-blindness.md
+  readdeck-highlight-exporter history show 42`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		runs := loadHistory().Latest(historyNote, historyLimit)
+		if historyJSON {
+			writeHistoryJSON(runs)
+			return
+		}
+
+		if len(runs) == 0 {
+			fatalf("No runs yet, run 'highlight-exporter export' first.")
+		}
+		display.PrintRuns(runs)
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <run>",
+	Short: "Show the summary of a past export",
+	Long: `Show the summary of a past export: its phases, counts and errors, and the
+notes it created, updated or failed to write. The run is the number listed by
+'history'.
+
+Examples:
+  readdeck-highlight-exporter history show 42
+  readdeck-highlight-exporter history show 42 --json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			fatalf("Invalid run %q, expected the number of a run", args[0])
+		}
+
+		run, ok := loadHistory().Find(id)
+		if !ok {
+			fatalf("No run #%d in the history, see 'highlight-exporter history'", id)
+		}
+
+		if historyJSON {
+			writeHistoryJSON(run)
+			return
+		}
+		display.PrintRun(run)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Maximum number of runs, 0 for all")
+	historyCmd.Flags().StringVar(&historyNote, "note", "", "Only the runs that wrote a note with this in its path")
+	historyCmd.PersistentFlags().BoolVar(&historyJSON, "json", false, "Print the runs as JSON")
+}
+
+func loadHistory() history.History {
+	h, err := history.Load(state.NewStore(config.StateHome()))
+	if err != nil {
+		fatalf("Could not load the history: %v", err)
+	}
+	return h
+}
+
+func writeHistoryJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fatalf("Could not write the history: %v", err)
+	}
+}
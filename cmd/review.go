@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/display"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/review"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var reviewLimitOverride int
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review your highlights with spaced repetition",
+	Long: `Review a few of your exported highlights every day.
+
+Highlights are scheduled with SM-2: the better you remember one, the longer it
+takes before it comes back. Highlights that are due come first, the rest of the
+daily limit is filled with highlights you haven't reviewed yet.
+
+This command works offline, on the highlights of previous exports.
+
+Grade every highlight with:
+  a / again   I forgot about this one
+  h / hard    I barely remembered it
+  g / good    I remembered it
+  e / easy    I knew it by heart
+
+Use s to skip a highlight, q to stop. Progress is saved after every grade.
+
+Examples:
+  readdeck-highlight-exporter review
+  readdeck-highlight-exporter review --limit=5`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		limit := viper.GetInt("review.daily_limit")
+		if cmd.Flags().Changed("limit") {
+			limit = reviewLimitOverride
+		}
+
+		store := state.NewStore(config.StateHome())
+		lib, err := library.Load(store)
+		if err != nil {
+			fatalf("Could not load the local library: %v", err)
+		}
+		if len(lib.Highlights) == 0 {
+			fatalf("No highlights to review yet, run 'highlight-exporter export' first.")
+		}
+
+		reviewState, err := review.LoadState(store)
+		if err != nil {
+			fatalf("Could not load the review state: %v", err)
+		}
+
+		selected := reviewState.Select(lib.Entries(), limit, time.Now())
+		if len(selected) == 0 {
+			fmt.Println("✅ Nothing left to review today!")
+			return
+		}
+
+		grades := make(map[review.Grade]int)
+		reviewed := 0
+		input := bufio.NewScanner(os.Stdin)
+
+	highlights:
+		for i, entry := range selected {
+			display.PrintReviewHighlight(entry, i+1, len(selected))
+
+			for {
+				fmt.Print("\n[a]gain [h]ard [g]ood [e]asy, [s]kip or [q]uit: ")
+				if !input.Scan() {
+					break highlights
+				}
+
+				answer := strings.ToLower(strings.TrimSpace(input.Text()))
+				if answer == "q" || answer == "quit" {
+					break highlights
+				}
+				if answer == "s" || answer == "skip" {
+					break
+				}
+
+				grade, err := review.ParseGrade(answer)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+
+				card := reviewState.Grade(entry.HighlightID, grade, time.Now())
+				if err := reviewState.Save(store); err != nil {
+					fatalf("Could not save the review state: %v", err)
+				}
+
+				grades[grade]++
+				reviewed++
+				fmt.Printf("Next review in %d day(s)\n", card.Interval)
+				break
+			}
+		}
+
+		display.PrintReviewSummary(grades, reviewed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	reviewCmd.Flags().IntVarP(&reviewLimitOverride, "limit", "n", 10, "Number of highlights to review today, overrides review.daily_limit")
+}
@@ -3,10 +3,12 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,6 +16,9 @@ import (
 var (
 	cfgFile     string
 	showVersion bool
+	logLevel    string
+	logFormat   string
+	logFile     string
 
 	programName = "highlight-exporter"
 	version     = "dev"
@@ -51,10 +56,13 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initLogging)
 
 	// Config file flag
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/readdeck-exporter/settings.yaml)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format (text, json)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Also write the logs to this file, relative to the state directory")
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Print version information and exit")
 }
 
@@ -64,6 +72,21 @@ func initConfig() {
 	defaults := config.DefaultSettings()
 	viper.SetDefault("readdeck.bookmarks_per_page", defaults.Readdeck.BookmarksPerPage)
 	viper.SetDefault("readdeck.request_timeout", defaults.Readdeck.RequestTimeout)
+	viper.SetDefault("export.sort", defaults.Export.Sort)
+	viper.SetDefault("export.grouping", defaults.Export.Grouping)
+	viper.SetDefault("export.mode", defaults.Export.Mode)
+	viper.SetDefault("export.format", defaults.Export.Format)
+	viper.SetDefault("anki.colors", defaults.Anki.Colors)
+	viper.SetDefault("anki.deck", defaults.Anki.Deck)
+	viper.SetDefault("anki.front", defaults.Anki.Front)
+	viper.SetDefault("review.daily_limit", defaults.Review.DailyLimit)
+	viper.SetDefault("feed.retention", defaults.Feed.Retention)
+	viper.SetDefault("feed.title", defaults.Feed.Title)
+	viper.SetDefault("webhook.address", defaults.Webhook.Address)
+	viper.SetDefault("webhook.debounce", defaults.Webhook.Debounce)
+	viper.SetDefault("hooks.timeout", defaults.Hooks.Timeout)
+	viper.SetDefault("log.level", defaults.Log.Level)
+	viper.SetDefault("log.format", defaults.Log.Format)
 
 	if cfgFile != "" {
 		// Use config file from the flag.
@@ -87,11 +110,63 @@ func initConfig() {
 	if err != nil {
 		var notFound viper.ConfigFileNotFoundError
 		if !errors.As(err, &notFound) {
-			log.Fatalf("Could not read the configuration: %v", err)
+			fatalf("Could not read the configuration: %v", err)
 		}
 	}
 }
 
+// initLogging sets up the default logger from the log flags, or the log settings when a flag isn't set.
+// The standard logger writes through it as well.
+func initLogging() {
+	level, err := logging.ParseLevel(logSetting("log-level", logLevel, "log.level"))
+	if err != nil {
+		fatalf("Invalid log level: %v", err)
+	}
+	format, err := logging.ParseFormat(logSetting("log-format", logFormat, "log.format"))
+	if err != nil {
+		fatalf("Invalid log format: %v", err)
+	}
+
+	var w io.Writer = os.Stderr
+	if path := logSetting("log-file", logFile, "log.file"); path != "" {
+		file, err := logging.OpenFile(path, config.StateHome())
+		if err != nil {
+			fatalf("Invalid log file: %v", err)
+		}
+		w = io.MultiWriter(os.Stderr, file)
+	}
+
+	slog.SetDefault(logging.New(w, logging.Options{
+		Level:   level,
+		Format:  format,
+		Secrets: []string{viper.GetString("readdeck.token"), viper.GetString("webhook.secret")},
+	}))
+}
+
+func logSetting(flag string, value string, key string) string {
+	if rootCmd.PersistentFlags().Changed(flag) {
+		return value
+	}
+	return viper.GetString(key)
+}
+
+// exitHooks clean up before fatalf exits, os.Exit skips deferred calls
+var exitHooks []func()
+
+// onExit registers a cleanup that also runs when the command ends with fatalf
+func onExit(hook func()) {
+	exitHooks = append(exitHooks, hook)
+}
+
+// fatalf logs the error that ends the command, runs the exit hooks and exits
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	os.Exit(1)
+}
+
 func printVersion() {
 	fmt.Printf("%s version %s\n", programName, version)
 	fmt.Printf("Built: %s\n", buildTime)
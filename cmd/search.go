@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/display"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/search"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/service"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchColors []string
+	searchSite   string
+	searchAuthor string
+	searchLabel  string
+	searchSince  string
+	searchUntil  string
+	searchLimit  int
+	searchJSON   bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query...>",
+	Short: "Search the text of your exported highlights",
+	Long: `Search your exported highlights, offline.
+
+Every word of the query has to be in a highlight, put words between double quotes
+to find them as a phrase. Case and punctuation are ignored. The best matches come
+first, the search can be narrowed down with the filters below.
+
+The search index is kept in the state directory and is updated after every export.
+
+Examples:
+  readdeck-highlight-exporter search stripe
+  readdeck-highlight-exporter search '"schlep blindness"' --author=graham
+  readdeck-highlight-exporter search --label=startups --color=yellow --since=30d
+  readdeck-highlight-exporter search payments --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		query := search.ParseQuery(strings.Join(args, " "))
+		filter := getSearchFilter()
+		if query.IsEmpty() && filter.IsEmpty() {
+			fatalf("Nothing to search for, give a query or a filter.")
+		}
+
+		store := state.NewStore(config.StateHome())
+		lib, err := library.Load(store)
+		if err != nil {
+			fatalf("Could not load the local library: %v", err)
+		}
+		if len(lib.Highlights) == 0 {
+			fatalf("No highlights to search yet, run 'highlight-exporter export' first.")
+		}
+
+		// The index is normally updated on export, this catches up on libraries from before the index
+		index, err := updateSearchIndex(store, lib)
+		if err != nil {
+			fatalf("Could not update the search index: %v", err)
+		}
+
+		results := index.Search(query, filter, lib.Highlights)
+		if searchLimit > 0 && len(results) > searchLimit {
+			results = results[:searchLimit]
+		}
+
+		if searchJSON {
+			writeSearchJSON(results)
+			return
+		}
+		display.PrintSearchResults(results)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringSliceVar(&searchColors, "color", nil, "Only highlights of this colour, can be repeated")
+	searchCmd.Flags().StringVar(&searchSite, "site", "", "Only highlights from a site whose domain contains this")
+	searchCmd.Flags().StringVar(&searchAuthor, "author", "", "Only highlights from an author whose name contains this")
+	searchCmd.Flags().StringVar(&searchLabel, "label", "", "Only highlights from bookmarks with this label")
+	searchCmd.Flags().StringVar(&searchSince, "since", "", "Only highlights created since this date (2006-01-02) or duration (30d, 2w, 1m, 1y)")
+	searchCmd.Flags().StringVar(&searchUntil, "until", "", "Only highlights created before this date (2006-01-02) or duration (30d, 2w, 1m, 1y)")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "Maximum number of results, 0 for all")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "Print the results as JSON")
+}
+
+func getSearchFilter() search.Filter {
+	now := time.Now()
+	filter := search.Filter{Colors: searchColors, Site: searchSite, Author: searchAuthor, Label: searchLabel}
+
+	if searchSince != "" {
+		since, err := service.ParseTime(searchSince, now)
+		if err != nil {
+			fatalf("Invalid --since: %v", err)
+		}
+		filter.Since = &since
+	}
+	if searchUntil != "" {
+		until, err := service.ParseTime(searchUntil, now)
+		if err != nil {
+			fatalf("Invalid --until: %v", err)
+		}
+		filter.Until = &until
+	}
+
+	return filter
+}
+
+// updateSearchIndex brings the search index in line with the library, it's only saved when something changed
+func updateSearchIndex(store *state.Store, lib library.Library) (*search.Index, error) {
+	index, err := search.Load(store)
+	if err != nil {
+		return nil, err
+	}
+
+	result := index.Sync(lib.Entries(), time.Now())
+	if result.Indexed == 0 && result.Removed == 0 {
+		return index, nil
+	}
+	return index, index.Save(store)
+}
+
+func writeSearchJSON(results []search.Result) {
+	type jsonResult struct {
+		library.Entry
+		Score int `json:"score"`
+	}
+
+	output := make([]jsonResult, 0, len(results))
+	for _, r := range results {
+		output = append(output, jsonResult{Entry: r.Entry, Score: r.Score})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		fatalf("Could not write the results: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/service"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/webhook"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// webhookMaxWait caps the debounce when notifications keep coming in
+	webhookMaxWait   = time.Minute
+	lockRetryDelay   = 2 * time.Second
+	shutdownDeadline = 10 * time.Second
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Export highlights as soon as Readdeck sends a webhook",
+	Long: `Run a small HTTP server that receives webhook notifications and exports the
+highlights of the bookmarks they are about, within seconds.
+
+POST /webhook takes a JSON payload with a bookmark_id or a list of bookmark_ids:
+  {"event": "annotation.created", "bookmark_id": "Nq4vSrSRGk6WeMPpEUxqaB"}
+
+The webhook.secret has to be sent along in the X-Webhook-Secret header, or as a
+bearer token. Notifications are debounced (webhook.debounce): a burst of them
+results in a single export of all the bookmarks involved.
+
+GET /health reports the number of exports, the last error and the bookmarks that
+are waiting to be exported.
+
+Exports take the same lock as export and sync, they wait for a running export to
+finish. SIGINT or SIGTERM stops the server once the note that is being written is
+finished.
+
+Examples:
+  readdeck-highlight-exporter config --webhook-secret=$(openssl rand -hex 32)
+  readdeck-highlight-exporter serve`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		requireConfig(true)
+		if viper.GetString("webhook.secret") == "" {
+			fatalf("Missing webhook secret. Set one with 'highlight-exporter config --webhook-secret=...'.")
+		}
+
+		ctx, stop := signalContext()
+		defer stop()
+
+		server := webhook.NewServer(ctx, webhook.Options{
+			Secret:   viper.GetString("webhook.secret"),
+			Debounce: viper.GetDuration("webhook.debounce"),
+			MaxWait:  webhookMaxWait,
+		}, exportBookmarks)
+
+		address := viper.GetString("webhook.address")
+		httpServer := &http.Server{
+			Addr:              address,
+			Handler:           server.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- httpServer.ListenAndServe()
+		}()
+		slog.Info("listening for webhooks, press Ctrl+C to stop", "address", address)
+
+		select {
+		case err := <-errs:
+			fatalf("Could not serve: %v", err)
+		case <-ctx.Done():
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDeadline)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("could not stop the server cleanly", "error", err)
+		}
+
+		if dropped := server.Close(); dropped > 0 {
+			slog.Warn("bookmarks were not exported, the next export picks them up", "bookmarks", dropped)
+		}
+		slog.Info("server stopped")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
+
+// exportBookmarks runs a targeted export of the bookmarks, once the export lock is free
+func exportBookmarks(ctx context.Context, bookmarkIDs []string) error {
+	lock, err := waitForExportLock(ctx)
+	if err != nil {
+		slog.Error("export failed", "bookmark_ids", bookmarkIDs, "error", err)
+		return err
+	}
+	defer lock.Release()
+
+	startTime := time.Now()
+	slog.Info("exporting bookmarks", "bookmark_ids", bookmarkIDs)
+
+	runner := newHookRunner()
+	results, err := syncNotes(ctx, "webhook", runner, newProgressRenderer(), service.WithBookmarks(bookmarkIDs...))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("export failed", "bookmark_ids", bookmarkIDs, "error", err)
+		return err
+	}
+
+	// The failure ends up as the last_error of /health
+	if printExportSummary(results, runner, time.Since(startTime), err != nil) {
+		slog.Warn("export finished with failures", "bookmark_ids", bookmarkIDs, "error", errExportFailures)
+		return errExportFailures
+	}
+	return nil
+}
+
+func waitForExportLock(ctx context.Context) (*state.Lock, error) {
+	for {
+		lock, err := lockExport()
+		if !errors.Is(err, state.ErrLocked) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/display"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsTop   int
+	statsWeeks int
+	statsJSON  bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics about your highlighting habits",
+	Long: `Summarise all exported highlights: highlights per month and week, colours,
+top sites, authors and labels, and your longest streaks of highlighting days.
+
+This command works offline, on the highlights of previous exports.
+
+Examples:
+  readdeck-highlight-exporter stats
+  readdeck-highlight-exporter stats --top=10 --weeks=26
+  readdeck-highlight-exporter stats --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		lib, err := library.Load(state.NewStore(config.StateHome()))
+		if err != nil {
+			fatalf("Could not load the local library: %v", err)
+		}
+
+		opts := stats.DefaultOptions()
+		opts.Top = statsTop
+		opts.Weeks = statsWeeks
+		result := stats.Compute(lib.Entries(), repository.DefaultColorConfig(), opts, time.Now())
+
+		if statsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(result); err != nil {
+				fatalf("Could not write the stats: %v", err)
+			}
+			return
+		}
+
+		if len(lib.Highlights) == 0 {
+			fatalf("No highlights yet, run 'highlight-exporter export' first.")
+		}
+		display.PrintStats(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().IntVar(&statsTop, "top", 5, "Number of sites, authors and labels to show")
+	statsCmd.Flags().IntVar(&statsWeeks, "weeks", 12, "Number of recent weeks to show")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Print the stats as JSON")
+}
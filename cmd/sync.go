@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncWatch    bool
+	syncInterval time.Duration
+)
+
+const minSyncInterval = time.Minute
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Export highlights to your notes, once or periodically",
+	Long: `Export your Readdeck highlights to your notes, like export does.
+
+With --watch it keeps running and exports again every --interval, a replacement
+for a cron job:
+- runs are spread with ±10% jitter
+- a failed run is retried after a minute, then after twice as long every time
+  it fails again, up to 30 minutes or the interval
+- only one export runs at a time: a run is skipped (and retried) while another
+  export or sync holds the lock in the state directory
+- SIGINT or SIGTERM stops the daemon once the note that is being written is
+  finished. A second signal stops it right away.
+
+--summary-format json prints the summary of every run as JSON. A run in which
+notes or hooks failed is a failed run.
+
+Examples:
+  readdeck-highlight-exporter sync
+  readdeck-highlight-exporter sync --watch
+  readdeck-highlight-exporter sync --watch --interval=1h`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		requireConfig(true)
+
+		if cmd.Flags().Changed("interval") && !syncWatch {
+			fatalf("--interval is only used with --watch")
+		}
+		if syncInterval < minSyncInterval {
+			fatalf("--interval has to be at least %s", minSyncInterval)
+		}
+		checkSummaryFormat()
+
+		ctx, stop := signalContext()
+		defer stop()
+
+		if !syncWatch {
+			if err := syncOnce(ctx); err != nil {
+				fatalf("Sync failed:\n\n%v", err)
+			}
+			return
+		}
+
+		slog.Info("syncing periodically, press Ctrl+C to stop", "interval", syncInterval)
+		schedule.NewScheduler(syncInterval).Run(ctx, syncOnce, func(err error, delay time.Duration) {
+			if err != nil {
+				slog.Error("sync failed", "error", err)
+			}
+			slog.Info("next sync scheduled", "at", time.Now().Add(delay).Format(time.TimeOnly), "delay", delay.Round(time.Second))
+		})
+		slog.Info("sync stopped")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	syncCmd.Flags().BoolVar(&syncWatch, "watch", false, "Keep running and sync periodically")
+	syncCmd.Flags().DurationVar(&syncInterval, "interval", 15*time.Minute, "Time between two syncs with --watch")
+	syncCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Don't show the progress of the export")
+	syncCmd.Flags().StringVar(&summaryFormat, "summary-format", "text", "Format of the report printed after every run (text, json), the notes are written to the vault either way")
+}
+
+// syncOnce runs a single export to the vault, under the export lock
+func syncOnce(ctx context.Context) error {
+	lock, err := lockExport()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	startTime := time.Now()
+	slog.Info("syncing with Readdeck")
+
+	runner := newHookRunner()
+	results, err := syncNotes(ctx, "sync", runner, newProgressRenderer())
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	failed := printExportSummary(results, runner, time.Since(startTime), err != nil)
+	slog.Info("sync finished", "notes", len(results), "duration", time.Since(startTime))
+	if failed {
+		return errExportFailures
+	}
+	return nil
+}
+
+// signalContext is cancelled on SIGINT or SIGTERM, a second signal stops the process right away
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
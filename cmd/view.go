@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/config"
@@ -19,18 +20,7 @@ func showConfig() {
 	fmt.Println("\nReaddeck:")
 	fmt.Printf("  Base URL:           %s\n", viper.GetString("readdeck.base_url"))
 
-	token := viper.GetString("readdeck.token")
-	if token != "" {
-		masked := token
-		if len(token) > 8 {
-			masked = token[:4] + strings.Repeat("*", len(token)-8) + token[len(token)-4:]
-		} else if len(token) > 0 {
-			masked = strings.Repeat("*", len(token))
-		}
-		fmt.Printf("  Token:              %s\n", masked)
-	} else {
-		fmt.Printf("  Token:              <not set>\n")
-	}
+	fmt.Printf("  Token:              %s\n", maskSecret(viper.GetString("readdeck.token")))
 
 	bpp := viper.GetInt("readdeck.bookmarks_per_page")
 	defaultIndicator := ""
@@ -49,9 +39,206 @@ func showConfig() {
 	fmt.Println("\nExport:")
 	fmt.Printf("  Fleeting path:      %s\n", viper.GetString("export.fleeting_path"))
 
+	sort := viper.GetString("export.sort")
+	defaultIndicator = ""
+	if sort == defaults.Export.Sort {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Highlight order:    %s%s\n", sort, defaultIndicator)
+
+	grouping := viper.GetString("export.grouping")
+	defaultIndicator = ""
+	if grouping == defaults.Export.Grouping {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Grouping:           %s%s\n", grouping, defaultIndicator)
+	for bookmarkType, mode := range viper.GetStringMapString("export.grouping_by_type") {
+		fmt.Printf("    %-18s%s\n", bookmarkType+":", mode)
+	}
+
+	mode := viper.GetString("export.mode")
+	defaultIndicator = ""
+	if mode == defaults.Export.Mode {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Mode:               %s%s\n", mode, defaultIndicator)
+
+	format := viper.GetString("export.format")
+	defaultIndicator = ""
+	if format == defaults.Export.Format {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Format:             %s%s\n", format, defaultIndicator)
+
+	stages := viper.GetStringSlice("export.stages")
+	if len(stages) > 0 {
+		fmt.Printf("  Stages:             %s\n", strings.Join(stages, " → "))
+	} else {
+		fmt.Printf("  Stages:             <none> (default)\n")
+	}
+
+	for _, rule := range []string{"include", "exclude"} {
+		for _, field := range []string{"labels", "sites", "types", "colors"} {
+			values := viper.GetStringSlice("export." + rule + "." + field)
+			if len(values) > 0 {
+				title := strings.ToUpper(rule[:1]) + rule[1:] + " " + field + ":"
+				fmt.Printf("  %-20s%s\n", title, strings.Join(values, ", "))
+			}
+		}
+	}
+
+	fmt.Println("\nAnki:")
+	colors := strings.Join(viper.GetStringSlice("anki.colors"), ", ")
+	defaultIndicator = ""
+	if colors == strings.Join(defaults.Anki.Colors, ", ") {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Colours:            %s%s\n", colors, defaultIndicator)
+
+	deck := viper.GetString("anki.deck")
+	defaultIndicator = ""
+	if deck == defaults.Anki.Deck {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Deck:               %s%s\n", deck, defaultIndicator)
+
+	front := viper.GetString("anki.front")
+	defaultIndicator = ""
+	if front == defaults.Anki.Front {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Front:              %s%s\n", front, defaultIndicator)
+
+	fmt.Println("\nReview:")
+	limit := viper.GetInt("review.daily_limit")
+	defaultIndicator = ""
+	if limit == defaults.Review.DailyLimit {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Daily limit:        %d%s\n", limit, defaultIndicator)
+
+	fmt.Println("\nFeed:")
+	feedFile := viper.GetString("feed.path")
+	if feedFile == "" {
+		feedFile = "<not set>"
+	}
+	fmt.Printf("  Path:               %s\n", feedFile)
+
+	retention := viper.GetInt("feed.retention")
+	defaultIndicator = ""
+	if retention == defaults.Feed.Retention {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Retention:          %d%s\n", retention, defaultIndicator)
+
+	feedName := viper.GetString("feed.title")
+	defaultIndicator = ""
+	if feedName == defaults.Feed.Title {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Title:              %s%s\n", feedName, defaultIndicator)
+
+	fmt.Println("\nCitations:")
+	bibtexFile := viper.GetString("citation.bibtex_path")
+	if bibtexFile == "" {
+		bibtexFile = "<not set>"
+	}
+	fmt.Printf("  BibTeX file:        %s\n", bibtexFile)
+
+	cslFile := viper.GetString("citation.csl_path")
+	if cslFile == "" {
+		cslFile = "<not set>"
+	}
+	fmt.Printf("  CSL-JSON file:      %s\n", cslFile)
+
+	fmt.Println("\nWebhook:")
+	address := viper.GetString("webhook.address")
+	defaultIndicator = ""
+	if address == defaults.Webhook.Address {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Address:            %s%s\n", address, defaultIndicator)
+	fmt.Printf("  Secret:             %s\n", maskSecret(viper.GetString("webhook.secret")))
+
+	debounce := viper.GetDuration("webhook.debounce")
+	defaultIndicator = ""
+	if debounce == defaults.Webhook.Debounce {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Debounce:           %s%s\n", debounce, defaultIndicator)
+
+	fmt.Println("\nHooks:")
+	for _, hook := range []struct {
+		name string
+		key  string
+	}{
+		{"Pre-write", "hooks.pre_write"},
+		{"Post-write", "hooks.post_write"},
+		{"Post-run", "hooks.post_run"},
+	} {
+		commands := viper.GetStringSlice(hook.key)
+		if len(commands) == 0 {
+			fmt.Printf("  %-20s<none>\n", hook.name+":")
+			continue
+		}
+		for _, command := range commands {
+			fmt.Printf("  %-20s%s\n", hook.name+":", command)
+		}
+	}
+
+	commandTimeout := viper.GetDuration("hooks.timeout")
+	defaultIndicator = ""
+	if commandTimeout == defaults.Hooks.Timeout {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Timeout:            %s%s\n", commandTimeout, defaultIndicator)
+
+	fmt.Println("\nGit:")
+	fmt.Printf("  Commit:             %t\n", viper.GetBool("git.commit"))
+	fmt.Printf("  Push:               %t\n", viper.GetBool("git.push"))
+	if message := viper.GetString("git.message"); message != "" {
+		fmt.Printf("  Message:            %q\n", message)
+	} else {
+		fmt.Printf("  Message:            <default>\n")
+	}
+
+	fmt.Println("\nLogging:")
+	level := viper.GetString("log.level")
+	defaultIndicator = ""
+	if level == defaults.Log.Level {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Level:              %s%s\n", level, defaultIndicator)
+
+	encoding := viper.GetString("log.format")
+	defaultIndicator = ""
+	if encoding == defaults.Log.Format {
+		defaultIndicator = " (default)"
+	}
+	fmt.Printf("  Format:             %s%s\n", encoding, defaultIndicator)
+
+	logPath := viper.GetString("log.file")
+	if logPath == "" {
+		logPath = "<not set>"
+	} else if !filepath.IsAbs(logPath) {
+		logPath = filepath.Join(config.StateHome(), logPath)
+	}
+	fmt.Printf("  File:               %s\n", logPath)
+
 	fmt.Printf("\nConfiguration file: %s\n", viper.ConfigFileUsed())
 }
 
+// maskSecret only shows the start and the end of long secrets
+func maskSecret(secret string) string {
+	if secret == "" {
+		return "<not set>"
+	}
+	if len(secret) > 8 {
+		return secret[:4] + strings.Repeat("*", len(secret)-8) + secret[len(secret)-4:]
+	}
+	return strings.Repeat("*", len(secret))
+}
+
 var viewCmd = &cobra.Command{
 	Use:   "view",
 	Short: "View current configuration",
@@ -0,0 +1,128 @@
+package anki
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotes() []model.Note {
+	return []model.Note{
+		{
+			Bookmark: readdeck.Bookmark{
+				ID:      "b1",
+				Title:   "Schlep <Blindness>",
+				SiteUrl: "https://www.paulgraham.com/schlep.html",
+				Authors: []string{"Paul Graham"},
+				Labels:  []string{"startups", "must read"},
+			},
+			Highlights: []readdeck.Highlight{
+				{ID: "h2", Text: "The most dangerous thing\nabout our dislike of schleps", Color: "green", StartSelector: "p[2]"},
+				{ID: "h1", Text: "Just some yellow", Color: "yellow", StartSelector: "p[1]"},
+				{ID: "h3", Text: "Tab\tand {{braces}}", Color: "GREEN", StartSelector: "p[3]"},
+			},
+		},
+	}
+}
+
+func TestNewCards(t *testing.T) {
+	tests := []struct {
+		name          string
+		front         FrontStyle
+		expectedType  string
+		expectedFront string
+		expectedBack  string
+	}{
+		{
+			name:          "title front",
+			front:         TitleFront,
+			expectedType:  "Basic",
+			expectedFront: "<b>Schlep &lt;Blindness&gt;</b><br><i>Paul Graham</i>",
+			expectedBack:  `<blockquote>The most dangerous thing<br>about our dislike of schleps</blockquote><a href="https://www.paulgraham.com/schlep.html">Schlep &lt;Blindness&gt;</a>`,
+		},
+		{
+			name:          "cloze front",
+			front:         ClozeFront,
+			expectedType:  "Cloze",
+			expectedFront: "{{c1::The most dangerous thing<br>about our dislike of schleps::The most dangerous thing...}}<br><br><i>Schlep &lt;Blindness&gt;</i>",
+			expectedBack:  `<a href="https://www.paulgraham.com/schlep.html">Schlep &lt;Blindness&gt;</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards := NewCards(testNotes(), Config{Colors: []string{"green"}, Deck: "Readdeck", Front: tt.front, Order: readdeck.OrderPosition})
+
+			require.Len(t, cards, 2)
+			assert.Equal(t, "readdeck-h2", cards[0].GUID)
+			assert.Equal(t, "readdeck-h3", cards[1].GUID)
+			assert.Equal(t, tt.expectedType, cards[0].NoteType)
+			assert.Equal(t, tt.expectedFront, cards[0].Front)
+			assert.Equal(t, tt.expectedBack, cards[0].Back)
+			assert.Equal(t, "Readdeck", cards[0].Deck)
+			assert.Equal(t, []string{"readdeck", "green", "startups", "must_read"}, cards[0].Tags)
+		})
+	}
+}
+
+func TestNewCards_ClozeSafe(t *testing.T) {
+	cards := NewCards(testNotes(), Config{Colors: []string{"green"}, Front: ClozeFront})
+
+	require.Len(t, cards, 2)
+	assert.Contains(t, cards[1].Front, "{{c1::Tab\tand {{braces} }::")
+}
+
+func TestWriteTSV(t *testing.T) {
+	cards := NewCards(testNotes(), Config{Colors: []string{"green"}, Deck: "Readdeck", Front: TitleFront})
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteTSV(&buffer, cards))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, lines, len(tsvHeaders)+2)
+	assert.Equal(t, "#guid column:1", lines[2])
+
+	for _, line := range lines[len(tsvHeaders):] {
+		assert.Len(t, strings.Split(line, "\t"), 6, line)
+	}
+	assert.True(t, strings.HasPrefix(lines[len(tsvHeaders)], "readdeck-h2\tBasic\tReaddeck\t<b>"), lines[len(tsvHeaders)])
+	assert.True(t, strings.HasSuffix(lines[len(tsvHeaders)+1], "\treaddeck green startups must_read"))
+}
+
+func TestState(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	cards := NewCards(testNotes(), Config{Colors: []string{"green", "yellow"}})
+	require.Len(t, cards, 3)
+
+	s, err := LoadState(store)
+	require.NoError(t, err)
+	assert.Len(t, s.New(cards), 3)
+
+	s.MarkExported(cards[:2], time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC))
+	require.NoError(t, s.Save(store))
+
+	reloaded, err := LoadState(store)
+	require.NoError(t, err)
+	assert.Equal(t, "readdeck-h1", reloaded.Exported["h1"].GUID)
+	assert.Equal(t, []Card{cards[2]}, reloaded.New(cards))
+}
+
+func TestParseFrontStyle(t *testing.T) {
+	style, err := ParseFrontStyle("Cloze")
+	require.NoError(t, err)
+	assert.Equal(t, ClozeFront, style)
+
+	style, err = ParseFrontStyle("")
+	require.NoError(t, err)
+	assert.Equal(t, TitleFront, style)
+
+	_, err = ParseFrontStyle("image")
+	assert.Error(t, err)
+}
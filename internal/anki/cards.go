@@ -0,0 +1,159 @@
+package anki
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+type FrontStyle string
+
+const (
+	// TitleFront asks for the quote, showing the bookmark title on the front
+	TitleFront FrontStyle = "title"
+	// ClozeFront hides the quote behind a cloze, hinted with its first words
+	ClozeFront FrontStyle = "cloze"
+)
+
+func ParseFrontStyle(input string) (FrontStyle, error) {
+	switch FrontStyle(strings.ToLower(strings.TrimSpace(input))) {
+	case TitleFront, "":
+		return TitleFront, nil
+	case ClozeFront:
+		return ClozeFront, nil
+	default:
+		return "", fmt.Errorf("unknown front %q, expected one of: title, cloze", input)
+	}
+}
+
+const clozeHintWords = 4
+
+// Anki only allows a limited set of characters in tags
+var tagRegex = regexp.MustCompile(`[\s"]+`)
+
+type Config struct {
+	// Colors are the highlight colours that become cards
+	Colors []string
+	Deck   string
+	Front  FrontStyle
+	Order  readdeck.HighlightOrder
+}
+
+type Card struct {
+	GUID        string
+	HighlightID string
+	NoteType    string
+	Deck        string
+	Front       string
+	Back        string
+	Tags        []string
+}
+
+// GUID is derived from the highlight ID, so importing a card again updates it instead of adding a duplicate
+func GUID(highlightID string) string {
+	return "readdeck-" + highlightID
+}
+
+// NewCards creates a card for every highlight of the configured colours.
+// Cards are ordered by bookmark title, then by the configured highlight order.
+func NewCards(notes []model.Note, config Config) []Card {
+	sorted := make([]model.Note, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Bookmark.Title < sorted[j].Bookmark.Title
+	})
+
+	var cards []Card
+	for _, note := range sorted {
+		for _, h := range readdeck.SortHighlights(note.Highlights, config.Order) {
+			if !matchesColor(config.Colors, h.Color) {
+				continue
+			}
+			cards = append(cards, newCard(note.Bookmark, h, config))
+		}
+	}
+
+	return cards
+}
+
+func newCard(bookmark readdeck.Bookmark, h readdeck.Highlight, config Config) Card {
+	card := Card{
+		GUID:        GUID(h.ID),
+		HighlightID: h.ID,
+		Deck:        config.Deck,
+		Back:        sourceLink(bookmark),
+		Tags:        cardTags(bookmark, h),
+	}
+
+	switch config.Front {
+	case ClozeFront:
+		card.NoteType = "Cloze"
+		card.Front = fmt.Sprintf("{{c1::%s::%s}}<br><br><i>%s</i>", clozeSafe(toHTML(h.Text)), clozeSafe(html.EscapeString(hint(h.Text))), html.EscapeString(bookmark.Title))
+	default:
+		card.NoteType = "Basic"
+		card.Front = fmt.Sprintf("<b>%s</b>", html.EscapeString(bookmark.Title))
+		if len(bookmark.Authors) > 0 {
+			card.Front += fmt.Sprintf("<br><i>%s</i>", html.EscapeString(strings.Join(bookmark.Authors, ", ")))
+		}
+		card.Back = fmt.Sprintf("<blockquote>%s</blockquote>%s", toHTML(h.Text), card.Back)
+	}
+
+	return card
+}
+
+func matchesColor(colors []string, color string) bool {
+	for _, c := range colors {
+		if strings.EqualFold(c, color) {
+			return true
+		}
+	}
+	return false
+}
+
+func sourceLink(bookmark readdeck.Bookmark) string {
+	if bookmark.SiteUrl == "" {
+		return html.EscapeString(bookmark.Title)
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(bookmark.SiteUrl), html.EscapeString(bookmark.Title))
+}
+
+func cardTags(bookmark readdeck.Bookmark, h readdeck.Highlight) []string {
+	tags := []string{"readdeck"}
+	if h.Color != "" {
+		tags = append(tags, strings.ToLower(h.Color))
+	}
+	for _, label := range bookmark.Labels {
+		if tag := tagRegex.ReplaceAllString(strings.TrimSpace(label), "_"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func hint(text string) string {
+	words := strings.Fields(text)
+	if len(words) <= clozeHintWords {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:clozeHintWords], " ") + "..."
+}
+
+// clozeSafe breaks up the separators of the cloze syntax
+func clozeSafe(text string) string {
+	text = strings.ReplaceAll(text, "::", ": :")
+	return strings.ReplaceAll(text, "}}", "} }")
+}
+
+// toHTML escapes the text and keeps its line breaks, cards are imported as HTML
+func toHTML(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = html.EscapeString(strings.TrimSpace(line))
+	}
+	return strings.Join(lines, "<br>")
+}
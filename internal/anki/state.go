@@ -0,0 +1,51 @@
+package anki
+
+import (
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const stateName = "anki.json"
+
+// State remembers which highlights were turned into cards, so later exports only hold new cards
+type State struct {
+	Exported map[string]ExportedCard `json:"exported"`
+}
+
+type ExportedCard struct {
+	GUID       string    `json:"guid"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+func LoadState(store *state.Store) (State, error) {
+	s := State{Exported: map[string]ExportedCard{}}
+	if err := store.Load(stateName, &s); err != nil {
+		return State{}, err
+	}
+	if s.Exported == nil {
+		s.Exported = map[string]ExportedCard{}
+	}
+	return s, nil
+}
+
+func (s State) Save(store *state.Store) error {
+	return store.Save(stateName, s)
+}
+
+// New returns the cards that were not exported before
+func (s State) New(cards []Card) []Card {
+	var result []Card
+	for _, card := range cards {
+		if _, ok := s.Exported[card.HighlightID]; !ok {
+			result = append(result, card)
+		}
+	}
+	return result
+}
+
+func (s State) MarkExported(cards []Card, at time.Time) {
+	for _, card := range cards {
+		s.Exported[card.HighlightID] = ExportedCard{GUID: card.GUID, ExportedAt: at.UTC()}
+	}
+}
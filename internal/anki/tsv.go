@@ -0,0 +1,47 @@
+package anki
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The file headers tell Anki which column holds what, so the import needs no manual mapping.
+// With a GUID column, Anki updates the notes it already knows.
+var tsvHeaders = []string{
+	"#separator:tab",
+	"#html:true",
+	"#guid column:1",
+	"#notetype column:2",
+	"#deck column:3",
+	"#tags column:6",
+}
+
+// WriteTSV writes the cards as an Anki import file: guid, note type, deck, front, back and tags
+func WriteTSV(w io.Writer, cards []Card) error {
+	for _, header := range tsvHeaders {
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return fmt.Errorf("could not write anki headers: %w", err)
+		}
+	}
+
+	for _, card := range cards {
+		fields := []string{card.GUID, card.NoteType, card.Deck, card.Front, card.Back, strings.Join(card.Tags, " ")}
+		for i, field := range fields {
+			fields[i] = tsvField(field)
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return fmt.Errorf("could not write card %s: %w", card.GUID, err)
+		}
+	}
+
+	return nil
+}
+
+// tsvField keeps a field on a single line, fields are already HTML
+func tsvField(field string) string {
+	field = strings.ReplaceAll(field, "\t", " ")
+	field = strings.ReplaceAll(field, "\r", "")
+	return strings.ReplaceAll(field, "\n", "<br>")
+}
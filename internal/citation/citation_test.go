@@ -0,0 +1,193 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCitekey(t *testing.T) {
+	published := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		bookmark readdeck.Bookmark
+		expected string
+	}{
+		{
+			name:     "first author, year and title word",
+			bookmark: readdeck.Bookmark{Title: "Schlep Blindness", Authors: []string{"Paul Graham", "Jessica Livingston"}, Published: published},
+			expected: "graham2012schlep",
+		},
+		{
+			name:     "stop words are skipped",
+			bookmark: readdeck.Bookmark{Title: "The Art of Doing Science", Authors: []string{"Hamming, Richard"}, Published: published},
+			expected: "hamming2012art",
+		},
+		{
+			name:     "accents are folded",
+			bookmark: readdeck.Bookmark{Title: "Über Gödel's theorem", Authors: []string{"Kurt Gödel"}, Published: published},
+			expected: "godel2012uber",
+		},
+		{
+			name:     "site without authors",
+			bookmark: readdeck.Bookmark{Title: "Release notes", SiteUrl: "https://www.example.co/notes"},
+			expected: "examplendrelease",
+		},
+		{
+			name:     "nothing known",
+			bookmark: readdeck.Bookmark{Title: "???"},
+			expected: "anonnd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Citekey(tt.bookmark))
+		})
+	}
+}
+
+func TestRegistry_Assign(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	registry, err := Load(store)
+	require.NoError(t, err)
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	first := readdeck.Bookmark{ID: "b1", Title: "Essays", Authors: []string{"Paul Graham"}, Created: day}
+	second := readdeck.Bookmark{ID: "b2", Title: "Essays", Authors: []string{"Paul Graham"}, Created: day.Add(time.Hour)}
+
+	// The order of the input doesn't matter, the oldest bookmark gets the plain key
+	keys := registry.Assign([]readdeck.Bookmark{second, first})
+	assert.Equal(t, map[string]string{"b1": "grahamndessays", "b2": "grahamndessaysa"}, keys)
+	require.NoError(t, registry.Save(store))
+
+	// Keys are stable, even when the metadata changes
+	registry, err = Load(store)
+	require.NoError(t, err)
+	first.Title = "Collected essays"
+	first.Published = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys = registry.Assign([]readdeck.Bookmark{first})
+	assert.Equal(t, "grahamndessays", keys["b1"])
+	assert.Equal(t, "Collected essays", registry.Entries["b1"].Title)
+
+	third := readdeck.Bookmark{ID: "b3", Title: "Essays", Authors: []string{"Paul Graham"}, Created: day.Add(2 * time.Hour)}
+	assert.Equal(t, "grahamndessaysb", registry.Assign([]readdeck.Bookmark{third})["b3"])
+}
+
+func TestRegistry_Find(t *testing.T) {
+	registry := &Registry{Entries: map[string]Entry{
+		"b1": {Citekey: "graham2012schlep", BookmarkID: "b1", URL: "https://www.paulgraham.com/schlep.html"},
+	}}
+
+	for _, query := range []string{"b1", "graham2012schlep", "http://paulgraham.com/schlep.html/", " https://www.paulgraham.com/schlep.html "} {
+		entry, ok := registry.Find(query)
+		assert.True(t, ok, query)
+		assert.Equal(t, "b1", entry.BookmarkID, query)
+	}
+
+	_, ok := registry.Find("https://example.com")
+	assert.False(t, ok)
+}
+
+func testEntries() []Entry {
+	return []Entry{
+		{
+			Citekey:    "graham2012schlep",
+			BookmarkID: "b1",
+			Type:       "article",
+			Title:      "Schlep Blindness & {other} 100%",
+			Authors:    []string{"Paul Graham", "Jessica Livingston"},
+			Published:  time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC),
+			URL:        "https://www.paulgraham.com/schlep.html",
+			Accessed:   time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC),
+		},
+		{
+			Citekey: "anonndtalk",
+			Type:    "video",
+			Title:   "A talk",
+			Authors: []string{"NASA"},
+		},
+	}
+}
+
+func TestWriteBibTeX(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, WriteBibTeX(&buffer, testEntries()))
+
+	expected := `@online{graham2012schlep,
+  title = {Schlep Blindness \& \{other\} 100\%},
+  author = {Graham, Paul and Livingston, Jessica},
+  date = {2012-01-01},
+  year = {2012},
+  url = {https://www.paulgraham.com/schlep.html},
+  urldate = {2025-03-23},
+}
+
+@video{anonndtalk,
+  title = {A talk},
+  author = {{NASA}},
+}
+`
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestWriteCSL(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, WriteCSL(&buffer, testEntries()))
+
+	var items []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &items))
+	require.Len(t, items, 2)
+
+	assert.Equal(t, "graham2012schlep", items[0]["id"])
+	assert.Equal(t, "webpage", items[0]["type"])
+	assert.Equal(t, "paulgraham.com", items[0]["container-title"])
+	assert.Equal(t, "https://www.paulgraham.com/schlep.html", items[0]["URL"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"family": "Graham", "given": "Paul"}, map[string]interface{}{"family": "Livingston", "given": "Jessica"}}, items[0]["author"])
+	assert.Equal(t, map[string]interface{}{"date-parts": []interface{}{[]interface{}{2012.0, 1.0, 1.0}}}, items[0]["issued"])
+
+	assert.Equal(t, "motion_picture", items[1]["type"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"literal": "NASA"}}, items[1]["author"])
+	assert.NotContains(t, items[1], "issued")
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "refs", "highlights.bib")
+
+	require.NoError(t, WriteFile(path, BibTeXFormat, testEntries()))
+	require.NoError(t, WriteFile(path, BibTeXFormat, testEntries()[:1]))
+
+	var expected bytes.Buffer
+	require.NoError(t, WriteBibTeX(&expected, testEntries()[:1]))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), string(content))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat(" BibTeX ")
+	require.NoError(t, err)
+	assert.Equal(t, BibTeXFormat, format)
+
+	format, err = ParseFormat("csl-json")
+	require.NoError(t, err)
+	assert.Equal(t, CSLFormat, format)
+
+	_, err = ParseFormat("ris")
+	assert.Error(t, err)
+}
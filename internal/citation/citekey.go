@@ -0,0 +1,83 @@
+package citation
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// Words that don't say anything about the title, skipped when picking the title word of a citekey
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "of": true, "on": true, "in": true,
+	"to": true, "for": true, "with": true, "at": true, "by": true, "from": true, "is": true,
+	"how": true, "why": true, "what": true, "when": true, "i": true, "my": true, "your": true,
+}
+
+// Citekey is the key of a bookmark before collisions are resolved: the surname of the first author,
+// the year it was published and the first meaningful word of the title, eg. graham2012schlep
+func Citekey(b readdeck.Bookmark) string {
+	name := "anon"
+	if len(b.Authors) > 0 {
+		family, _, _ := Name(b.Authors[0])
+		if surname := keyPart(family); surname != "" {
+			name = surname
+		}
+	} else if site := siteName(b.SiteUrl); site != "" {
+		name = site
+	}
+
+	year := "nd"
+	if !b.Published.IsZero() {
+		year = b.Published.Format("2006")
+	}
+
+	return name + year + titleWord(b.Title)
+}
+
+func titleWord(title string) string {
+	for _, word := range strings.Fields(title) {
+		part := keyPart(word)
+		if part != "" && !stopWords[part] {
+			return part
+		}
+	}
+	return ""
+}
+
+// siteName is the main part of the domain, eg. paulgraham for www.paulgraham.com
+func siteName(siteUrl string) string {
+	parsed, err := url.Parse(siteUrl)
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+	labels := strings.Split(strings.TrimPrefix(parsed.Hostname(), "www."), ".")
+	if len(labels) > 1 {
+		labels = labels[:len(labels)-1]
+	}
+	return keyPart(labels[len(labels)-1])
+}
+
+// Common latin letters with diacritics, so they don't disappear from citekeys
+var foldReplacer = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "æ", "ae",
+	"ç", "c", "č", "c", "ć", "c",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ě", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i",
+	"ł", "l", "ñ", "n", "ń", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "œ", "oe",
+	"ř", "r", "š", "s", "ś", "s", "ß", "ss",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ů", "u",
+	"ý", "y", "ÿ", "y", "ž", "z", "ź", "z", "ż", "z",
+)
+
+// keyPart keeps the lower case ASCII letters and digits, accents are dropped: Gödel becomes godel
+func keyPart(input string) string {
+	var builder strings.Builder
+	for _, r := range foldReplacer.Replace(strings.ToLower(input)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,147 @@
+package citation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const stateName = "citations.json"
+
+// Entry is the bibliographic data of an exported bookmark
+type Entry struct {
+	Citekey    string    `json:"citekey"`
+	BookmarkID string    `json:"bookmark_id"`
+	Type       string    `json:"type"`
+	Title      string    `json:"title"`
+	Authors    []string  `json:"authors"`
+	Published  time.Time `json:"published"`
+	URL        string    `json:"url"`
+	// Accessed is when the bookmark was saved in Readdeck
+	Accessed time.Time `json:"accessed"`
+}
+
+// Registry remembers the citekey of every exported bookmark, so keys never change once they are in use
+type Registry struct {
+	// Entries by bookmark ID
+	Entries map[string]Entry `json:"entries"`
+}
+
+func Load(store *state.Store) (*Registry, error) {
+	registry := &Registry{}
+	if err := store.Load(stateName, registry); err != nil {
+		return nil, err
+	}
+	if registry.Entries == nil {
+		registry.Entries = make(map[string]Entry)
+	}
+	return registry, nil
+}
+
+func (r *Registry) Save(store *state.Store) error {
+	return store.Save(stateName, r)
+}
+
+// Assign returns the citekey of every bookmark, by bookmark ID. Known bookmarks keep their key,
+// their metadata is updated. New bookmarks are handled oldest first, so colliding keys get their
+// suffix (a, b, ...) in a predictable order.
+func (r *Registry) Assign(bookmarks []readdeck.Bookmark) map[string]string {
+	sorted := make([]readdeck.Bookmark, len(bookmarks))
+	copy(sorted, bookmarks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Created.Equal(sorted[j].Created) {
+			return sorted[i].Created.Before(sorted[j].Created)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	used := make(map[string]bool, len(r.Entries))
+	for _, e := range r.Entries {
+		used[e.Citekey] = true
+	}
+
+	keys := make(map[string]string, len(sorted))
+	for _, b := range sorted {
+		key := r.Entries[b.ID].Citekey
+		if key == "" {
+			key = uniqueKey(Citekey(b), used)
+			used[key] = true
+		}
+
+		r.Entries[b.ID] = Entry{
+			Citekey:    key,
+			BookmarkID: b.ID,
+			Type:       b.Type,
+			Title:      b.Title,
+			Authors:    b.Authors,
+			Published:  b.Published,
+			URL:        b.SiteUrl,
+			Accessed:   b.Created,
+		}
+		keys[b.ID] = key
+	}
+	return keys
+}
+
+func uniqueKey(base string, used map[string]bool) string {
+	if !used[base] {
+		return base
+	}
+	for suffix := 'a'; suffix <= 'z'; suffix++ {
+		if key := base + string(suffix); !used[key] {
+			return key
+		}
+	}
+	for i := 2; ; i++ {
+		if key := fmt.Sprintf("%s%d", base, i); !used[key] {
+			return key
+		}
+	}
+}
+
+// Sorted returns the entries ordered by citekey
+func (r *Registry) Sorted() []Entry {
+	entries := make([]Entry, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Citekey < entries[j].Citekey
+	})
+	return entries
+}
+
+// Find looks up an entry by citekey, bookmark ID or URL. URLs match regardless of
+// their scheme, a leading www. or a trailing slash.
+func (r *Registry) Find(query string) (Entry, bool) {
+	query = strings.TrimSpace(query)
+	if e, ok := r.Entries[query]; ok {
+		return e, true
+	}
+
+	for _, e := range r.Sorted() {
+		if e.Citekey == query {
+			return e, true
+		}
+	}
+
+	normalized := normalizeURL(query)
+	for _, e := range r.Sorted() {
+		if e.URL != "" && normalizeURL(e.URL) == normalized {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+func normalizeURL(input string) string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	input = strings.TrimPrefix(input, "https://")
+	input = strings.TrimPrefix(input, "http://")
+	input = strings.TrimPrefix(input, "www.")
+	return strings.TrimRight(input, "/")
+}
@@ -0,0 +1,219 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+type Format string
+
+const (
+	// BibTeXFormat writes biblatex entries, as used by LaTeX, pandoc and Zotero
+	BibTeXFormat Format = "bibtex"
+	// CSLFormat writes CSL-JSON, as used by pandoc and most citation processors
+	CSLFormat Format = "csl"
+)
+
+func ParseFormat(input string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "bibtex", "bib", "biblatex":
+		return BibTeXFormat, nil
+	case "csl", "csl-json", "json":
+		return CSLFormat, nil
+	default:
+		return "", fmt.Errorf("unknown citation format %q, expected bibtex or csl", input)
+	}
+}
+
+func Write(w io.Writer, format Format, entries []Entry) error {
+	switch format {
+	case CSLFormat:
+		return WriteCSL(w, entries)
+	default:
+		return WriteBibTeX(w, entries)
+	}
+}
+
+// WriteFile replaces the bibliography at path through a temporary file, so tools that
+// read it never see a half written one and a failed write keeps the previous entries
+func WriteFile(path string, format Format, entries []Entry) error {
+	var buffer bytes.Buffer
+	if err := Write(&buffer, format, entries); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create bibliography directory: %w", err)
+	}
+
+	// The bibliography is read by other tools
+	if err := util.WriteFileAtomic(path, buffer.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write bibliography: %w", err)
+	}
+	return nil
+}
+
+// Name splits an author into family and given names.
+// Single names, like organisations, have no given name and ok is false.
+func Name(author string) (family string, given string, ok bool) {
+	author = strings.TrimSpace(author)
+	if family, given, found := strings.Cut(author, ","); found {
+		return strings.TrimSpace(family), strings.TrimSpace(given), true
+	}
+
+	fields := strings.Fields(author)
+	if len(fields) < 2 {
+		return author, "", false
+	}
+	return fields[len(fields)-1], strings.Join(fields[:len(fields)-1], " "), true
+}
+
+var bibtexTypes = map[string]string{
+	"article": "online",
+	"video":   "video",
+	"photo":   "artwork",
+}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`, "}", `\}`,
+	"&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+	"~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
+
+// WriteBibTeX writes biblatex entries, the fields are written in a fixed order
+// so the file only changes when the metadata does
+func WriteBibTeX(w io.Writer, entries []Entry) error {
+	for i, e := range entries {
+		entryType, ok := bibtexTypes[e.Type]
+		if !ok {
+			entryType = "misc"
+		}
+
+		var builder strings.Builder
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		fmt.Fprintf(&builder, "@%s{%s,\n", entryType, e.Citekey)
+		writeBibField(&builder, "title", bibtexEscaper.Replace(e.Title))
+		if len(e.Authors) > 0 {
+			writeBibField(&builder, "author", bibtexAuthors(e.Authors))
+		}
+		if !e.Published.IsZero() {
+			writeBibField(&builder, "date", e.Published.Format("2006-01-02"))
+			writeBibField(&builder, "year", e.Published.Format("2006"))
+		}
+		if e.URL != "" {
+			writeBibField(&builder, "url", e.URL)
+		}
+		if !e.Accessed.IsZero() {
+			writeBibField(&builder, "urldate", e.Accessed.Format("2006-01-02"))
+		}
+		builder.WriteString("}\n")
+
+		if _, err := io.WriteString(w, builder.String()); err != nil {
+			return fmt.Errorf("could not write bibtex entry %s: %w", e.Citekey, err)
+		}
+	}
+	return nil
+}
+
+func writeBibField(builder *strings.Builder, name string, value string) {
+	fmt.Fprintf(builder, "  %s = {%s},\n", name, value)
+}
+
+func bibtexAuthors(authors []string) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		family, given, ok := Name(author)
+		if !ok {
+			// Braces keep organisations from being split into a family and given name
+			names = append(names, "{"+bibtexEscaper.Replace(family)+"}")
+			continue
+		}
+		names = append(names, bibtexEscaper.Replace(family)+", "+bibtexEscaper.Replace(given))
+	}
+	return strings.Join(names, " and ")
+}
+
+var cslTypes = map[string]string{
+	"article": "webpage",
+	"video":   "motion_picture",
+	"photo":   "graphic",
+}
+
+type cslItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []cslName `json:"author,omitempty"`
+	Issued         *cslDate  `json:"issued,omitempty"`
+	Accessed       *cslDate  `json:"accessed,omitempty"`
+	URL            string    `json:"URL,omitempty"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func WriteCSL(w io.Writer, entries []Entry) error {
+	items := make([]cslItem, 0, len(entries))
+	for _, e := range entries {
+		itemType, ok := cslTypes[e.Type]
+		if !ok {
+			itemType = "document"
+		}
+
+		item := cslItem{
+			ID:       e.Citekey,
+			Type:     itemType,
+			Title:    e.Title,
+			Issued:   newCSLDate(e.Published),
+			Accessed: newCSLDate(e.Accessed),
+			URL:      e.URL,
+		}
+		if parsed, err := url.Parse(e.URL); err == nil && parsed.Hostname() != "" {
+			item.ContainerTitle = strings.TrimPrefix(parsed.Hostname(), "www.")
+		}
+		for _, author := range e.Authors {
+			family, given, ok := Name(author)
+			if !ok {
+				item.Author = append(item.Author, cslName{Literal: family})
+				continue
+			}
+			item.Author = append(item.Author, cslName{Family: family, Given: given})
+		}
+		items = append(items, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(items); err != nil {
+		return fmt.Errorf("could not write CSL-JSON: %w", err)
+	}
+	return nil
+}
+
+func newCSLDate(t time.Time) *cslDate {
+	if t.IsZero() {
+		return nil
+	}
+	return &cslDate{DateParts: [][]int{{t.Year(), int(t.Month()), t.Day()}}}
+}
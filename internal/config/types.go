@@ -10,6 +10,14 @@ import (
 type Settings struct {
 	Readdeck ReaddeckSettings `mapstructure:"readdeck"`
 	Export   ExportSettings   `mapstructure:"export"`
+	Anki     AnkiSettings     `mapstructure:"anki"`
+	Review   ReviewSettings   `mapstructure:"review"`
+	Feed     FeedSettings     `mapstructure:"feed"`
+	Citation CitationSettings `mapstructure:"citation"`
+	Webhook  WebhookSettings  `mapstructure:"webhook"`
+	Hooks    HooksSettings    `mapstructure:"hooks"`
+	Git      GitSettings      `mapstructure:"git"`
+	Log      LogSettings      `mapstructure:"log"`
 }
 
 type ReaddeckSettings struct {
@@ -21,6 +29,103 @@ type ReaddeckSettings struct {
 
 type ExportSettings struct {
 	FleetingPath string `mapstructure:"fleeting_path"`
+	Sort         string `mapstructure:"sort"`
+	// Grouping is one of color, flat, date or section
+	Grouping       string            `mapstructure:"grouping"`
+	GroupingByType map[string]string `mapstructure:"grouping_by_type"`
+	// Mode is either bookmark (a note per bookmark) or atomic (a note per highlight)
+	Mode string `mapstructure:"mode"`
+	// Format is the flavour of the notes: markdown, logseq or org
+	Format string `mapstructure:"format"`
+	// Routes send matching highlights to another folder, the first matching route wins
+	Routes []RouteSettings `mapstructure:"routes"`
+	// Include and Exclude filter every export, the filter flags of export replace the include rules
+	Include FilterSettings `mapstructure:"include"`
+	Exclude FilterSettings `mapstructure:"exclude"`
+	// Stages transform the notes before they are written, in order (clean-whitespace, straight-quotes, dedupe)
+	Stages []string `mapstructure:"stages"`
+}
+
+type FilterSettings struct {
+	Labels []string `mapstructure:"labels"`
+	// Sites are domains, their subdomains match too
+	Sites  []string `mapstructure:"sites"`
+	Types  []string `mapstructure:"types"`
+	Colors []string `mapstructure:"colors"`
+}
+
+type RouteSettings struct {
+	Color string `mapstructure:"color"`
+	Label string `mapstructure:"label"`
+	Type  string `mapstructure:"type"`
+	Path  string `mapstructure:"path"`
+	// Mode and Grouping shape the notes of this route, they default to the export settings
+	Mode     string `mapstructure:"mode"`
+	Grouping string `mapstructure:"grouping"`
+}
+
+type AnkiSettings struct {
+	// Colors are the highlight colours that become flashcards
+	Colors []string `mapstructure:"colors"`
+	Deck   string   `mapstructure:"deck"`
+	// Front is either title (the bookmark title) or cloze (the quote as cloze deletion)
+	Front string `mapstructure:"front"`
+}
+
+type ReviewSettings struct {
+	// DailyLimit is the number of highlights to review per day
+	DailyLimit int `mapstructure:"daily_limit"`
+}
+
+type FeedSettings struct {
+	// Path of the Atom feed of new highlights, the feed is only written when it is set
+	Path string `mapstructure:"path"`
+	// Retention is the number of entries kept in the feed
+	Retention int    `mapstructure:"retention"`
+	Title     string `mapstructure:"title"`
+}
+
+type CitationSettings struct {
+	// BibTeXPath and CSLPath are bibliography files of all exported bookmarks, rewritten after each export
+	BibTeXPath string `mapstructure:"bibtex_path"`
+	CSLPath    string `mapstructure:"csl_path"`
+}
+
+type WebhookSettings struct {
+	// Address the serve command listens on
+	Address string `mapstructure:"address"`
+	// Secret has to be sent along with every notification, serve doesn't start without one
+	Secret string `mapstructure:"secret"`
+	// Debounce is the quiet time after the last notification before exporting
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+type HooksSettings struct {
+	// PreWrite commands get every note before it is written, they can change or veto it
+	PreWrite []string `mapstructure:"pre_write"`
+	// PostWrite commands run after every note that is written, PostRun ones after every export
+	PostWrite []string `mapstructure:"post_write"`
+	PostRun   []string `mapstructure:"post_run"`
+	// Timeout applies to every single command
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type GitSettings struct {
+	// Commit the notes written by an export to the git repository of the fleeting path
+	Commit bool `mapstructure:"commit"`
+	// Message is a text/template of the commit message, empty for the default message
+	Message string `mapstructure:"message"`
+	// Push the commit to the upstream of the current branch
+	Push bool `mapstructure:"push"`
+}
+
+type LogSettings struct {
+	// Level is one of debug, info, warn or error
+	Level string `mapstructure:"level"`
+	// Format is either text or json
+	Format string `mapstructure:"format"`
+	// File receives the logs as well as stderr, a relative path is relative to the state directory
+	File string `mapstructure:"file"`
 }
 
 func DefaultSettings() Settings {
@@ -29,6 +134,35 @@ func DefaultSettings() Settings {
 			BookmarksPerPage: 100,
 			RequestTimeout:   time.Second * 30,
 		},
+		Export: ExportSettings{
+			Sort:     "position",
+			Grouping: "color",
+			Mode:     "bookmark",
+			Format:   "markdown",
+		},
+		Anki: AnkiSettings{
+			Colors: []string{"green"},
+			Deck:   "Readdeck",
+			Front:  "title",
+		},
+		Review: ReviewSettings{
+			DailyLimit: 10,
+		},
+		Feed: FeedSettings{
+			Retention: 50,
+			Title:     "Readdeck highlights",
+		},
+		Webhook: WebhookSettings{
+			Address:  "127.0.0.1:8421",
+			Debounce: 10 * time.Second,
+		},
+		Hooks: HooksSettings{
+			Timeout: 30 * time.Second,
+		},
+		Log: LogSettings{
+			Level:  "info",
+			Format: "text",
+		},
 	}
 }
 
@@ -63,5 +197,82 @@ func LoadAndValidate() (Settings, error) {
 		return Settings{}, fmt.Errorf("export.fleeting_path is required")
 	}
 
+	if settings.Export.Sort == "" {
+		settings.Export.Sort = defaults.Export.Sort
+	}
+
+	if settings.Export.Grouping == "" {
+		settings.Export.Grouping = defaults.Export.Grouping
+	}
+
+	if settings.Export.Mode == "" {
+		settings.Export.Mode = defaults.Export.Mode
+	}
+
+	if settings.Export.Format == "" {
+		settings.Export.Format = defaults.Export.Format
+	}
+
+	if len(settings.Anki.Colors) == 0 {
+		settings.Anki.Colors = defaults.Anki.Colors
+	}
+
+	if settings.Anki.Deck == "" {
+		settings.Anki.Deck = defaults.Anki.Deck
+	}
+
+	if settings.Anki.Front == "" {
+		settings.Anki.Front = defaults.Anki.Front
+	}
+
+	if settings.Review.DailyLimit == 0 {
+		settings.Review.DailyLimit = defaults.Review.DailyLimit
+	} else if settings.Review.DailyLimit < 0 {
+		return Settings{}, fmt.Errorf("review.daily_limit can't be negative")
+	}
+
+	if settings.Feed.Retention == 0 {
+		settings.Feed.Retention = defaults.Feed.Retention
+	} else if settings.Feed.Retention < 0 {
+		return Settings{}, fmt.Errorf("feed.retention can't be negative")
+	}
+
+	if settings.Feed.Title == "" {
+		settings.Feed.Title = defaults.Feed.Title
+	}
+
+	if settings.Webhook.Address == "" {
+		settings.Webhook.Address = defaults.Webhook.Address
+	}
+
+	if settings.Webhook.Debounce == 0 {
+		settings.Webhook.Debounce = defaults.Webhook.Debounce
+	} else if settings.Webhook.Debounce < 0 {
+		return Settings{}, fmt.Errorf("webhook.debounce can't be negative")
+	}
+
+	if settings.Hooks.Timeout == 0 {
+		settings.Hooks.Timeout = defaults.Hooks.Timeout
+	} else if settings.Hooks.Timeout < 0 {
+		return Settings{}, fmt.Errorf("hooks.timeout can't be negative")
+	}
+
+	if settings.Log.Level == "" {
+		settings.Log.Level = defaults.Log.Level
+	}
+
+	if settings.Log.Format == "" {
+		settings.Log.Format = defaults.Log.Format
+	}
+
+	for i, route := range settings.Export.Routes {
+		if route.Path == "" {
+			return Settings{}, fmt.Errorf("export.routes[%d].path is required", i)
+		}
+		if route.Color == "" && route.Label == "" && route.Type == "" {
+			return Settings{}, fmt.Errorf("export.routes[%d] needs a color, label or type to match on", i)
+		}
+	}
+
 	return settings, nil
 }
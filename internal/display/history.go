@@ -0,0 +1,121 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/history"
+)
+
+const historyTimeFmt = "2006-01-02 15:04:05"
+
+// PrintRuns lists the runs of the history, a line per run
+func PrintRuns(runs []history.Run) {
+	fmt.Println("\n" + HeaderColor("Export History"))
+	fmt.Println(HeaderColor("==================================="))
+
+	for _, run := range runs {
+		fmt.Printf("%s  %s  %-8s %8s  %s%s\n",
+			BoldTitle(fmt.Sprintf("#%-4d", run.ID)),
+			run.Started.Local().Format(historyTimeFmt),
+			run.Trigger,
+			formatDuration(run.Duration()),
+			formatCounts(run.Counts),
+			runStatus(run))
+	}
+}
+
+// PrintRun reprints the summary of a past run, with the notes it wrote
+func PrintRun(run history.Run) {
+	fmt.Println("\n" + HeaderColor(fmt.Sprintf("Run #%d (%s)", run.ID, run.Trigger)))
+	fmt.Println(HeaderColor("==================================="))
+
+	fmt.Printf("Started:  %s\n", run.Started.Local().Format(historyTimeFmt))
+	fmt.Printf("Finished: %s (%s)%s\n", run.Finished.Local().Format(historyTimeFmt), TimeColor(formatDuration(run.Duration())), runStatus(run))
+	fmt.Printf("Processed %d notes (%s)\n", run.Counts.Created+run.Counts.Updated+run.Counts.Unchanged+run.Counts.Failed, formatCounts(run.Counts))
+
+	if run.HighlightsAdded > 0 {
+		fmt.Printf("Total highlights: %d (%s new added)\n", run.Highlights, CreatedColor(fmt.Sprintf("+%d", run.HighlightsAdded)))
+	} else {
+		fmt.Printf("Total highlights: %d\n", run.Highlights)
+	}
+
+	if run.Error != "" {
+		fmt.Printf("Error: %s\n", Red(run.Error))
+	}
+
+	if len(run.Phases) > 0 {
+		printSection("Phases")
+		for _, phase := range run.Phases {
+			fmt.Printf("  %-10s %6d in %s\n", phase.Stage, phase.Done, TimeColor(formatDuration(phase.Duration)))
+		}
+	}
+
+	if len(run.HookFailures) > 0 {
+		printSection("Hook failures")
+		for _, failure := range run.HookFailures {
+			fmt.Printf("  - %s\n", failure)
+		}
+	}
+
+	for _, group := range []struct {
+		title string
+		kind  string
+	}{
+		{BoldCreated("✨ Created:"), "created"},
+		{BoldUpdated("🔄 Updated:"), "updated"},
+		{Red("❌ Failed:"), "failed"},
+		{UpdatedColor("🚫 Vetoed:"), "vetoed"},
+	} {
+		printed := false
+		for _, note := range run.Notes {
+			if note.Type != group.kind {
+				continue
+			}
+			if !printed {
+				fmt.Println("\n" + group.title)
+				printed = true
+			}
+			printHistoryNote(note)
+		}
+	}
+}
+
+func printHistoryNote(note history.Note) {
+	fmt.Printf("%s\n", BoldTitle(note.BookmarkTitle))
+	fmt.Printf("    Highlights: %d", note.Highlights)
+	if note.Type == "updated" && len(note.Added) > 0 {
+		fmt.Printf(" (%s)", CreatedColor(fmt.Sprintf("+%d", len(note.Added))))
+	}
+	fmt.Println()
+
+	if note.Path != "" {
+		fmt.Printf("    Path: %s\n", note.Path)
+	}
+	if note.Error != "" {
+		fmt.Printf("    Error: %s\n", note.Error)
+	}
+}
+
+func formatCounts(counts history.Counts) string {
+	text := fmt.Sprintf("%s created, %s updated, %s unchanged",
+		CreatedColor(fmt.Sprintf("%d", counts.Created)),
+		UpdatedColor(fmt.Sprintf("%d", counts.Updated)),
+		UnchangedColor(fmt.Sprintf("%d", counts.Unchanged)))
+	if counts.Failed > 0 {
+		text += fmt.Sprintf(", %s failed", Red(fmt.Sprintf("%d", counts.Failed)))
+	}
+	if counts.Vetoed > 0 {
+		text += fmt.Sprintf(", %s vetoed", UpdatedColor(fmt.Sprintf("%d", counts.Vetoed)))
+	}
+	return text
+}
+
+func runStatus(run history.Run) string {
+	switch {
+	case run.Interrupted:
+		return " " + UpdatedColor("interrupted")
+	case run.Error != "":
+		return " " + Red("failed")
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/hooks"
+)
+
+// PrintHookFailures lists the hooks that failed or vetoed a write during the export
+func PrintHookFailures(failures []hooks.Failure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	vetoed := 0
+	for _, f := range failures {
+		if f.Vetoed {
+			vetoed++
+		}
+	}
+
+	fmt.Printf("Hooks: %s, %s vetoed\n",
+		Red(fmt.Sprintf("%d failed", len(failures)-vetoed)),
+		UpdatedColor(fmt.Sprintf("%d", vetoed)))
+	for _, f := range failures {
+		fmt.Printf("  - %s\n", f.Error())
+	}
+}
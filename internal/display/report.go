@@ -0,0 +1,98 @@
+package display
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/hooks"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+)
+
+// Report is the machine readable summary of an export, the notes use the shape of the hook payloads
+type Report struct {
+	Counts       ReportCounts        `json:"counts"`
+	Highlights   int                 `json:"highlights"`
+	Added        int                 `json:"highlights_added"`
+	DurationMS   int64               `json:"duration_ms"`
+	Interrupted  bool                `json:"interrupted"`
+	Notes        []hooks.Operation   `json:"notes"`
+	HookFailures []ReportHookFailure `json:"hook_failures"`
+}
+
+type ReportCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+	Vetoed    int `json:"vetoed"`
+}
+
+type ReportHookFailure struct {
+	Event   hooks.Event `json:"event"`
+	Command string      `json:"command"`
+	Path    string      `json:"path,omitempty"`
+	Vetoed  bool        `json:"vetoed"`
+	Error   string      `json:"error"`
+}
+
+func NewReport(results []repository.OperationResult, failures []hooks.Failure, duration time.Duration, interrupted bool) Report {
+	report := Report{
+		DurationMS:   duration.Milliseconds(),
+		Interrupted:  interrupted,
+		Notes:        make([]hooks.Operation, 0, len(results)),
+		HookFailures: make([]ReportHookFailure, 0, len(failures)),
+	}
+
+	for _, r := range results {
+		report.Notes = append(report.Notes, hooks.NewOperation(r))
+
+		switch r.Type {
+		case "created":
+			report.Counts.Created++
+		case "updated":
+			report.Counts.Updated++
+		case "unchanged":
+			report.Counts.Unchanged++
+		case "failed":
+			report.Counts.Failed++
+			continue
+		case "vetoed":
+			report.Counts.Vetoed++
+			continue
+		}
+		report.Highlights += len(r.Note.Highlights)
+		report.Added += r.HighlightsAdded
+	}
+
+	for _, f := range failures {
+		report.HookFailures = append(report.HookFailures, ReportHookFailure{
+			Event:   f.Event,
+			Command: f.Command,
+			Path:    f.Path,
+			Vetoed:  f.Vetoed,
+			Error:   f.Err.Error(),
+		})
+	}
+	return report
+}
+
+// Failed tells whether a note or a hook failed. A hook that vetoes a write did its job, it is no failure.
+func (r Report) Failed() bool {
+	if r.Counts.Failed > 0 {
+		return true
+	}
+	for _, f := range r.HookFailures {
+		if !f.Vetoed {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteReport writes the report as indented JSON
+func WriteReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
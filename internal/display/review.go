@@ -0,0 +1,57 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/review"
+)
+
+func PrintReviewHighlight(entry library.Entry, position, total int) {
+	fmt.Printf("\n%s\n", HeaderColor(fmt.Sprintf("Highlight %d of %d", position, total)))
+	fmt.Println(HeaderColor("==================================="))
+
+	for _, line := range strings.Split(strings.TrimSpace(entry.Text), "\n") {
+		fmt.Printf("  %s\n", colorize(entry.Color, line))
+	}
+
+	fmt.Printf("\n— %s", BoldTitle(entry.BookmarkTitle))
+	if len(entry.Authors) > 0 {
+		fmt.Printf(", %s", strings.Join(entry.Authors, ", "))
+	}
+	fmt.Println()
+
+	if entry.URL != "" {
+		fmt.Printf("  %s\n", entry.URL)
+	}
+	if entry.Path != "" {
+		fmt.Printf("  %s\n", entry.Path)
+	}
+}
+
+func PrintReviewSummary(grades map[review.Grade]int, reviewed int) {
+	fmt.Println("\n" + HeaderColor("Review Summary"))
+	fmt.Println(HeaderColor("==================================="))
+	fmt.Printf("Reviewed %d highlights (%s again, %s hard, %s good, %s easy)\n",
+		reviewed,
+		Red(fmt.Sprintf("%d", grades[review.Again])),
+		Yellow(fmt.Sprintf("%d", grades[review.Hard])),
+		Green(fmt.Sprintf("%d", grades[review.Good])),
+		Blue(fmt.Sprintf("%d", grades[review.Easy])))
+}
+
+func colorize(color string, text string) string {
+	switch color {
+	case "yellow":
+		return Yellow(text)
+	case "red":
+		return Red(text)
+	case "blue":
+		return Blue(text)
+	case "green":
+		return Green(text)
+	default:
+		return White(text)
+	}
+}
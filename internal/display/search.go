@@ -0,0 +1,33 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/search"
+)
+
+func PrintSearchResults(results []search.Result) {
+	if len(results) == 0 {
+		fmt.Println("No highlights found")
+		return
+	}
+
+	for i, result := range results {
+		entry := result.Entry
+		fmt.Printf("\n%s %s", HeaderColor(fmt.Sprintf("%d.", i+1)), BoldTitle(entry.BookmarkTitle))
+		if len(entry.Authors) > 0 {
+			fmt.Printf(", %s", strings.Join(entry.Authors, ", "))
+		}
+		fmt.Printf(" (%s)\n", entry.Created.Format("2006-01-02"))
+
+		for _, line := range strings.Split(strings.TrimSpace(entry.Text), "\n") {
+			fmt.Printf("  %s\n", colorize(entry.Color, line))
+		}
+		if entry.Path != "" {
+			fmt.Printf("  %s\n", entry.Path)
+		}
+	}
+
+	fmt.Printf("\n%d highlight(s) found\n", len(results))
+}
@@ -0,0 +1,19 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/service"
+)
+
+// PrintStageReport prints what a transform stage did to stderr, so data exports keep a clean stdout
+func PrintStageReport(report service.StageReport) {
+	status := CreatedColor("ok")
+	if report.Err != nil {
+		status = Red(report.Err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Stage %-18s %d → %d notes in %s: %s\n",
+		report.Stage, report.NotesIn, report.NotesOut, report.Duration.Round(time.Microsecond), status)
+}
@@ -0,0 +1,98 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/stats"
+)
+
+const (
+	barWidth     = 30
+	statsMonths  = 12
+	statsDateFmt = "2006-01-02"
+)
+
+func PrintStats(s stats.Stats) {
+	fmt.Println("\n" + HeaderColor("Highlight Stats"))
+	fmt.Println(HeaderColor("==================================="))
+
+	if s.Highlights == 0 {
+		fmt.Println("No highlights yet")
+		return
+	}
+
+	fmt.Printf("%s highlights in %s bookmarks (%.1f per bookmark)\n",
+		CreatedColor(fmt.Sprintf("%d", s.Highlights)),
+		CreatedColor(fmt.Sprintf("%d", s.Bookmarks)),
+		s.AveragePerBookmark)
+	fmt.Printf("From %s until %s\n", s.First.Format(statsDateFmt), s.Last.Format(statsDateFmt))
+
+	months := s.Months
+	if len(months) > statsMonths {
+		months = months[len(months)-statsMonths:]
+	}
+	printSection("Per month")
+	printPeriods(months)
+
+	printSection("Per week")
+	printPeriods(s.Weeks)
+
+	printSection("Colours")
+	maxColor := 0
+	for _, c := range s.Colors {
+		maxColor = max(maxColor, c.Highlights)
+	}
+	for _, c := range s.Colors {
+		fmt.Printf("  %-28s %s %d\n", c.Name, colorize(c.Color, bar(c.Highlights, maxColor)), c.Highlights)
+	}
+
+	printCounts("Top sites", s.Sites)
+	printCounts("Top authors", s.Authors)
+	printCounts("Top labels", s.Labels)
+
+	printSection("Streaks")
+	if s.CurrentStreak.Days > 0 {
+		fmt.Printf("  Current: %s day(s), since %s\n", CreatedColor(fmt.Sprintf("%d", s.CurrentStreak.Days)), s.CurrentStreak.Start.Format(statsDateFmt))
+	} else {
+		fmt.Println("  Current: none")
+	}
+	for _, streak := range s.Streaks {
+		fmt.Printf("  %3d day(s)  %s → %s\n", streak.Days, streak.Start.Format(statsDateFmt), streak.End.Format(statsDateFmt))
+	}
+}
+
+func printSection(title string) {
+	fmt.Println("\n" + BoldTitle(title))
+}
+
+func printPeriods(periods []stats.Period) {
+	maxCount := 0
+	for _, p := range periods {
+		maxCount = max(maxCount, p.Highlights)
+	}
+	for _, p := range periods {
+		fmt.Printf("  %-8s %s %d\n", p.Name, Green(bar(p.Highlights, maxCount)), p.Highlights)
+	}
+}
+
+func printCounts(title string, counts []stats.Count) {
+	if len(counts) == 0 {
+		return
+	}
+	printSection(title)
+	for _, c := range counts {
+		fmt.Printf("  %-28s %d highlights in %d bookmark(s)\n", c.Name, c.Highlights, c.Bookmarks)
+	}
+}
+
+func bar(count, maxCount int) string {
+	if maxCount == 0 {
+		return strings.Repeat(" ", barWidth)
+	}
+	width := count * barWidth / maxCount
+	if count > 0 && width == 0 {
+		width = 1
+	}
+	return strings.Repeat("█", width) + strings.Repeat(" ", barWidth-width)
+}
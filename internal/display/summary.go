@@ -10,7 +10,7 @@ import (
 )
 
 func PrintSummary(results []repository.OperationResult, printTiming bool, duration time.Duration) {
-	created, updated, unchanged := 0, 0, 0
+	created, updated, unchanged, failed, vetoed := 0, 0, 0, 0, 0
 	totalHighlights, newHighlights := 0, 0
 
 	for _, r := range results {
@@ -21,6 +21,12 @@ func PrintSummary(results []repository.OperationResult, printTiming bool, durati
 			updated++
 		case "unchanged":
 			unchanged++
+		case "failed":
+			failed++
+			continue
+		case "vetoed":
+			vetoed++
+			continue
 		}
 
 		totalHighlights += len(r.Note.Highlights)
@@ -36,6 +42,13 @@ func PrintSummary(results []repository.OperationResult, printTiming bool, durati
 		UpdatedColor(fmt.Sprintf("%d", updated)),
 		UnchangedColor(fmt.Sprintf("%d", unchanged)))
 
+	if failed > 0 {
+		fmt.Printf("Failed: %s\n", Red(fmt.Sprintf("%d notes", failed)))
+	}
+	if vetoed > 0 {
+		fmt.Printf("Vetoed by a hook: %s\n", UpdatedColor(fmt.Sprintf("%d notes", vetoed)))
+	}
+
 	if newHighlights > 0 {
 		fmt.Printf("Total highlights: %d (%s new added)\n",
 			totalHighlights,
@@ -45,16 +58,17 @@ func PrintSummary(results []repository.OperationResult, printTiming bool, durati
 	}
 
 	if printTiming {
-		var timeStr string
-		if duration.Seconds() < 10 {
-			timeStr = fmt.Sprintf("%dms", duration.Milliseconds())
-		} else {
-			timeStr = fmt.Sprintf("%.2fs", duration.Seconds())
-		}
-		fmt.Printf("Time: %s\n", TimeColor(timeStr))
+		fmt.Printf("Time: %s\n", TimeColor(formatDuration(duration)))
 	}
 }
 
+func formatDuration(duration time.Duration) string {
+	if duration.Seconds() < 10 {
+		return fmt.Sprintf("%dms", duration.Milliseconds())
+	}
+	return fmt.Sprintf("%.2fs", duration.Seconds())
+}
+
 func PrintDetails(results []repository.OperationResult) {
 	fmt.Println("\n" + HeaderColor("Notes Detail"))
 	fmt.Println(HeaderColor("==================================="))
@@ -63,6 +77,8 @@ func PrintDetails(results []repository.OperationResult) {
 	createdNotes := filterByType(results, "created")
 	updatedNotes := filterByType(results, "updated")
 	unchangedNotes := filterByType(results, "unchanged")
+	failedNotes := filterByType(results, "failed")
+	vetoedNotes := filterByType(results, "vetoed")
 
 	// Print created notes first
 	if len(createdNotes) > 0 {
@@ -90,6 +106,32 @@ func PrintDetails(results []repository.OperationResult) {
 			fmt.Println("")
 		}
 	}
+
+	// Print the failed notes last, with what went wrong
+	if len(failedNotes) > 0 {
+		fmt.Println(Red("❌ Failed:"))
+		for _, r := range failedNotes {
+			printNoteDetail(r, false)
+			if r.Note.Path != "" {
+				fmt.Printf("    Path: %s\n", r.Note.Path)
+			}
+			fmt.Printf("    Error: %v\n", r.Err)
+			fmt.Println("")
+		}
+	}
+
+	// The notes a hook didn't want written, with the hook that vetoed them
+	if len(vetoedNotes) > 0 {
+		fmt.Println(UpdatedColor("🚫 Vetoed:"))
+		for _, r := range vetoedNotes {
+			printNoteDetail(r, false)
+			if r.Note.Path != "" {
+				fmt.Printf("    Path: %s\n", r.Note.Path)
+			}
+			fmt.Printf("    Reason: %v\n", r.Err)
+			fmt.Println("")
+		}
+	}
 }
 
 func filterByType(results []repository.OperationResult, opType string) []repository.OperationResult {
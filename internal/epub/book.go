@@ -0,0 +1,126 @@
+package epub
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+// Book is a digest of highlights, with a chapter per bookmark
+type Book struct {
+	Identifier string
+	Title      string
+	Language   string
+	Created    time.Time
+	// Authors are the authors of all bookmarks, in order of appearance
+	Authors  []string
+	Chapters []Chapter
+}
+
+type Chapter struct {
+	ID        string
+	Path      string
+	Title     string
+	Authors   []string
+	URL       string
+	Published time.Time
+	Sections  []Section
+}
+
+// Section is a group of highlights within a chapter, by default a highlight colour
+type Section struct {
+	ID         string
+	Href       string
+	Title      string
+	Highlights []Highlight
+}
+
+type Highlight struct {
+	Color string
+	Lines []string
+}
+
+// NewBook builds the digest of the notes, sorted by title. The identifier is derived from
+// the highlights, so exporting the same selection again gives the same book.
+func NewBook(notes []model.Note, formatter *repository.HighlightFormatter, title string, created time.Time) Book {
+	sorted := make([]model.Note, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Bookmark.Title) < strings.ToLower(sorted[j].Bookmark.Title)
+	})
+
+	book := Book{
+		Title:    title,
+		Language: "en",
+		Created:  created,
+	}
+
+	hash := sha1.New()
+	seenAuthors := make(map[string]bool)
+
+	for i, note := range sorted {
+		b := note.Bookmark
+		chapter := Chapter{
+			ID:        fmt.Sprintf("chapter-%03d", i+1),
+			Title:     b.Title,
+			Authors:   b.Authors,
+			URL:       b.SiteUrl,
+			Published: b.Published,
+		}
+		chapter.Path = chapter.ID + ".xhtml"
+
+		for _, author := range b.Authors {
+			if !seenAuthors[author] {
+				seenAuthors[author] = true
+				book.Authors = append(book.Authors, author)
+			}
+		}
+
+		for j, group := range formatter.GroupHighlights(b.Type, note.Highlights) {
+			section := Section{
+				ID:    fmt.Sprintf("section-%d", j+1),
+				Title: group.Title,
+			}
+			section.Href = chapter.Path + "#" + section.ID
+
+			for _, h := range group.Highlights {
+				hash.Write([]byte(h.ID))
+				section.Highlights = append(section.Highlights, Highlight{
+					Color: util.Slug(h.Color),
+					Lines: lines(h.Text),
+				})
+			}
+			chapter.Sections = append(chapter.Sections, section)
+		}
+
+		book.Chapters = append(book.Chapters, chapter)
+	}
+
+	book.Identifier = uuidURN(hash.Sum(nil))
+	return book
+}
+
+func lines(text string) []string {
+	var result []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// uuidURN formats a name based (version 5 style) UUID from the hash
+func uuidURN(sum []byte) string {
+	b := make([]byte, 16)
+	copy(b, sum)
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,130 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotes() []model.Note {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+
+	return []model.Note{
+		{
+			Bookmark: readdeck.Bookmark{ID: "b2", Title: "Zen & the art", Created: created, Type: "article"},
+			Highlights: []readdeck.Highlight{
+				{ID: "h3", Text: "Something <else>", Color: "blue"},
+			},
+		},
+		{
+			Bookmark: readdeck.Bookmark{
+				ID:        "b1",
+				Title:     "Schlep Blindness",
+				Created:   created,
+				Published: time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC),
+				SiteUrl:   "https://www.paulgraham.com/schlep.html?a=1&b=2",
+				Authors:   []string{"Paul Graham"},
+				Type:      "article",
+			},
+			Highlights: []readdeck.Highlight{
+				{ID: "h1", Text: "Schleps are hard\n\nreally", Color: "yellow", StartSelector: "p[1]"},
+				{ID: "h2", Text: "A takeaway", Color: "green", StartSelector: "p[2]"},
+			},
+		},
+	}
+}
+
+func readArchive(t *testing.T, data []byte) (*zip.Reader, map[string]string) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return reader, files
+}
+
+func TestWrite(t *testing.T) {
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	book := NewBook(testNotes(), formatter, "March highlights", time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC))
+
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, book))
+
+	reader, files := readArchive(t, buffer.Bytes())
+
+	mimetypeFile := reader.File[0]
+	assert.Equal(t, "mimetype", mimetypeFile.Name)
+	assert.Equal(t, zip.Store, mimetypeFile.Method)
+	assert.Empty(t, mimetypeFile.Extra)
+	assert.Equal(t, "application/epub+zip", files["mimetype"])
+	// Readers sniff the file type from the fixed offset of the first entry
+	assert.Equal(t, "mimetypeapplication/epub+zip", string(buffer.Bytes()[30:58]))
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".opf") ||
+			strings.HasSuffix(name, ".xhtml") || strings.HasSuffix(name, ".ncx") {
+			assert.True(t, strings.HasPrefix(content, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"), name)
+			decoder := xml.NewDecoder(strings.NewReader(content))
+			for {
+				_, err := decoder.Token()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err, "%s is not well-formed", name)
+			}
+		}
+	}
+
+	opf := files["OEBPS/content.opf"]
+	assert.Contains(t, opf, `<dc:identifier id="book-id">`+book.Identifier+`</dc:identifier>`)
+	assert.Contains(t, opf, "<dc:title>March highlights</dc:title>")
+	assert.Contains(t, opf, "<dc:creator>Paul Graham</dc:creator>")
+	assert.Contains(t, opf, "<dc:source>https://www.paulgraham.com/schlep.html?a=1&amp;b=2</dc:source>")
+	assert.Contains(t, opf, `<meta property="dcterms:modified">2025-04-01T08:00:00Z</meta>`)
+	assert.Contains(t, opf, `<item id="chapter-001" href="chapter-001.xhtml" media-type="application/xhtml+xml"/>`)
+
+	// Chapters are sorted by title
+	nav := files["OEBPS/nav.xhtml"]
+	assert.Less(t, strings.Index(nav, "Schlep Blindness"), strings.Index(nav, "Zen &amp; the art"))
+	assert.Contains(t, nav, `<a href="chapter-001.xhtml#section-1">Key takeaways</a>`)
+	assert.Contains(t, files["OEBPS/toc.ncx"], `<navPoint id="nav-chapter-002" playOrder="2">`)
+
+	chapter := files["OEBPS/chapter-001.xhtml"]
+	assert.Contains(t, chapter, "<dt>Authors</dt><dd>Paul Graham</dd>")
+	assert.Contains(t, chapter, "<dt>Published</dt><dd>2012-01-01</dd>")
+	assert.Contains(t, chapter, `<blockquote class="highlight color-yellow"><p>Schleps are hard</p><p>really</p></blockquote>`)
+	assert.Less(t, strings.Index(chapter, "Key takeaways"), strings.Index(chapter, "General highlights"))
+
+	assert.Contains(t, files["OEBPS/chapter-002.xhtml"], "<p>Something &lt;else&gt;</p>")
+	assert.NotContains(t, files["OEBPS/chapter-002.xhtml"], "<dl")
+}
+
+func TestNewBook_Identifier(t *testing.T) {
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	notes := testNotes()
+
+	first := NewBook(notes, formatter, "Digest", time.Now())
+	second := NewBook(notes, formatter, "Digest", time.Now().Add(time.Hour))
+	assert.Equal(t, first.Identifier, second.Identifier)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first.Identifier)
+
+	notes[0].Highlights = append(notes[0].Highlights, readdeck.Highlight{ID: "h4", Text: "New", Color: "red"})
+	assert.NotEqual(t, first.Identifier, NewBook(notes, formatter, "Digest", time.Now()).Identifier)
+}
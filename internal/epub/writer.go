@@ -0,0 +1,152 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+const mimetype = "application/epub+zip"
+
+var templates = template.Must(template.New("epub").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+}).ParseFS(templateFiles, "templates/*.opf", "templates/*.xhtml", "templates/*.ncx"))
+
+type chapterData struct {
+	Language string
+	Chapter  Chapter
+}
+
+// Write packages the book as an EPUB 3 file, with an EPUB 2 table of contents for older readers
+func Write(w io.Writer, book Book) error {
+	archive := zip.NewWriter(w)
+
+	// The mimetype has to come first and be stored as is, so readers can sniff the file type
+	if err := writeMimetype(archive, book.Created); err != nil {
+		return err
+	}
+
+	files, err := bookFiles(book)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := writeFile(archive, file.path, file.content, book.Created); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("could not write epub: %w", err)
+	}
+	return nil
+}
+
+type file struct {
+	path    string
+	content []byte
+}
+
+func bookFiles(book Book) ([]file, error) {
+	var files []file
+
+	for _, asset := range []struct{ path, name string }{
+		{"META-INF/container.xml", "container.xml"},
+		{"OEBPS/style.css", "style.css"},
+	} {
+		content, err := templateFiles.ReadFile("templates/" + asset.name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", asset.name, err)
+		}
+		files = append(files, file{asset.path, content})
+	}
+
+	for _, name := range []string{"content.opf", "nav.xhtml", "toc.ncx"} {
+		content, err := render(name, book)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{"OEBPS/" + name, content})
+	}
+
+	for _, chapter := range book.Chapters {
+		content, err := render("chapter.xhtml", chapterData{Language: book.Language, Chapter: chapter})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{"OEBPS/" + chapter.Path, content})
+	}
+
+	return files, nil
+}
+
+func writeMimetype(archive *zip.Writer, modified time.Time) error {
+	content := []byte(mimetype)
+	date, clock := msDosTime(modified)
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: uint64(len(content)),
+		ModifiedDate:       date,
+		ModifiedTime:       clock,
+	}
+
+	// CreateRaw doesn't add a data descriptor or extra fields, which strict EPUB checkers reject for the mimetype
+	w, err := archive.CreateRaw(header)
+	if err != nil {
+		return fmt.Errorf("could not write mimetype: %w", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("could not write mimetype: %w", err)
+	}
+	return nil
+}
+
+// msDosTime encodes the time for the zip header, CreateRaw leaves that to the caller
+func msDosTime(t time.Time) (date uint16, clock uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+func writeFile(archive *zip.Writer, path string, content []byte, modified time.Time) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     path,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// render prepends the XML declaration, html/template would escape it in the templates
+func render(name string, data interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	if err := templates.ExecuteTemplate(&buffer, name, data); err != nil {
+		return nil, fmt.Errorf("could not render %s: %w", name, err)
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// SchemaVersion is bumped whenever a field is renamed or removed.
+// Adding a field is not a breaking change and keeps the version.
+const SchemaVersion = 1
+
+// Document is the JSON export: every bookmark with its highlights
+type Document struct {
+	SchemaVersion int        `json:"schema_version"`
+	ExportedAt    time.Time  `json:"exported_at"`
+	Bookmarks     []Bookmark `json:"bookmarks"`
+}
+
+// Bookmark is a single line of the NDJSON export
+type Bookmark struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	URL         string      `json:"url"`
+	Href        string      `json:"href"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Authors     []string    `json:"authors"`
+	Labels      []string    `json:"labels"`
+	Created     time.Time   `json:"created"`
+	Published   *time.Time  `json:"published,omitempty"`
+	Highlights  []Highlight `json:"highlights"`
+}
+
+type Highlight struct {
+	ID      string    `json:"id"`
+	Text    string    `json:"text"`
+	Color   string    `json:"color"`
+	Created time.Time `json:"created"`
+	Href    string    `json:"href"`
+	// Chapter is only set when highlights are grouped by section
+	Chapter string `json:"chapter,omitempty"`
+}
+
+// NewDocument converts the notes to the export schema. Bookmarks are ordered by creation,
+// highlights within a bookmark follow the given order, so repeated exports are diffable.
+func NewDocument(notes []model.Note, order readdeck.HighlightOrder, exportedAt time.Time) Document {
+	bookmarks := make([]Bookmark, 0, len(notes))
+	for _, note := range notes {
+		bookmarks = append(bookmarks, newBookmark(note, order))
+	}
+
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		if !bookmarks[i].Created.Equal(bookmarks[j].Created) {
+			return bookmarks[i].Created.Before(bookmarks[j].Created)
+		}
+		return bookmarks[i].ID < bookmarks[j].ID
+	})
+
+	return Document{
+		SchemaVersion: SchemaVersion,
+		ExportedAt:    exportedAt.UTC(),
+		Bookmarks:     bookmarks,
+	}
+}
+
+func newBookmark(note model.Note, order readdeck.HighlightOrder) Bookmark {
+	b := note.Bookmark
+
+	result := Bookmark{
+		ID:          b.ID,
+		Title:       b.Title,
+		URL:         b.SiteUrl,
+		Href:        b.Href,
+		Type:        b.Type,
+		Description: b.Description,
+		Authors:     nonNil(b.Authors),
+		Labels:      nonNil(b.Labels),
+		Created:     b.Created.UTC(),
+		Highlights:  make([]Highlight, 0, len(note.Highlights)),
+	}
+
+	if !b.Published.IsZero() {
+		published := b.Published.UTC()
+		result.Published = &published
+	}
+
+	for _, h := range readdeck.SortHighlights(note.Highlights, order) {
+		result.Highlights = append(result.Highlights, Highlight{
+			ID:      h.ID,
+			Text:    h.Text,
+			Color:   h.Color,
+			Created: h.Created.UTC(),
+			Href:    h.Href,
+			Chapter: h.Chapter,
+		})
+	}
+
+	return result
+}
+
+// nonNil makes sure lists are written as [] instead of null
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
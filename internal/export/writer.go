@@ -0,0 +1,116 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	// JSON writes a single Document
+	JSON Format = "json"
+	// NDJSON writes a Bookmark per line
+	NDJSON Format = "ndjson"
+	// CSV writes a row per highlight, the bookmark columns are repeated
+	CSV Format = "csv"
+)
+
+// ParseFormat returns the export format, ok is false when the input is not a data format
+func ParseFormat(input string) (Format, bool) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(input))); format {
+	case JSON, NDJSON, CSV:
+		return format, true
+	default:
+		return "", false
+	}
+}
+
+// CSVHeader lists the columns of the CSV export, in order
+var CSVHeader = []string{
+	"bookmark_id",
+	"bookmark_title",
+	"bookmark_url",
+	"bookmark_type",
+	"bookmark_authors",
+	"bookmark_labels",
+	"highlight_id",
+	"highlight_text",
+	"highlight_color",
+	"highlight_created",
+	"highlight_href",
+	"highlight_chapter",
+}
+
+func Write(w io.Writer, format Format, document Document) error {
+	switch format {
+	case JSON:
+		return WriteJSON(w, document)
+	case NDJSON:
+		return WriteNDJSON(w, document)
+	case CSV:
+		return WriteCSV(w, document)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func WriteJSON(w io.Writer, document Document) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("could not write json: %w", err)
+	}
+	return nil
+}
+
+func WriteNDJSON(w io.Writer, document Document) error {
+	encoder := json.NewEncoder(w)
+	for _, bookmark := range document.Bookmarks {
+		if err := encoder.Encode(bookmark); err != nil {
+			return fmt.Errorf("could not write bookmark %s: %w", bookmark.ID, err)
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes a row per highlight. Lists are joined with "; ".
+func WriteCSV(w io.Writer, document Document) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(CSVHeader); err != nil {
+		return fmt.Errorf("could not write csv header: %w", err)
+	}
+
+	for _, b := range document.Bookmarks {
+		for _, h := range b.Highlights {
+			record := []string{
+				b.ID,
+				b.Title,
+				b.URL,
+				b.Type,
+				strings.Join(b.Authors, "; "),
+				strings.Join(b.Labels, "; "),
+				h.ID,
+				h.Text,
+				h.Color,
+				h.Created.Format(time.RFC3339),
+				h.Href,
+				h.Chapter,
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("could not write highlight %s: %w", h.ID, err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("could not write csv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocument() Document {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+
+	notes := []model.Note{
+		{
+			Bookmark: readdeck.Bookmark{ID: "b2", Title: "Later", Created: created.Add(time.Hour), Type: "video"},
+			Highlights: []readdeck.Highlight{
+				{ID: "h3", Text: "Only one", Color: "blue", Created: created},
+			},
+		},
+		{
+			Bookmark: readdeck.Bookmark{
+				ID:        "b1",
+				Title:     "Schlep Blindness",
+				Created:   created,
+				Published: created.Add(-24 * time.Hour),
+				SiteUrl:   "https://www.paulgraham.com/schlep.html",
+				Type:      "article",
+				Authors:   []string{"Paul Graham"},
+				Labels:    []string{"startups", "essays"},
+			},
+			Highlights: []readdeck.Highlight{
+				{ID: "h2", Text: "Second, \"quoted\"", Color: "green", Created: created, StartSelector: "p[2]", Href: "https://read.example.com/api/h2"},
+				{ID: "h1", Text: "First\nline", Color: "yellow", Created: created, StartSelector: "p[1]", Chapter: "Intro"},
+			},
+		},
+	}
+
+	return NewDocument(notes, readdeck.OrderPosition, created)
+}
+
+func TestNewDocument(t *testing.T) {
+	document := testDocument()
+
+	assert.Equal(t, SchemaVersion, document.SchemaVersion)
+	require.Len(t, document.Bookmarks, 2)
+	assert.Equal(t, "b1", document.Bookmarks[0].ID)
+	assert.Equal(t, "b2", document.Bookmarks[1].ID)
+	assert.Equal(t, "h1", document.Bookmarks[0].Highlights[0].ID)
+	assert.Equal(t, "h2", document.Bookmarks[0].Highlights[1].ID)
+	assert.NotNil(t, document.Bookmarks[0].Published)
+	assert.Nil(t, document.Bookmarks[1].Published)
+	assert.Equal(t, []string{}, document.Bookmarks[1].Authors)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, JSON, testDocument()))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	assert.Equal(t, float64(SchemaVersion), decoded["schema_version"])
+	assert.Equal(t, "2025-03-23T20:16:00Z", decoded["exported_at"])
+
+	bookmarks := decoded["bookmarks"].([]interface{})
+	first := bookmarks[0].(map[string]interface{})
+	assert.Equal(t, "https://www.paulgraham.com/schlep.html", first["url"])
+	assert.Equal(t, "2025-03-22T20:16:00Z", first["published"])
+
+	second := bookmarks[1].(map[string]interface{})
+	assert.NotContains(t, second, "published")
+	assert.Equal(t, []interface{}{}, second["labels"])
+
+	highlight := first["highlights"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Intro", highlight["chapter"])
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, NDJSON, testDocument()))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var bookmark Bookmark
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &bookmark))
+	assert.Equal(t, "b2", bookmark.ID)
+	assert.Len(t, bookmark.Highlights, 1)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, CSV, testDocument()))
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, CSVHeader, records[0])
+	assert.Equal(t, []string{
+		"b1", "Schlep Blindness", "https://www.paulgraham.com/schlep.html", "article", "Paul Graham", "startups; essays",
+		"h1", "First\nline", "yellow", "2025-03-23T20:16:00Z", "", "Intro",
+	}, records[1])
+	assert.Equal(t, "Second, \"quoted\"", records[2][7])
+	assert.Equal(t, "b2", records[3][0])
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		ok       bool
+	}{
+		{input: "json", expected: JSON, ok: true},
+		{input: "NDJSON", expected: NDJSON, ok: true},
+		{input: " csv ", expected: CSV, ok: true},
+		{input: "markdown", ok: false},
+		{input: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			format, ok := ParseFormat(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
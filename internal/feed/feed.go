@@ -0,0 +1,249 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+const generator = "readdeck-highlight-exporter"
+
+// Feed is an Atom feed of new highlights, an entry per bookmark and export run
+type Feed struct {
+	XMLName   xml.Name  `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string    `xml:"id"`
+	Title     string    `xml:"title"`
+	Updated   time.Time `xml:"updated"`
+	Author    Person    `xml:"author"`
+	Links     []Link    `xml:"link"`
+	Generator string    `xml:"generator,omitempty"`
+	Entries   []Entry   `xml:"entry"`
+}
+
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    time.Time  `xml:"updated"`
+	Links      []Link     `xml:"link"`
+	Categories []Category `xml:"category"`
+	Content    Content    `xml:"content"`
+}
+
+type Person struct {
+	Name string `xml:"name"`
+}
+
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type Category struct {
+	Term string `xml:"term,attr"`
+}
+
+type Content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+var contentTemplate = template.Must(template.New("content").Parse(
+	`{{if .Authors}}<p>{{range $i, $a := .Authors}}{{if $i}}, {{end}}{{$a}}{{end}}</p>{{end}}` +
+		`{{range .Groups}}<h3>{{.Title}}</h3>{{range .Highlights}}<blockquote>{{.Text}}</blockquote>{{end}}{{end}}`))
+
+type Options struct {
+	Title string
+	// Link is the Readdeck instance, it identifies the feed
+	Link string
+	// Retention is the number of entries to keep, 0 keeps all of them
+	Retention int
+}
+
+// Update adds the entries to the feed at path. The file is only rewritten when there are new entries,
+// or when it doesn't exist yet.
+func Update(path string, entries []Entry, opts Options, at time.Time) error {
+	feed, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 && feed.ID != "" {
+		return nil
+	}
+
+	if feed.ID == "" {
+		feed.ID = fmt.Sprintf("urn:readdeck:feed:%x", sha1.Sum([]byte(opts.Link)))
+	}
+	feed.Title = opts.Title
+	feed.Links = nil
+	if opts.Link != "" {
+		feed.Links = []Link{{Href: opts.Link, Rel: "alternate"}}
+	}
+	feed.Updated = at
+	feed.Add(entries, opts.Retention)
+
+	return feed.Save(path)
+}
+
+// Load reads the feed at path, a missing file gives an empty feed
+func Load(path string) (*Feed, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Feed{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read feed: %w", err)
+	}
+
+	var feed Feed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("could not read feed %s: %w", path, err)
+	}
+	return &feed, nil
+}
+
+// NewEntries makes an entry per bookmark with new highlights. A highlight can be in several results,
+// eg. in the note of its bookmark and its atomic note, but it is only listed once.
+func NewEntries(results []repository.OperationResult, formatter *repository.HighlightFormatter, at time.Time) ([]Entry, error) {
+	seen := make(map[string]bool)
+	bookmarks := make(map[string]readdeck.Bookmark)
+	highlights := make(map[string][]readdeck.Highlight)
+	var order []string
+
+	for _, r := range results {
+		for _, h := range r.NewHighlights {
+			if seen[h.ID] {
+				continue
+			}
+			seen[h.ID] = true
+
+			id := r.Note.Bookmark.ID
+			if _, ok := bookmarks[id]; !ok {
+				bookmarks[id] = r.Note.Bookmark
+				order = append(order, id)
+			}
+			highlights[id] = append(highlights[id], h)
+		}
+	}
+
+	entries := make([]Entry, 0, len(order))
+	for _, id := range order {
+		entry, err := newEntry(bookmarks[id], highlights[id], formatter, at)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func newEntry(b readdeck.Bookmark, highlights []readdeck.Highlight, formatter *repository.HighlightFormatter, at time.Time) (Entry, error) {
+	title := b.Title
+	if len(highlights) > 1 {
+		title = fmt.Sprintf("%s (%d highlights)", b.Title, len(highlights))
+	}
+
+	entry := Entry{
+		ID:      EntryID(highlights),
+		Title:   title,
+		Updated: at,
+	}
+	if b.SiteUrl != "" {
+		entry.Links = []Link{{Href: b.SiteUrl, Rel: "alternate"}}
+	}
+	for _, label := range b.Labels {
+		entry.Categories = append(entry.Categories, Category{Term: label})
+	}
+
+	var content bytes.Buffer
+	data := struct {
+		Authors []string
+		Groups  []repository.HighlightGroup
+	}{
+		Authors: b.Authors,
+		Groups:  formatter.GroupHighlights(b.Type, highlights),
+	}
+	if err := contentTemplate.Execute(&content, data); err != nil {
+		return Entry{}, fmt.Errorf("could not render feed entry for %s: %w", b.ID, err)
+	}
+	entry.Content = Content{Type: "html", Body: content.String()}
+
+	return entry, nil
+}
+
+// EntryID only depends on the highlights, so the same highlights always give the same entry
+func EntryID(highlights []readdeck.Highlight) string {
+	if len(highlights) == 1 {
+		return "urn:readdeck:highlight:" + highlights[0].ID
+	}
+
+	ids := make([]string, 0, len(highlights))
+	for _, h := range highlights {
+		ids = append(ids, h.ID)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("urn:readdeck:highlights:%x", sha1.Sum([]byte(strings.Join(ids, ","))))
+}
+
+// Add puts the entries on top, replacing entries with the same ID,
+// and keeps the newest retention entries
+func (f *Feed) Add(entries []Entry, retention int) {
+	added := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		added[e.ID] = true
+	}
+
+	merged := append([]Entry{}, entries...)
+	for _, e := range f.Entries {
+		if !added[e.ID] {
+			merged = append(merged, e)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Updated.After(merged[j].Updated)
+	})
+	if retention > 0 && len(merged) > retention {
+		merged = merged[:retention]
+	}
+	f.Entries = merged
+}
+
+// Save writes the feed to a temporary file next to it and renames it,
+// so a web server never serves a half written feed
+func (f *Feed) Save(path string) error {
+	f.Generator = generator
+	if f.Author.Name == "" {
+		f.Author.Name = "Readdeck"
+	}
+
+	data, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode feed: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create feed directory: %w", err)
+	}
+
+	// The feed is meant to be served, so it is readable by all
+	if err := util.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("could not write feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package feed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFormatter() *repository.HighlightFormatter {
+	return repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+}
+
+func TestNewEntries(t *testing.T) {
+	at := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+	bookmark := readdeck.Bookmark{
+		ID:      "b1",
+		Title:   "Schlep Blindness",
+		SiteUrl: "https://www.paulgraham.com/schlep.html",
+		Authors: []string{"Paul Graham"},
+		Labels:  []string{"startups"},
+	}
+	h1 := readdeck.Highlight{ID: "h1", Text: "Schleps <are> hard", Color: "yellow"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "A takeaway", Color: "green"}
+	h3 := readdeck.Highlight{ID: "h3", Text: "Alone", Color: "yellow"}
+
+	results := []repository.OperationResult{
+		{Type: "updated", Note: model.Note{Bookmark: bookmark}, NewHighlights: []readdeck.Highlight{h1, h2}},
+		// The atomic note of a highlight that is already in the entry above
+		{Type: "created", Note: model.Note{Bookmark: bookmark}, NewHighlights: []readdeck.Highlight{h1}},
+		{Type: "unchanged", Note: model.Note{Bookmark: readdeck.Bookmark{ID: "b2"}}},
+		{Type: "created", Note: model.Note{Bookmark: readdeck.Bookmark{ID: "b3", Title: "Other"}}, NewHighlights: []readdeck.Highlight{h3}},
+	}
+
+	entries, err := NewEntries(results, newFormatter(), at)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	first := entries[0]
+	assert.Equal(t, EntryID([]readdeck.Highlight{h2, h1}), first.ID)
+	assert.Equal(t, "Schlep Blindness (2 highlights)", first.Title)
+	assert.Equal(t, at, first.Updated)
+	assert.Equal(t, []Link{{Href: "https://www.paulgraham.com/schlep.html", Rel: "alternate"}}, first.Links)
+	assert.Equal(t, []Category{{Term: "startups"}}, first.Categories)
+	assert.Equal(t, "html", first.Content.Type)
+	assert.Equal(t, "<p>Paul Graham</p><h3>Key takeaways</h3><blockquote>A takeaway</blockquote>"+
+		"<h3>General highlights</h3><blockquote>Schleps &lt;are&gt; hard</blockquote>", first.Content.Body)
+
+	assert.Equal(t, "urn:readdeck:highlight:h3", entries[1].ID)
+	assert.Equal(t, "Other", entries[1].Title)
+	assert.Empty(t, entries[1].Links)
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public", "highlights.xml")
+	opts := Options{Title: "Team highlights", Link: "https://read.example.com", Retention: 3}
+	day := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+
+	entry := func(id string, at time.Time) Entry {
+		return Entry{ID: id, Title: id, Updated: at, Content: Content{Type: "html", Body: "<p>" + id + "</p>"}}
+	}
+
+	// The feed is created, even without entries
+	require.NoError(t, Update(path, nil, opts, day))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	for i := 1; i <= 4; i++ {
+		at := day.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, Update(path, []Entry{entry(fmt.Sprintf("e%d", i), at)}, opts, at))
+	}
+
+	feed, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Team highlights", feed.Title)
+	assert.Equal(t, day.Add(4*time.Hour), feed.Updated)
+	assert.Equal(t, "Readdeck", feed.Author.Name)
+	require.Len(t, feed.Entries, 3)
+	assert.Equal(t, []string{"e4", "e3", "e2"}, []string{feed.Entries[0].ID, feed.Entries[1].ID, feed.Entries[2].ID})
+	assert.Equal(t, "<p>e4</p>", feed.Entries[0].Content.Body)
+	id := feed.ID
+
+	// Nothing new leaves the file alone
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, Update(path, nil, opts, day.Add(48*time.Hour)))
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// An entry with the same ID replaces the old one
+	require.NoError(t, Update(path, []Entry{entry("e3", day.Add(5*time.Hour))}, opts, day.Add(5*time.Hour)))
+	feed, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, id, feed.ID)
+	assert.Equal(t, []string{"e3", "e4", "e2"}, []string{feed.Entries[0].ID, feed.Entries[1].ID, feed.Entries[2].ID})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, string(data), `<link href="https://read.example.com" rel="alternate"></link>`)
+
+	leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
@@ -0,0 +1,170 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnrelatedChanges is returned when the index holds changes the export didn't make,
+// committing would sweep them into the export commit
+var ErrUnrelatedChanges = errors.New("the repository has unrelated staged changes")
+
+// Repository is a git working tree, driven through the git binary on the PATH
+type Repository struct {
+	root string
+}
+
+type CommitResult struct {
+	// Files is the number of files in the commit, zero when nothing changed
+	Files int
+	Hash  string
+}
+
+// Open finds the working tree the directory is in
+func Open(ctx context.Context, dir string) (*Repository, error) {
+	root, err := run(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(strings.TrimSpace(root))
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{root: resolved}, nil
+}
+
+func (r *Repository) Root() string {
+	return r.root
+}
+
+// Commit stages exactly the given files and commits them. It refuses to run when other
+// changes are staged already, or when a file is outside of the working tree.
+func (r *Repository) Commit(ctx context.Context, paths []string, message string) (CommitResult, error) {
+	files, err := r.relativePaths(paths)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if len(files) == 0 {
+		return CommitResult{}, nil
+	}
+
+	staged, err := r.staged(ctx)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	wanted := make(map[string]bool, len(files))
+	for _, file := range files {
+		wanted[file] = true
+	}
+	var unrelated []string
+	for _, file := range staged {
+		if !wanted[file] {
+			unrelated = append(unrelated, file)
+		}
+	}
+	if len(unrelated) > 0 {
+		return CommitResult{}, fmt.Errorf("%w: %s", ErrUnrelatedChanges, strings.Join(unrelated, ", "))
+	}
+
+	if _, err := r.git(ctx, nulSeparated(files), "add", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		return CommitResult{}, err
+	}
+
+	staged, err = r.staged(ctx)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if len(staged) == 0 {
+		return CommitResult{}, nil
+	}
+
+	if _, err := r.git(ctx, []byte(message), "commit", "--quiet", "--file=-"); err != nil {
+		return CommitResult{}, err
+	}
+
+	hash, err := r.git(ctx, nil, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return CommitResult{}, err
+	}
+	return CommitResult{Files: len(staged), Hash: strings.TrimSpace(hash)}, nil
+}
+
+// Push pushes the current branch to its upstream
+func (r *Repository) Push(ctx context.Context) error {
+	_, err := r.git(ctx, nil, "push", "--quiet")
+	return err
+}
+
+// staged lists the files in the index that differ from HEAD, relative to the root
+func (r *Repository) staged(ctx context.Context) ([]string, error) {
+	output, err := r.git(ctx, nil, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(output, func(r rune) bool { return r == 0 }), nil
+}
+
+// relativePaths makes the paths relative to the root, in git's slash separated form
+func (r *Repository) relativePaths(paths []string) ([]string, error) {
+	seen := make(map[string]bool, len(paths))
+	files := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		// The note exists, resolving it lines it up with the resolved root
+		if resolved, err := filepath.EvalSymlinks(absolute); err == nil {
+			absolute = resolved
+		}
+
+		relative, err := filepath.Rel(r.root, absolute)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside of the repository %s", path, r.root)
+		}
+
+		relative = filepath.ToSlash(relative)
+		if !seen[relative] {
+			seen[relative] = true
+			files = append(files, relative)
+		}
+	}
+	return files, nil
+}
+
+func (r *Repository) git(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	return run(ctx, r.root, stdin, args...)
+}
+
+func run(ctx context.Context, dir string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, message)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+func nulSeparated(files []string) []byte {
+	var buffer bytes.Buffer
+	for _, file := range files {
+		buffer.WriteString(file)
+		buffer.WriteByte(0)
+	}
+	return buffer.Bytes()
+}
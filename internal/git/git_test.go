@@ -0,0 +1,181 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepository initialises a repository in a temporary directory, isolated from the git configuration of the user
+func testRepository(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not on the PATH")
+	}
+
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Exporter")
+	t.Setenv("GIT_AUTHOR_EMAIL", "exporter@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Exporter")
+	t.Setenv("GIT_COMMITTER_EMAIL", "exporter@example.com")
+
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "--quiet", "--initial-branch=main")
+	return dir
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	require.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, path string, content string) string {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRepository_Commit(t *testing.T) {
+	dir := testRepository(t)
+	ctx := context.Background()
+
+	first := writeFile(t, filepath.Join(dir, "notes", "first.md"), "first")
+	second := writeFile(t, filepath.Join(dir, "notes", "second.md"), "second")
+	writeFile(t, filepath.Join(dir, "notes", "draft.md"), "not exported")
+
+	repo, err := Open(ctx, filepath.Join(dir, "notes"))
+	require.NoError(t, err)
+
+	result, err := repo.Commit(ctx, []string{first, second, first}, "Export\n")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Files)
+	assert.NotEmpty(t, result.Hash)
+
+	assert.Equal(t, "Export", gitCmd(t, dir, "log", "-1", "--format=%s"))
+	assert.Equal(t, "notes/first.md\nnotes/second.md", gitCmd(t, dir, "show", "--name-only", "--format=", "HEAD"))
+	assert.Equal(t, "?? notes/draft.md", gitCmd(t, dir, "status", "--porcelain"))
+
+	// Nothing changed, nothing to commit
+	result, err = repo.Commit(ctx, []string{first, second}, "Export\n")
+	require.NoError(t, err)
+	assert.Equal(t, CommitResult{}, result)
+
+	writeFile(t, second, "second, updated")
+	result, err = repo.Commit(ctx, []string{first, second}, "Update\n")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Files)
+	assert.Equal(t, "notes/second.md", gitCmd(t, dir, "show", "--name-only", "--format=", "HEAD"))
+}
+
+func TestRepository_Commit_UnrelatedChanges(t *testing.T) {
+	dir := testRepository(t)
+	ctx := context.Background()
+
+	note := writeFile(t, filepath.Join(dir, "note.md"), "note")
+	writeFile(t, filepath.Join(dir, "todo.md"), "someone else's work")
+	gitCmd(t, dir, "add", "todo.md")
+
+	repo, err := Open(ctx, dir)
+	require.NoError(t, err)
+
+	_, err = repo.Commit(ctx, []string{note}, "Export\n")
+	assert.ErrorIs(t, err, ErrUnrelatedChanges)
+	assert.ErrorContains(t, err, "todo.md")
+	assert.Equal(t, "A  todo.md\n?? note.md", gitCmd(t, dir, "status", "--porcelain"))
+
+	// A staged note of the export itself is fine
+	gitCmd(t, dir, "reset", "--quiet")
+	gitCmd(t, dir, "add", "note.md")
+	result, err := repo.Commit(ctx, []string{note}, "Export\n")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Files)
+}
+
+func TestRepository_Commit_OutsideRepository(t *testing.T) {
+	dir := testRepository(t)
+	ctx := context.Background()
+
+	outside := writeFile(t, filepath.Join(t.TempDir(), "note.md"), "note")
+
+	repo, err := Open(ctx, dir)
+	require.NoError(t, err)
+
+	_, err = repo.Commit(ctx, []string{outside}, "Export\n")
+	assert.ErrorContains(t, err, "outside of the repository")
+
+	_, err = Open(ctx, filepath.Dir(outside))
+	assert.Error(t, err)
+}
+
+func TestRepository_Push(t *testing.T) {
+	dir := testRepository(t)
+	ctx := context.Background()
+
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "--quiet", "--bare", "--initial-branch=main")
+	gitCmd(t, dir, "remote", "add", "origin", remote)
+
+	repo, err := Open(ctx, dir)
+	require.NoError(t, err)
+
+	note := writeFile(t, filepath.Join(dir, "note.md"), "note")
+	_, err = repo.Commit(ctx, []string{note}, "Export\n")
+	require.NoError(t, err)
+
+	// Without an upstream there is nowhere to push to
+	assert.Error(t, repo.Push(ctx))
+
+	gitCmd(t, dir, "push", "--quiet", "--set-upstream", "origin", "main")
+	writeFile(t, note, "updated")
+	_, err = repo.Commit(ctx, []string{note}, "Update\n")
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(ctx))
+
+	assert.Equal(t, gitCmd(t, dir, "rev-parse", "HEAD"), gitCmd(t, remote, "rev-parse", "main"))
+}
+
+func TestNewMessage(t *testing.T) {
+	bookmark := func(id, title string) readdeck.Bookmark {
+		return readdeck.Bookmark{ID: id, Title: title}
+	}
+	results := []repository.OperationResult{
+		{Type: "created", Note: model.Note{Bookmark: bookmark("b1", "Schlep Blindness")}, HighlightsAdded: 2},
+		// Atomic notes of the same bookmark are listed once
+		{Type: "created", Note: model.Note{Bookmark: bookmark("b1", "Schlep Blindness")}, HighlightsAdded: 1},
+		{Type: "updated", Note: model.Note{Bookmark: bookmark("b2", "How to Do Great Work")}, HighlightsAdded: 1},
+		{Type: "unchanged", Note: model.Note{Bookmark: bookmark("b3", "Untouched")}},
+	}
+	data := NewMessageData(results, time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC))
+	assert.Equal(t, 4, data.HighlightsAdded)
+
+	tmpl, err := ParseMessage("")
+	require.NoError(t, err)
+	message, err := NewMessage(tmpl, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Readdeck export: 2 created, 1 updated\n\nCreated: Schlep Blindness\nUpdated: How to Do Great Work\n", message)
+
+	tmpl, err = ParseMessage(`{{.HighlightsAdded}} highlights on {{.Time.Format "2006-01-02"}}`)
+	require.NoError(t, err)
+	message, err = NewMessage(tmpl, data)
+	require.NoError(t, err)
+	assert.Equal(t, "4 highlights on 2025-03-23\n", message)
+
+	_, err = ParseMessage("{{.Created")
+	assert.Error(t, err)
+
+	tmpl, err = ParseMessage("{{if false}}never{{end}}")
+	require.NoError(t, err)
+	_, err = NewMessage(tmpl, data)
+	assert.Error(t, err)
+}
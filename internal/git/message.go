@@ -0,0 +1,81 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+)
+
+// DefaultMessage is the commit message template used when none is configured
+const DefaultMessage = `Readdeck export: {{.Created}} created, {{.Updated}} updated
+{{range .CreatedTitles}}
+Created: {{.}}{{end}}{{range .UpdatedTitles}}
+Updated: {{.}}{{end}}
+`
+
+// MessageData is what the commit message template has to work with
+type MessageData struct {
+	Created         int
+	Updated         int
+	HighlightsAdded int
+	// CreatedTitles and UpdatedTitles hold the bookmark titles, once per bookmark
+	CreatedTitles []string
+	UpdatedTitles []string
+	Time          time.Time
+}
+
+func NewMessageData(results []repository.OperationResult, at time.Time) MessageData {
+	data := MessageData{Time: at}
+	created := make(map[string]bool)
+	updated := make(map[string]bool)
+
+	for _, r := range results {
+		bookmark := r.Note.Bookmark
+		switch r.Type {
+		case "created":
+			data.Created++
+			if !created[bookmark.ID] {
+				created[bookmark.ID] = true
+				data.CreatedTitles = append(data.CreatedTitles, bookmark.Title)
+			}
+		case "updated":
+			data.Updated++
+			if !updated[bookmark.ID] {
+				updated[bookmark.ID] = true
+				data.UpdatedTitles = append(data.UpdatedTitles, bookmark.Title)
+			}
+		}
+		data.HighlightsAdded += r.HighlightsAdded
+	}
+	return data
+}
+
+// ParseMessage parses a commit message template, an empty one is the default message
+func ParseMessage(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultMessage
+	}
+
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func NewMessage(tmpl *template.Template, data MessageData) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("could not build the commit message: %w", err)
+	}
+
+	message := strings.TrimSpace(buffer.String())
+	if message == "" {
+		return "", fmt.Errorf("the commit message is empty")
+	}
+	return message + "\n", nil
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/hooks"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const (
+	stateName = "history.json"
+	// maxRuns is the number of runs that are kept, the oldest ones are dropped
+	maxRuns = 100
+)
+
+// History records the exports to the vault, so what a run did can be looked up
+// long after its output is gone
+type History struct {
+	NextID int   `json:"next_id"`
+	Runs   []Run `json:"runs"`
+}
+
+type Run struct {
+	ID int `json:"id"`
+	// Trigger is what started the run: export, sync or webhook
+	Trigger  string           `json:"trigger"`
+	Started  time.Time        `json:"started"`
+	Finished time.Time        `json:"finished"`
+	Phases   []progress.Phase `json:"phases"`
+	Counts   Counts           `json:"counts"`
+	// Highlights and HighlightsAdded count the notes that were written or left unchanged
+	Highlights      int `json:"highlights"`
+	HighlightsAdded int `json:"highlights_added"`
+	// Notes are the notes that were created, updated or that failed, unchanged ones are only counted
+	Notes        []Note   `json:"notes"`
+	HookFailures []string `json:"hook_failures,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	Interrupted  bool     `json:"interrupted,omitempty"`
+}
+
+type Counts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+	Vetoed    int `json:"vetoed"`
+}
+
+type Note struct {
+	Type          string `json:"type"`
+	Path          string `json:"path"`
+	BookmarkID    string `json:"bookmark_id"`
+	BookmarkTitle string `json:"bookmark_title"`
+	Highlights    int    `json:"highlights"`
+	// Added are the IDs of the highlights the run added to the note
+	Added []string `json:"added,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// NewRun describes an export from its results, err is the error the export stopped with
+func NewRun(trigger string, started time.Time, finished time.Time, phases []progress.Phase, results []repository.OperationResult, failures []hooks.Failure, err error) Run {
+	run := Run{
+		Trigger:     trigger,
+		Started:     started,
+		Finished:    finished,
+		Phases:      phases,
+		Notes:       []Note{},
+		Interrupted: errors.Is(err, context.Canceled),
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	for _, r := range results {
+		if r.Type != "failed" && r.Type != "vetoed" {
+			run.Highlights += len(r.Note.Highlights)
+			run.HighlightsAdded += r.HighlightsAdded
+		}
+
+		switch r.Type {
+		case "created":
+			run.Counts.Created++
+		case "updated":
+			run.Counts.Updated++
+		case "unchanged":
+			run.Counts.Unchanged++
+			continue
+		case "failed":
+			run.Counts.Failed++
+		case "vetoed":
+			run.Counts.Vetoed++
+		}
+
+		note := Note{
+			Type:          r.Type,
+			Path:          r.Note.Path,
+			BookmarkID:    r.Note.Bookmark.ID,
+			BookmarkTitle: r.Note.Bookmark.Title,
+			Highlights:    len(r.Note.Highlights),
+		}
+		for _, h := range r.NewHighlights {
+			note.Added = append(note.Added, h.ID)
+		}
+		if r.Err != nil {
+			note.Error = r.Err.Error()
+		}
+		run.Notes = append(run.Notes, note)
+	}
+
+	for _, f := range failures {
+		run.HookFailures = append(run.HookFailures, f.Error())
+	}
+	return run
+}
+
+func (r Run) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// Failed tells whether the run stopped with an error, or some of its notes failed
+func (r Run) Failed() bool {
+	return (r.Error != "" && !r.Interrupted) || r.Counts.Failed > 0
+}
+
+// Touches tells whether the run wrote, or failed to write, a note with the path in its path
+func (r Run) Touches(path string) bool {
+	for _, note := range r.Notes {
+		if note.Path != "" && strings.Contains(note.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func Load(store *state.Store) (History, error) {
+	h := History{NextID: 1}
+	if err := store.Load(stateName, &h); err != nil {
+		return History{}, err
+	}
+	return h, nil
+}
+
+func (h History) Save(store *state.Store) error {
+	return store.Save(stateName, h)
+}
+
+// Add numbers the run and appends it, dropping the oldest runs past maxRuns
+func (h *History) Add(run Run) Run {
+	if h.NextID < 1 {
+		h.NextID = 1
+	}
+	run.ID = h.NextID
+	h.NextID++
+
+	h.Runs = append(h.Runs, run)
+	if len(h.Runs) > maxRuns {
+		h.Runs = h.Runs[len(h.Runs)-maxRuns:]
+	}
+	return run
+}
+
+func (h History) Find(id int) (Run, bool) {
+	for _, run := range h.Runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// Latest returns the most recent runs first, those that touched the path when it isn't empty
+func (h History) Latest(path string, limit int) []Run {
+	result := []Run{}
+	for i := len(h.Runs) - 1; i >= 0; i-- {
+		if path != "" && !h.Runs[i].Touches(path) {
+			continue
+		}
+		result = append(result, h.Runs[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/hooks"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRun(t *testing.T) {
+	started := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	h1 := readdeck.Highlight{ID: "h1"}
+	h2 := readdeck.Highlight{ID: "h2"}
+
+	results := []repository.OperationResult{
+		{
+			Type:            "updated",
+			Note:            model.Note{Path: "/notes/schlep.md", Bookmark: readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness"}, Highlights: []readdeck.Highlight{h1, h2}},
+			HighlightsAdded: 1,
+			NewHighlights:   []readdeck.Highlight{h2},
+		},
+		{Type: "unchanged", Note: model.Note{Path: "/notes/great-work.md", Highlights: []readdeck.Highlight{{ID: "h3"}}}},
+		{Type: "failed", Note: model.Note{Bookmark: readdeck.Bookmark{ID: "b3", Title: "Broken"}, Highlights: []readdeck.Highlight{{ID: "h4"}}}, Err: errors.New("disk full")},
+		{Type: "vetoed", Note: model.Note{Path: "/notes/draft.md", Bookmark: readdeck.Bookmark{ID: "b4", Title: "Draft"}, Highlights: []readdeck.Highlight{{ID: "h5"}}}, Err: errors.New("write vetoed")},
+	}
+	failures := []hooks.Failure{{Event: hooks.PostRun, Command: "notify", Err: errors.New("exit status 1")}}
+	phases := []progress.Phase{{Stage: progress.Notes, Done: 3, Duration: time.Second}}
+
+	run := NewRun("export", started, started.Add(2*time.Second), phases, results, failures, nil)
+	assert.Equal(t, Counts{Updated: 1, Unchanged: 1, Failed: 1, Vetoed: 1}, run.Counts)
+	assert.Equal(t, 3, run.Highlights)
+	assert.Equal(t, 1, run.HighlightsAdded)
+	assert.Equal(t, 2*time.Second, run.Duration())
+	assert.Equal(t, phases, run.Phases)
+	assert.True(t, run.Failed())
+
+	// Unchanged notes are only counted
+	assert.Equal(t, []Note{
+		{Type: "updated", Path: "/notes/schlep.md", BookmarkID: "b1", BookmarkTitle: "Schlep Blindness", Highlights: 2, Added: []string{"h2"}},
+		{Type: "failed", BookmarkID: "b3", BookmarkTitle: "Broken", Highlights: 1, Error: "disk full"},
+		{Type: "vetoed", Path: "/notes/draft.md", BookmarkID: "b4", BookmarkTitle: "Draft", Highlights: 1, Error: "write vetoed"},
+	}, run.Notes)
+	assert.Equal(t, []string{`post_run hook "notify" failed: exit status 1`}, run.HookFailures)
+
+	assert.True(t, run.Touches("schlep"))
+	assert.False(t, run.Touches("great-work"))
+
+	interrupted := NewRun("sync", started, started, nil, nil, nil, context.Canceled)
+	assert.True(t, interrupted.Interrupted)
+	assert.Equal(t, "context canceled", interrupted.Error)
+	assert.False(t, interrupted.Failed())
+}
+
+func TestHistory_Add(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	h, err := Load(store)
+	require.NoError(t, err)
+	assert.Empty(t, h.Latest("", 0))
+
+	for i := 0; i < maxRuns+2; i++ {
+		run := Run{Trigger: "sync", Notes: []Note{{Path: fmt.Sprintf("/notes/%d.md", i)}}}
+		assert.Equal(t, i+1, h.Add(run).ID)
+	}
+	require.NoError(t, h.Save(store))
+
+	reloaded, err := Load(store)
+	require.NoError(t, err)
+	require.Len(t, reloaded.Runs, maxRuns)
+	assert.Equal(t, 3, reloaded.Runs[0].ID)
+
+	// The numbering goes on where it was
+	assert.Equal(t, maxRuns+3, reloaded.Add(Run{}).ID)
+
+	_, ok := reloaded.Find(1)
+	assert.False(t, ok)
+	run, ok := reloaded.Find(50)
+	require.True(t, ok)
+	assert.Equal(t, "/notes/49.md", run.Notes[0].Path)
+
+	latest := reloaded.Latest("", 2)
+	require.Len(t, latest, 2)
+	assert.Equal(t, maxRuns+3, latest[0].ID)
+	assert.Equal(t, maxRuns+2, latest[1].ID)
+
+	touched := reloaded.Latest("/notes/42.md", 0)
+	require.Len(t, touched, 1)
+	assert.Equal(t, 43, touched[0].ID)
+}
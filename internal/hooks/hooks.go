@@ -0,0 +1,231 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+)
+
+type Event string
+
+const (
+	PreWrite  Event = "pre_write"
+	PostWrite Event = "post_write"
+	PostRun   Event = "post_run"
+)
+
+// Config holds the shell commands to run per event, in order
+type Config struct {
+	PreWrite  []string
+	PostWrite []string
+	PostRun   []string
+	// Timeout applies to every single command
+	Timeout time.Duration
+}
+
+func (c Config) IsEmpty() bool {
+	return len(c.PreWrite) == 0 && len(c.PostWrite) == 0 && len(c.PostRun) == 0
+}
+
+// Operation describes a note that was (or is about to be) written
+type Operation struct {
+	Type            string   `json:"type"`
+	Path            string   `json:"path"`
+	Bookmark        Bookmark `json:"bookmark"`
+	Highlights      int      `json:"highlights"`
+	HighlightsAdded int      `json:"highlights_added"`
+	// Error is set on failed operations
+	Error string `json:"error,omitempty"`
+}
+
+type Bookmark struct {
+	ID     string   `json:"id"`
+	Title  string   `json:"title"`
+	URL    string   `json:"url"`
+	Type   string   `json:"type"`
+	Labels []string `json:"labels"`
+}
+
+// WritePayload is sent on stdin to the pre-write and post-write hooks
+type WritePayload struct {
+	Event Event `json:"event"`
+	Operation
+	// Content is only sent to pre-write hooks
+	Content string `json:"content,omitempty"`
+}
+
+// RunPayload is sent on stdin to the post-run hooks
+type RunPayload struct {
+	Event      Event       `json:"event"`
+	Operations []Operation `json:"operations"`
+}
+
+// Failure is a hook that failed, timed out or vetoed a write
+type Failure struct {
+	Event   Event
+	Command string
+	// Path of the note, empty for post-run hooks
+	Path   string
+	Vetoed bool
+	Err    error
+}
+
+func (f Failure) Error() string {
+	if f.Vetoed {
+		return fmt.Sprintf("%s hook %q vetoed %s: %v", f.Event, f.Command, f.Path, f.Err)
+	}
+	if f.Path != "" {
+		return fmt.Sprintf("%s hook %q failed for %s: %v", f.Event, f.Command, f.Path, f.Err)
+	}
+	return fmt.Sprintf("%s hook %q failed: %v", f.Event, f.Command, f.Err)
+}
+
+// Runner runs the hooks of the configuration and keeps track of their failures.
+// It is the write hook of the note repositories.
+type Runner struct {
+	config Config
+
+	mu       sync.Mutex
+	failures []Failure
+}
+
+var _ repository.WriteHook = (*Runner)(nil)
+
+func NewRunner(config Config) *Runner {
+	return &Runner{config: config}
+}
+
+// BeforeWrite runs the pre-write hooks, each one gets the content as left by the one before.
+// A hook replaces the content by printing it, when it prints nothing the content is kept.
+// A hook that exits with a non-zero status vetoes the write, one that can't run or times out
+// skips the write as well.
+func (r *Runner) BeforeWrite(ctx context.Context, result repository.OperationResult, content []byte) ([]byte, error) {
+	for _, command := range r.config.PreWrite {
+		output, err := r.run(ctx, command, WritePayload{
+			Event:     PreWrite,
+			Operation: NewOperation(result),
+			Content:   string(content),
+		})
+		if err != nil {
+			var exitErr *exec.ExitError
+			vetoed := errors.As(err, &exitErr)
+			r.fail(Failure{Event: PreWrite, Command: command, Path: result.Note.Path, Vetoed: vetoed, Err: err})
+			if !vetoed {
+				// A hook that couldn't run or timed out fails the note, it didn't decide anything
+				return nil, fmt.Errorf("pre-write hook %q failed: %w", command, err)
+			}
+			return nil, fmt.Errorf("%w by %q: %w", repository.ErrWriteVetoed, command, err)
+		}
+
+		if len(bytes.TrimSpace(output)) > 0 {
+			content = output
+		}
+	}
+	return content, nil
+}
+
+// AfterWrite runs the post-write hooks, their failures don't affect the export
+func (r *Runner) AfterWrite(ctx context.Context, result repository.OperationResult) {
+	for _, command := range r.config.PostWrite {
+		if _, err := r.run(ctx, command, WritePayload{Event: PostWrite, Operation: NewOperation(result)}); err != nil {
+			r.fail(Failure{Event: PostWrite, Command: command, Path: result.Note.Path, Err: err})
+		}
+	}
+}
+
+// AfterRun runs the post-run hooks with every operation of the export
+func (r *Runner) AfterRun(ctx context.Context, results []repository.OperationResult) {
+	payload := RunPayload{Event: PostRun, Operations: make([]Operation, 0, len(results))}
+	for _, result := range results {
+		payload.Operations = append(payload.Operations, NewOperation(result))
+	}
+
+	for _, command := range r.config.PostRun {
+		if _, err := r.run(ctx, command, payload); err != nil {
+			r.fail(Failure{Event: PostRun, Command: command, Err: err})
+		}
+	}
+}
+
+func (r *Runner) Failures() []Failure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Failure{}, r.failures...)
+}
+
+func (r *Runner) fail(failure Failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, failure)
+}
+
+// run runs the command in the shell with the payload on stdin and returns what it printed
+func (r *Runner) run(ctx context.Context, command string, payload any) ([]byte, error) {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the payload: %w", err)
+	}
+
+	if r.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.Timeout)
+		defer cancel()
+	}
+
+	cmd := shell(ctx, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait on children that keep the output open after the command was killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s", r.config.Timeout)
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func NewOperation(result repository.OperationResult) Operation {
+	bookmark := result.Note.Bookmark
+	operation := Operation{
+		Type: result.Type,
+		Path: result.Note.Path,
+		Bookmark: Bookmark{
+			ID:     bookmark.ID,
+			Title:  bookmark.Title,
+			URL:    bookmark.SiteUrl,
+			Type:   bookmark.Type,
+			Labels: bookmark.Labels,
+		},
+		Highlights:      len(result.Note.Highlights),
+		HighlightsAdded: result.HighlightsAdded,
+	}
+	if result.Err != nil {
+		operation.Error = result.Err.Error()
+	}
+	return operation
+}
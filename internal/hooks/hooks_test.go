@@ -0,0 +1,126 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResult() repository.OperationResult {
+	return repository.OperationResult{
+		Type: "updated",
+		Note: model.Note{
+			Path:       "/notes/schlep.md",
+			Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", SiteUrl: "https://paulgraham.com/schlep.html", Labels: []string{"startups"}},
+			Highlights: []readdeck.Highlight{{ID: "h1"}, {ID: "h2"}},
+		},
+		HighlightsAdded: 1,
+	}
+}
+
+func skipWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of these tests are sh scripts")
+	}
+}
+
+func TestRunner_BeforeWrite(t *testing.T) {
+	skipWithoutShell(t)
+
+	tests := []struct {
+		name     string
+		commands []string
+		expected string
+		vetoed   bool
+		failed   bool
+	}{
+		{name: "no hooks", expected: "content"},
+		{name: "output replaces the content", commands: []string{"cat > /dev/null; printf formatted"}, expected: "formatted"},
+		{name: "no output keeps the content", commands: []string{"cat > /dev/null"}, expected: "content"},
+		{name: "hooks are chained", commands: []string{"printf first", `grep -q '"content":"first"' && printf second`}, expected: "second"},
+		{name: "non-zero exit vetoes", commands: []string{"echo 'not today' >&2; exit 1"}, vetoed: true},
+		{name: "timeout", commands: []string{"sleep 5"}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewRunner(Config{PreWrite: tt.commands, Timeout: 200 * time.Millisecond})
+			content, err := runner.BeforeWrite(context.Background(), testResult(), []byte("content"))
+
+			if !tt.vetoed && !tt.failed {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, string(content))
+				assert.Empty(t, runner.Failures())
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, tt.vetoed, errors.Is(err, repository.ErrWriteVetoed))
+			require.Len(t, runner.Failures(), 1)
+			failure := runner.Failures()[0]
+			assert.Equal(t, tt.vetoed, failure.Vetoed)
+			assert.Equal(t, "/notes/schlep.md", failure.Path)
+			if tt.vetoed {
+				assert.Contains(t, failure.Error(), "not today")
+			} else {
+				assert.Contains(t, failure.Error(), "timed out")
+			}
+		})
+	}
+}
+
+func TestRunner_AfterWrite(t *testing.T) {
+	skipWithoutShell(t)
+
+	output := filepath.Join(t.TempDir(), "payload.json")
+	runner := NewRunner(Config{PostWrite: []string{"cat > " + output, "exit 3"}, Timeout: time.Second})
+	runner.AfterWrite(context.Background(), testResult())
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	var payload WritePayload
+	require.NoError(t, json.Unmarshal(content, &payload))
+	assert.Equal(t, WritePayload{
+		Event: PostWrite,
+		Operation: Operation{
+			Type:            "updated",
+			Path:            "/notes/schlep.md",
+			Bookmark:        Bookmark{ID: "b1", Title: "Schlep Blindness", URL: "https://paulgraham.com/schlep.html", Labels: []string{"startups"}},
+			Highlights:      2,
+			HighlightsAdded: 1,
+		},
+	}, payload)
+
+	require.Len(t, runner.Failures(), 1)
+	assert.Equal(t, PostWrite, runner.Failures()[0].Event)
+	assert.False(t, runner.Failures()[0].Vetoed)
+}
+
+func TestRunner_AfterRun(t *testing.T) {
+	skipWithoutShell(t)
+
+	output := filepath.Join(t.TempDir(), "payload.json")
+	runner := NewRunner(Config{PostRun: []string{"cat > " + output}, Timeout: time.Second})
+	runner.AfterRun(context.Background(), []repository.OperationResult{testResult(), testResult()})
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	var payload RunPayload
+	require.NoError(t, json.Unmarshal(content, &payload))
+	assert.Equal(t, PostRun, payload.Event)
+	assert.Len(t, payload.Operations, 2)
+	assert.Empty(t, runner.Failures())
+}
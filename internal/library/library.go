@@ -0,0 +1,98 @@
+package library
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const stateName = "library.json"
+
+// Library is a local copy of every highlight that was exported, so commands
+// like review work offline, without reading the notes back
+type Library struct {
+	UpdatedAt  time.Time        `json:"updated_at"`
+	Highlights map[string]Entry `json:"highlights"`
+}
+
+type Entry struct {
+	HighlightID   string    `json:"highlight_id"`
+	Text          string    `json:"text"`
+	Color         string    `json:"color"`
+	Created       time.Time `json:"created"`
+	BookmarkID    string    `json:"bookmark_id"`
+	BookmarkTitle string    `json:"bookmark_title"`
+	BookmarkType  string    `json:"bookmark_type"`
+	Authors       []string  `json:"authors"`
+	Labels        []string  `json:"labels"`
+	URL           string    `json:"url"`
+	// Path is the note the highlight was exported to
+	Path string `json:"path"`
+}
+
+func Load(store *state.Store) (Library, error) {
+	l := Library{Highlights: map[string]Entry{}}
+	if err := store.Load(stateName, &l); err != nil {
+		return Library{}, err
+	}
+	if l.Highlights == nil {
+		l.Highlights = map[string]Entry{}
+	}
+	return l, nil
+}
+
+func (l Library) Save(store *state.Store) error {
+	return store.Save(stateName, l)
+}
+
+// Record adds or refreshes the highlights of the exported notes.
+// A highlight exported to several notes (eg. atomic mode) keeps the first path.
+func (l *Library) Record(notes []model.Note, at time.Time) {
+	if l.Highlights == nil {
+		l.Highlights = map[string]Entry{}
+	}
+
+	for _, note := range notes {
+		for _, h := range note.Highlights {
+			path := note.Path
+			if existing, ok := l.Highlights[h.ID]; ok && existing.Path != "" {
+				path = existing.Path
+			}
+
+			l.Highlights[h.ID] = Entry{
+				HighlightID:   h.ID,
+				Text:          h.Text,
+				Color:         h.Color,
+				Created:       h.Created,
+				BookmarkID:    note.Bookmark.ID,
+				BookmarkTitle: note.Bookmark.Title,
+				BookmarkType:  note.Bookmark.Type,
+				Authors:       note.Bookmark.Authors,
+				Labels:        note.Bookmark.Labels,
+				URL:           note.Bookmark.SiteUrl,
+				Path:          path,
+			}
+		}
+	}
+
+	l.UpdatedAt = at.UTC()
+}
+
+// Entries returns the highlights ordered by creation
+func (l Library) Entries() []Entry {
+	result := make([]Entry, 0, len(l.Highlights))
+	for _, entry := range l.Highlights {
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Created.Equal(result[j].Created) {
+			return result[i].Created.Before(result[j].Created)
+		}
+		return result[i].HighlightID < result[j].HighlightID
+	})
+
+	return result
+}
@@ -0,0 +1,44 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLibrary_Record(t *testing.T) {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", SiteUrl: "https://www.paulgraham.com/schlep.html"}
+
+	h1 := readdeck.Highlight{ID: "h1", Text: "First", Color: "yellow", Created: created.Add(time.Minute)}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second", Color: "green", Created: created}
+
+	store := state.NewStore(t.TempDir())
+	l, err := Load(store)
+	require.NoError(t, err)
+	assert.Empty(t, l.Entries())
+
+	l.Record([]model.Note{{Path: "/notes/schlep.md", Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}}}, created)
+
+	// The atomic note of a highlight doesn't replace the bookmark note
+	h1.Text = "First, edited"
+	l.Record([]model.Note{{Path: "/notes/first.md", Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}}}, created)
+	require.NoError(t, l.Save(store))
+
+	reloaded, err := Load(store)
+	require.NoError(t, err)
+
+	entries := reloaded.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "h2", entries[0].HighlightID)
+	assert.Equal(t, "h1", entries[1].HighlightID)
+	assert.Equal(t, "First, edited", entries[1].Text)
+	assert.Equal(t, "/notes/schlep.md", entries[1].Path)
+	assert.Equal(t, "Schlep Blindness", entries[1].BookmarkTitle)
+	assert.Equal(t, "https://www.paulgraham.com/schlep.html", entries[1].URL)
+}
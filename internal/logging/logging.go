@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	TextFormat Format = "text"
+	JSONFormat Format = "json"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case TextFormat, "":
+		return TextFormat, nil
+	case JSONFormat:
+		return JSONFormat, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, expected text or json", value)
+}
+
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+type Options struct {
+	Level  slog.Level
+	Format Format
+	// Secrets are replaced wherever they show up in a message or an attribute
+	Secrets []string
+}
+
+// New returns a logger that writes to w in the given format
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+
+	var handler slog.Handler
+	if opts.Format == JSONFormat {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	return slog.New(NewRedactingHandler(handler, opts.Secrets...))
+}
+
+// OpenFile opens the log file for appending, a relative path is relative to the directory
+func OpenFile(path string, dir string) (*os.File, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("could not create the directory of %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the log file: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(&buffer, Options{Level: slog.LevelDebug, Format: JSONFormat, Secrets: []string{"s3cr3t-token", " ", "t"}})
+
+	logger.With("base_url", "https://read.example.com?key=s3cr3t-token").Info("request with s3cr3t-token failed",
+		"error", errors.New("401: Authorization: Bearer abc.def"),
+		"token", "anything",
+		slog.Group("request", "header", "bearer xyz", "page", 2),
+	)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+
+	assert.Equal(t, "request with [REDACTED] failed", record["msg"])
+	assert.Equal(t, "https://read.example.com?key=[REDACTED]", record["base_url"])
+	assert.Equal(t, "401: Authorization: Bearer [REDACTED]", record["error"])
+	assert.Equal(t, "[REDACTED]", record["token"])
+	assert.Equal(t, map[string]any{"header": "bearer [REDACTED]", "page": 2.0}, record["request"])
+}
+
+func TestNew(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(&buffer, Options{Level: slog.LevelWarn, Format: TextFormat})
+
+	logger.Info("hidden")
+	logger.Warn("shown", "bookmark_id", "b1", "path", "/notes/a note.md")
+	assert.NotContains(t, buffer.String(), "hidden")
+	assert.Contains(t, buffer.String(), `level=WARN msg=shown bookmark_id=b1 path="/notes/a note.md"`)
+}
+
+func TestParse(t *testing.T) {
+	level, err := ParseLevel(" DEBUG ")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+
+	format, err := ParseFormat("JSON")
+	require.NoError(t, err)
+	assert.Equal(t, JSONFormat, format)
+
+	_, err = ParseFormat("logfmt")
+	assert.Error(t, err)
+}
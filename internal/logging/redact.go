@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const (
+	redacted = "[REDACTED]"
+	// minSecretLength keeps short secrets from redacting every word they happen to be part of
+	minSecretLength = 8
+)
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[^\s"',;]+`)
+	// sensitiveKeys are attributes that are never logged, whatever their value
+	sensitiveKeys = map[string]bool{"token": true, "authorization": true, "secret": true, "password": true}
+)
+
+// RedactingHandler scrubs secrets, bearer tokens and sensitive attributes from the
+// records before they reach the handler it wraps
+type RedactingHandler struct {
+	handler slog.Handler
+	secrets []string
+}
+
+var _ slog.Handler = (*RedactingHandler)(nil)
+
+func NewRedactingHandler(handler slog.Handler, secrets ...string) *RedactingHandler {
+	h := &RedactingHandler{handler: handler}
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); len(secret) >= minSecretLength {
+			h.secrets = append(h.secrets, secret)
+		}
+	}
+	return h
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	scrubbed := slog.NewRecord(record.Time, record.Level, h.redact(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, scrubbed)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RedactingHandler{handler: h.handler.WithAttrs(h.redactAttrs(attrs)), secrets: h.secrets}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{handler: h.handler.WithGroup(name), secrets: h.secrets}
+}
+
+func (h *RedactingHandler) redactAttrs(attrs []slog.Attr) []slog.Attr {
+	result := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		result = append(result, h.redactAttr(a))
+	}
+	return result
+}
+
+func (h *RedactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redact(value.String()))
+	case slog.KindGroup:
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(h.redactAttrs(value.Group())...)}
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(a.Key, h.redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, h.redact(v.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+func (h *RedactingHandler) redact(text string) string {
+	for _, secret := range h.secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return bearerPattern.ReplaceAllString(text, "${1}"+redacted)
+}
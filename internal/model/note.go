@@ -6,4 +6,6 @@ type Note struct {
 	Path       string
 	Bookmark   readdeck.Bookmark
 	Highlights []readdeck.Highlight
+	// Citekey identifies the bookmark in the bibliography, it is empty when citations aren't tracked
+	Citekey string
 }
@@ -13,6 +13,11 @@ type NoteMetadata struct {
 	ArchiveUrl   string     `yaml:"readdeck-url"`
 	Site         string     `yaml:"media-url"`
 	Authors      []string   `yaml:"authors"`
+	Citekey      string     `yaml:"citekey,omitempty"`
+	// Only set on atomic notes, which hold a single highlight
+	HighlightID string `yaml:"readdeck-highlight-id,omitempty"`
+	Color       string `yaml:"color,omitempty"`
+	Source      string `yaml:"source,omitempty"`
 }
 
 type ParsedNote struct {
@@ -21,6 +26,14 @@ type ParsedNote struct {
 	Content        []Section
 	HighlightIDs   []string
 	RawFrontmatter map[string]interface{}
+	// RawProperties keeps the metadata, in order, of formats that use properties instead of frontmatter
+	RawProperties []Property
+}
+
+// Property is a single key/value pair of note metadata, for formats that don't use YAML frontmatter
+type Property struct {
+	Key   string
+	Value string
 }
 
 type SectionType string
@@ -0,0 +1,48 @@
+package progress
+
+// Stage is a step of the export that reports its progress
+type Stage string
+
+const (
+	Pages     Stage = "pages"
+	Bookmarks Stage = "bookmarks"
+	Files     Stage = "files"
+	Notes     Stage = "notes"
+)
+
+// Event is the progress within a stage, Total is zero when it isn't known
+type Event struct {
+	Stage Stage
+	Done  int
+	Total int
+}
+
+// Reporter receives the progress of the client, the exporter and the repository
+type Reporter interface {
+	Report(event Event)
+}
+
+// startsOver tells whether the event begins a new run of a stage, eg. the notes of another destination
+func startsOver(previous Event, event Event) bool {
+	return event.Stage != previous.Stage || event.Done <= previous.Done || event.Total != previous.Total
+}
+
+// Multi passes every event on to all the reporters
+func Multi(reporters ...Reporter) Reporter {
+	return multi(reporters)
+}
+
+type multi []Reporter
+
+func (m multi) Report(event Event) {
+	for _, r := range m {
+		r.Report(event)
+	}
+}
+
+type nop struct{}
+
+func (nop) Report(Event) {}
+
+// Nop ignores every event, it is the reporter when none is set
+var Nop Reporter = nop{}
@@ -0,0 +1,64 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Phase is how long a stage took and how much it did, over all of its runs
+type Phase struct {
+	Stage    Stage         `json:"stage"`
+	Done     int           `json:"done"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Recorder keeps the phases of an export. The time between two events is spent on
+// the stage of the latter, the first one counts from the creation of the recorder.
+type Recorder struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	current Event
+	last    time.Time
+	phases  []Phase
+}
+
+var _ Reporter = (*Recorder)(nil)
+
+func NewRecorder() *Recorder {
+	return &Recorder{now: time.Now, last: time.Now()}
+}
+
+func (r *Recorder) Report(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	phase := r.phase(event.Stage)
+	if startsOver(r.current, event) {
+		phase.Done += event.Done
+	} else {
+		phase.Done += event.Done - r.current.Done
+	}
+
+	now := r.now()
+	phase.Duration += now.Sub(r.last)
+	r.last = now
+	r.current = event
+}
+
+// Phases returns the stages in the order they started
+func (r *Recorder) Phases() []Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Phase(nil), r.phases...)
+}
+
+func (r *Recorder) phase(stage Stage) *Phase {
+	for i := range r.phases {
+		if r.phases[i].Stage == stage {
+			return &r.phases[i]
+		}
+	}
+	r.phases = append(r.phases, Phase{Stage: stage})
+	return &r.phases[len(r.phases)-1]
+}
@@ -0,0 +1,33 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+
+	// Every event comes a second after the previous one
+	now := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	recorder.last = now
+	recorder.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	reporter := Multi(recorder, Nop)
+	reporter.Report(Event{Stage: Pages, Done: 1, Total: 2})
+	reporter.Report(Event{Stage: Pages, Done: 2, Total: 2})
+	reporter.Report(Event{Stage: Notes, Done: 1, Total: 3})
+	reporter.Report(Event{Stage: Notes, Done: 3, Total: 3})
+	// The notes of a second destination
+	reporter.Report(Event{Stage: Notes, Done: 1, Total: 1})
+
+	assert.Equal(t, []Phase{
+		{Stage: Pages, Done: 2, Duration: 2 * time.Second},
+		{Stage: Notes, Done: 4, Duration: 3 * time.Second},
+	}, recorder.Phases())
+}
@@ -0,0 +1,133 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const redrawInterval = 100 * time.Millisecond
+
+type stageText struct {
+	// Active is shown while the stage runs, Finished when it is done
+	Active   string
+	Finished string
+	Unit     string
+}
+
+var stageTexts = map[Stage]stageText{
+	Pages:     {Active: "Fetching highlights", Finished: "Fetched", Unit: "pages"},
+	Bookmarks: {Active: "Resolving bookmarks", Finished: "Resolved", Unit: "bookmarks"},
+	Files:     {Active: "Scanning the vault", Finished: "Scanned", Unit: "files"},
+	Notes:     {Active: "Writing notes", Finished: "Wrote", Unit: "notes"},
+}
+
+// Renderer shows the progress in the terminal. Live, it redraws a single status line
+// with the counts and an ETA. Otherwise it prints a plain line once a stage is done.
+type Renderer struct {
+	w    io.Writer
+	live bool
+	now  func() time.Time
+
+	mu      sync.Mutex
+	current Event
+	started time.Time
+	drawn   time.Time
+	// finished is when the previous stage was done, the work of a stage starts before its first event
+	finished time.Time
+}
+
+var _ Reporter = (*Renderer)(nil)
+
+func NewRenderer(w io.Writer, live bool) *Renderer {
+	return &Renderer{w: w, live: live, now: time.Now}
+}
+
+// NewStdoutRenderer renders live when stdout is a terminal
+func NewStdoutRenderer() *Renderer {
+	fd := os.Stdout.Fd()
+	return NewRenderer(os.Stdout, isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd))
+}
+
+func (r *Renderer) Report(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if startsOver(r.current, event) {
+		r.finishStage(now)
+		r.started = r.finished
+		if r.started.IsZero() {
+			r.started = now
+		}
+	}
+	r.current = event
+
+	if r.live && (event.Done == event.Total || now.Sub(r.drawn) >= redrawInterval) {
+		fmt.Fprintf(r.w, "\r\033[K%s", r.statusLine(now))
+		r.drawn = now
+	}
+}
+
+// Finish ends the current stage, it has to be called before anything else is printed
+func (r *Renderer) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finishStage(r.now())
+}
+
+func (r *Renderer) finishStage(now time.Time) {
+	if r.current.Stage == "" {
+		return
+	}
+
+	if r.live {
+		fmt.Fprint(r.w, "\r\033[K")
+	} else {
+		text := textOf(r.current.Stage)
+		fmt.Fprintf(r.w, "%s %d %s in %s\n", text.Finished, r.current.Done, text.Unit, formatDuration(now.Sub(r.started)))
+	}
+	r.current = Event{}
+	r.finished = now
+}
+
+func (r *Renderer) statusLine(now time.Time) string {
+	text := textOf(r.current.Stage)
+	done, total := r.current.Done, r.current.Total
+
+	if total <= 0 {
+		return fmt.Sprintf("%s: %d %s", text.Active, done, text.Unit)
+	}
+
+	line := fmt.Sprintf("%s: %d/%d %s (%d%%)", text.Active, done, total, text.Unit, done*100/total)
+	if eta, ok := estimate(now.Sub(r.started), done, total); ok {
+		line += " · ETA " + formatDuration(eta)
+	}
+	return line
+}
+
+// estimate extrapolates the time the rest takes from the time the done part took
+func estimate(elapsed time.Duration, done int, total int) (time.Duration, bool) {
+	if done <= 0 || done >= total || elapsed <= 0 {
+		return 0, false
+	}
+	return elapsed / time.Duration(done) * time.Duration(total-done), true
+}
+
+func textOf(stage Stage) stageText {
+	if text, ok := stageTexts[stage]; ok {
+		return text
+	}
+	return stageText{Active: string(stage), Finished: "Done", Unit: string(stage)}
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRenderer has a clock that moves a second ahead on every event
+func testRenderer(live bool) (*Renderer, *bytes.Buffer) {
+	var buffer bytes.Buffer
+	renderer := NewRenderer(&buffer, live)
+
+	now := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	renderer.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return renderer, &buffer
+}
+
+func TestRenderer_Live(t *testing.T) {
+	renderer, buffer := testRenderer(true)
+
+	renderer.Report(Event{Stage: Pages, Done: 1, Total: 4})
+	renderer.Report(Event{Stage: Pages, Done: 2, Total: 4})
+	assert.Equal(t, "\r\033[KFetching highlights: 1/4 pages (25%)\r\033[KFetching highlights: 2/4 pages (50%) · ETA 1s", buffer.String())
+
+	buffer.Reset()
+	renderer.Report(Event{Stage: Files, Done: 3})
+	assert.Equal(t, "\r\033[K\r\033[KScanning the vault: 3 files", buffer.String())
+
+	buffer.Reset()
+	renderer.Finish()
+	assert.Equal(t, "\r\033[K", buffer.String())
+
+	buffer.Reset()
+	renderer.Finish()
+	assert.Empty(t, buffer.String())
+}
+
+func TestRenderer_Plain(t *testing.T) {
+	renderer, buffer := testRenderer(false)
+
+	renderer.Report(Event{Stage: Bookmarks, Done: 1, Total: 2})
+	renderer.Report(Event{Stage: Bookmarks, Done: 2, Total: 2})
+	assert.Empty(t, buffer.String())
+
+	renderer.Report(Event{Stage: Notes, Done: 1, Total: 1})
+	// The notes of another destination
+	renderer.Report(Event{Stage: Notes, Done: 1, Total: 3})
+	renderer.Finish()
+
+	assert.Equal(t, "Resolved 2 bookmarks in 2s\nWrote 1 notes in 1s\nWrote 1 notes in 1s\n", buffer.String())
+}
+
+func TestEstimate(t *testing.T) {
+	eta, ok := estimate(10*time.Second, 1, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 40*time.Second, eta)
+
+	_, ok = estimate(10*time.Second, 0, 5)
+	assert.False(t, ok)
+	_, ok = estimate(10*time.Second, 5, 5)
+	assert.False(t, ok)
+}
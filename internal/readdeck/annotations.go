@@ -0,0 +1,53 @@
+package readdeck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BookmarkHighlightsClient is implemented by clients that can return the highlights of a single bookmark
+type BookmarkHighlightsClient interface {
+	GetBookmarkHighlights(ctx context.Context, bookmarkId string) ([]Highlight, error)
+}
+
+var _ BookmarkHighlightsClient = (*HttpClient)(nil)
+
+func (c HttpClient) GetBookmarkHighlights(ctx context.Context, bookmarkId string) ([]Highlight, error) {
+	endpoint := fmt.Sprintf("%s/api/bookmarks/%s/annotations", c.baseUrl, bookmarkId)
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create request: %w", err)
+	}
+
+	c.addCommonHeaders(request)
+
+	resp, err := c.do(request)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP Request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Non success status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read bytes: %w", err)
+	}
+
+	var highlights []Highlight
+	if err := json.Unmarshal(body, &highlights); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// The annotations of a bookmark don't repeat the bookmark they belong to
+	for i := range highlights {
+		highlights[i].BookmarkID = bookmarkId
+	}
+
+	return highlights, nil
+}
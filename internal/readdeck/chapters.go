@@ -0,0 +1,189 @@
+package readdeck
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ArticleClient is implemented by clients that can return the readable content of a bookmark
+type ArticleClient interface {
+	GetArticle(ctx context.Context, bookmarkId string) (string, error)
+}
+
+var _ ArticleClient = (*HttpClient)(nil)
+
+func (c HttpClient) GetArticle(ctx context.Context, bookmarkId string) (string, error) {
+	endpoint := fmt.Sprintf("%s/api/bookmarks/%s/article", c.baseUrl, bookmarkId)
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("Could not create request: %w", err)
+	}
+
+	c.addCommonHeaders(request)
+	request.Header.Set("accept", "text/html")
+
+	resp, err := c.do(request)
+	if err != nil {
+		return "", fmt.Errorf("HTTP Request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("Non success status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read bytes: %w", err)
+	}
+
+	return string(body), nil
+}
+
+// ResolveChapters sets the Chapter of every highlight to the heading that precedes
+// its start selector in the article, and its Position to the place of that element.
+// Highlights that can't be located keep an empty chapter and no position.
+func ResolveChapters(article string, highlights []Highlight) []Highlight {
+	chapters := mapChapters(article)
+
+	result := make([]Highlight, len(highlights))
+	for i, h := range highlights {
+		result[i] = h
+		if h.StartSelector == "" {
+			continue
+		}
+		if index := chapters.lookup(normalizeSelector(h.StartSelector)); index >= 0 {
+			result[i].Chapter = chapters[index].chapter
+			result[i].Position = index + 1
+		}
+	}
+
+	return result
+}
+
+type chapterEntry struct {
+	path    string
+	chapter string
+}
+
+// chapterMap holds the elements of the article in document order
+type chapterMap []chapterEntry
+
+// lookup returns the index of the element the selector points to, or -1. Selectors are
+// relative to the article, which can be wrapped in more elements than Readdeck counted. So
+// without an exact match, the element with the fewest extra ancestors is taken, as long as
+// it is the only one at that depth: a relative "p[1]" matches the first paragraph of every
+// block, guessing one of them would put the highlight in the wrong chapter.
+func (m chapterMap) lookup(selector string) int {
+	found, depth, ambiguous := -1, 0, false
+	for i, entry := range m {
+		if entry.path == selector {
+			return i
+		}
+		if !strings.HasSuffix(entry.path, "/"+selector) {
+			continue
+		}
+
+		extra := strings.Count(entry.path, "/") - strings.Count(selector, "/")
+		switch {
+		case found < 0 || extra < depth:
+			found, depth, ambiguous = i, extra, false
+		case extra == depth:
+			ambiguous = true
+		}
+	}
+
+	if ambiguous {
+		return -1
+	}
+	return found
+}
+
+// mapChapters walks the article and records, for every element in document order, the
+// heading that was last seen before it. Paths use the same format as the selectors.
+// The walk is best effort: on malformed markup we keep what was mapped so far.
+func mapChapters(article string) chapterMap {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + article + "</root>"))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var result chapterMap
+	var path []string
+	siblings := []map[string]int{{}}
+	current := ""
+	heading := -1
+	var headingText strings.Builder
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			tag := strings.ToLower(t.Name.Local)
+			counts := siblings[len(siblings)-1]
+			counts[tag]++
+
+			path = append(path, fmt.Sprintf("%s[%d]", tag, counts[tag]))
+			siblings = append(siblings, map[string]int{})
+
+			if heading < 0 && isHeading(tag) {
+				heading = len(path)
+				headingText.Reset()
+			}
+
+			// Skip our own wrapper
+			if len(path) > 1 {
+				result = append(result, chapterEntry{path: strings.Join(path[1:], "/"), chapter: current})
+			}
+		case xml.CharData:
+			if heading >= 0 {
+				headingText.Write(t)
+			}
+		case xml.EndElement:
+			if heading == len(path) {
+				current = strings.Join(strings.Fields(headingText.String()), " ")
+				heading = -1
+				// The heading itself belongs to its own chapter
+				headingPath := strings.Join(path[1:], "/")
+				for i := len(result) - 1; i >= 0; i-- {
+					if result[i].path != headingPath && !strings.HasPrefix(result[i].path, headingPath+"/") {
+						break
+					}
+					result[i].chapter = current
+				}
+			}
+
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+				siblings = siblings[:len(siblings)-1]
+			}
+		}
+	}
+
+	return result
+}
+
+func normalizeSelector(selector string) string {
+	segments := parseSelector(selector)
+	parts := make([]string, len(segments))
+	for i, s := range segments {
+		parts[i] = fmt.Sprintf("%s[%d]", s.tag, s.index)
+	}
+	return strings.Join(parts, "/")
+}
+
+func isHeading(tag string) bool {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package readdeck
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveChapters(t *testing.T) {
+	article := `<section>
+<p>Before any heading</p>
+<h2>First <em>chapter</em></h2>
+<p>One&nbsp;paragraph<br>with a break</p>
+<p>Another</p>
+<h2>Second chapter</h2>
+<ul><li>Item</li></ul>
+<p>Last</p>
+</section>`
+
+	highlights := []Highlight{
+		{ID: "intro", StartSelector: "section/p[1]"},
+		{ID: "first", StartSelector: "section/p[2]"},
+		{ID: "heading", StartSelector: "section/h2[1]/em[1]"},
+		{ID: "list", StartSelector: "/section/ul/li[1]"},
+		{ID: "last", StartSelector: "p[4]"},
+		{ID: "relative", StartSelector: "ul[1]/li[1]"},
+		{ID: "unknown", StartSelector: "section/p[20]"},
+		{ID: "none"},
+	}
+
+	got := ResolveChapters(article, highlights)
+
+	chapters := make(map[string]string, len(got))
+	for _, h := range got {
+		chapters[h.ID] = h.Chapter
+	}
+
+	assert.Equal(t, map[string]string{
+		"intro":    "",
+		"first":    "First chapter",
+		"heading":  "First chapter",
+		"list":     "Second chapter",
+		"last":     "Second chapter",
+		"relative": "Second chapter",
+		"unknown":  "",
+		"none":     "",
+	}, chapters)
+	assert.Empty(t, highlights[1].Chapter, "input should not be modified")
+
+	positions := make(map[string]int, len(got))
+	for _, h := range got {
+		positions[h.ID] = h.Position
+	}
+	// Elements in document order: section, p, h2, em, p, br, p, h2, ul, li, p
+	assert.Equal(t, map[string]int{
+		"intro":    2,
+		"first":    5,
+		"heading":  4,
+		"list":     10,
+		"last":     11,
+		"relative": 10,
+		"unknown":  0,
+		"none":     0,
+	}, positions)
+}
+
+func TestResolveChapters_RelativeSelectors(t *testing.T) {
+	article := `<div>
+<h2>First chapter</h2>
+<div><p>Nested in the first</p></div>
+<h2>Second chapter</h2>
+<div><p>Nested in the second</p></div>
+<blockquote><div><p>Deeper in the second</p></div></blockquote>
+</div>`
+
+	highlights := []Highlight{
+		// Matches the first paragraph of both blocks, there's no telling which
+		{ID: "ambiguous", StartSelector: "p[1]"},
+		// The one in the blockquote is deeper, so the first block wins
+		{ID: "shallowest", StartSelector: "div[1]/p[1]"},
+		{ID: "exact", StartSelector: "div/div[2]/p[1]"},
+	}
+
+	got := ResolveChapters(article, highlights)
+
+	assert.Equal(t, "", got[0].Chapter)
+	assert.Equal(t, 0, got[0].Position)
+	assert.Equal(t, "First chapter", got[1].Chapter)
+	assert.Equal(t, "Second chapter", got[2].Chapter)
+}
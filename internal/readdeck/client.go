@@ -16,6 +16,15 @@ type Highlight struct {
 	BookmarkURL      string    `json:"bookmark_url"`
 	BookmarkTitle    string    `json:"bookmark_title"`
 	BookmarkSiteName string    `json:"bookmark_site_name"`
+	StartSelector    string    `json:"start_selector"`
+	StartOffset      int       `json:"start_offset"`
+	EndSelector      string    `json:"end_selector"`
+	EndOffset        int       `json:"end_offset"`
+	// Chapter is the heading the highlight falls under, it is resolved from the article and not part of the API
+	Chapter string `json:"-"`
+	// Position is the place of the start element in the article, counting from 1. It is resolved
+	// along with the chapter and is 0 when the article wasn't read or the element wasn't found.
+	Position int `json:"-"`
 }
 
 type Bookmark struct {
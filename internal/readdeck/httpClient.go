@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
 )
 
 type HttpClient struct {
@@ -16,6 +18,16 @@ type HttpClient struct {
 	baseUrl  string
 	token    string
 	pageSize int
+	progress progress.Reporter
+}
+
+type HttpClientOption func(*HttpClient)
+
+// WithProgress reports every page of highlights that is fetched
+func WithProgress(reporter progress.Reporter) HttpClientOption {
+	return func(c *HttpClient) {
+		c.progress = reporter
+	}
 }
 
 // LEARNING
@@ -29,7 +41,7 @@ type highlightsCall struct {
 	TotalPages  int
 }
 
-func NewHttpClient(client http.Client, baseUrl, authToken string, pagesize int) *HttpClient {
+func NewHttpClient(client http.Client, baseUrl, authToken string, pagesize int, opts ...HttpClientOption) *HttpClient {
 	if pagesize < 10 {
 		pagesize = 10
 	}
@@ -38,20 +50,30 @@ func NewHttpClient(client http.Client, baseUrl, authToken string, pagesize int)
 		pagesize = 100
 	}
 
-	return &HttpClient{
+	c := &HttpClient{
 		client:   client,
 		baseUrl:  baseUrl,
 		token:    authToken,
 		pageSize: pagesize,
+		progress: progress.Nop,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
 func (c HttpClient) GetHighlights(ctx context.Context, since *time.Time) ([]Highlight, error) {
 	var highlights []Highlight
 	totalPages := 1
+	pages := 0
+	start := time.Now()
 
 	for i := 0; i < totalPages; i++ {
-		log.Printf("Requesting page %d, offset: %d", (i + 1), i*c.pageSize)
+		pages++
+		slog.Debug("requesting highlights", "page", i+1, "offset", i*c.pageSize)
 
 		call, err := c.doHighlightCall(ctx, c.pageSize, i*c.pageSize)
 		if err != nil {
@@ -59,6 +81,7 @@ func (c HttpClient) GetHighlights(ctx context.Context, since *time.Time) ([]High
 		}
 
 		totalPages = call.TotalPages
+		c.progress.Report(progress.Event{Stage: progress.Pages, Done: pages, Total: totalPages})
 
 		var pageHighlights []Highlight
 		stopFetching := false
@@ -80,6 +103,7 @@ func (c HttpClient) GetHighlights(ctx context.Context, since *time.Time) ([]High
 		}
 	}
 
+	slog.Info("fetched highlights", "highlights", len(highlights), "pages", pages, "duration", time.Since(start))
 	return c.reverseList(highlights), nil
 }
 
@@ -97,7 +121,7 @@ func (c HttpClient) doHighlightCall(ctx context.Context, limit int, offset int)
 		return highlightsCall{}, err
 	}
 
-	resp, err := c.client.Do(request)
+	resp, err := c.do(request)
 	if err != nil {
 		return highlightsCall{}, fmt.Errorf("HTTP Request failed: %w", err)
 	}
@@ -176,7 +200,7 @@ func (c HttpClient) GetBookmark(ctx context.Context, bookmarkId string) (Bookmar
 		return Bookmark{}, fmt.Errorf("Could not create request: %w", err)
 	}
 
-	resp, err := c.client.Do(request)
+	resp, err := c.do(request)
 
 	if err != nil {
 		return Bookmark{}, fmt.Errorf("HTTP Request failed: %w", err)
@@ -202,6 +226,19 @@ func (c HttpClient) createBookmarkRequest(ctx context.Context, id string) (*http
 	return req, nil
 }
 
+// do sends the request, with its outcome and duration at debug level
+func (c HttpClient) do(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.client.Do(request)
+	if err != nil {
+		slog.Debug("request failed", "method", request.Method, "url", request.URL.String(), "duration", time.Since(start), "error", err)
+		return nil, err
+	}
+
+	slog.Debug("request done", "method", request.Method, "url", request.URL.String(), "status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
+}
+
 func (c HttpClient) addCommonHeaders(req *http.Request) *http.Request {
 	req.Header.Add("accept", "application/json")
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
@@ -3,11 +3,13 @@ package readdeck
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetHighlightsIntegration(t *testing.T) {
@@ -37,6 +39,33 @@ func TestGetHighlightsIntegration(t *testing.T) {
 	}
 }
 
+func TestGetBookmarkHighlights(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/bookmark_annotations.json")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/bookmarks/DUvg9NZ93QP9pRbuzHVuyd/annotations", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	client := NewHttpClient(http.Client{}, server.URL, "token", 100)
+	highlights, err := client.GetBookmarkHighlights(context.Background(), "DUvg9NZ93QP9pRbuzHVuyd")
+	require.NoError(t, err)
+	require.Len(t, highlights, 2)
+
+	assert.Equal(t, "Hk3vQqGmTmPpS8hVuYrFzB", highlights[0].ID)
+	assert.Equal(t, "DUvg9NZ93QP9pRbuzHVuyd", highlights[0].BookmarkID)
+	assert.Equal(t, "section/div[1]/p[3]", highlights[0].StartSelector)
+	assert.Equal(t, 12, highlights[0].StartOffset)
+	assert.Equal(t, "section/div[1]/p[3]", highlights[0].EndSelector)
+	assert.Equal(t, 87, highlights[0].EndOffset)
+	assert.Equal(t, "section/div[1]/h2[1]", highlights[1].StartSelector)
+	assert.Equal(t, "green", highlights[1].Color)
+}
+
 func TestBookmarkIntegration(t *testing.T) {
 	if os.Getenv("RUN_INTEGRATION_TEST") != "true" {
 		t.Skip("Skipping integration test. Set `export RUN_INTEGRATION_TEST=true` to run")
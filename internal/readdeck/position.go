@@ -0,0 +1,150 @@
+package readdeck
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type HighlightOrder string
+
+const (
+	// OrderPosition sorts highlights by where they start in the article
+	OrderPosition HighlightOrder = "position"
+	// OrderCreated sorts highlights by the moment they were made
+	OrderCreated HighlightOrder = "created"
+	// OrderAPI keeps the order in which the client returned the highlights
+	OrderAPI HighlightOrder = "api"
+)
+
+func ParseHighlightOrder(input string) (HighlightOrder, error) {
+	switch HighlightOrder(strings.ToLower(strings.TrimSpace(input))) {
+	case OrderPosition, "":
+		return OrderPosition, nil
+	case OrderCreated:
+		return OrderCreated, nil
+	case OrderAPI:
+		return OrderAPI, nil
+	default:
+		return "", fmt.Errorf("unknown highlight order %q, expected one of: position, created, api", input)
+	}
+}
+
+// SortHighlights returns a sorted copy, the input is left untouched
+func SortHighlights(highlights []Highlight, order HighlightOrder) []Highlight {
+	result := make([]Highlight, len(highlights))
+	copy(result, highlights)
+
+	switch order {
+	case OrderPosition:
+		sort.SliceStable(result, func(i, j int) bool {
+			return ComparePosition(result[i], result[j]) < 0
+		})
+	case OrderCreated:
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Created.Before(result[j].Created)
+		})
+	}
+
+	return result
+}
+
+// ComparePosition compares where two highlights start in the document.
+//
+// Highlights located in the article (see ResolveChapters) are ordered on their Position,
+// which is exact. Otherwise only the selectors are known: XPath-like paths (eg.
+// "section/p[3]/em[1]") relative to the article, where the index counts the siblings
+// with the same tag. Siblings with different tags can't be placed from their selectors
+// alone, they are ordered on their tag name, so that order is approximate: a heading can
+// end up after the paragraph that follows it. The order stays total, which sorting relies
+// on: located highlights come first, then the other ones with a selector, then those
+// without a selector in order of creation.
+func ComparePosition(a, b Highlight) int {
+	switch {
+	case a.Position > 0 && b.Position > 0:
+		if a.Position != b.Position {
+			return compareInt(a.Position, b.Position)
+		}
+	case a.Position > 0:
+		return -1
+	case b.Position > 0:
+		return 1
+	}
+
+	switch {
+	case a.StartSelector == "" && b.StartSelector == "":
+		return compareCreated(a, b)
+	case a.StartSelector == "":
+		return 1
+	case b.StartSelector == "":
+		return -1
+	}
+
+	pathA := parseSelector(a.StartSelector)
+	pathB := parseSelector(b.StartSelector)
+
+	for i := 0; i < len(pathA) && i < len(pathB); i++ {
+		if pathA[i].tag != pathB[i].tag {
+			return strings.Compare(pathA[i].tag, pathB[i].tag)
+		}
+
+		if pathA[i].index != pathB[i].index {
+			return compareInt(pathA[i].index, pathB[i].index)
+		}
+	}
+
+	// One node contains the other, the ancestor's text comes first
+	if len(pathA) != len(pathB) {
+		return compareInt(len(pathA), len(pathB))
+	}
+
+	if a.StartOffset != b.StartOffset {
+		return compareInt(a.StartOffset, b.StartOffset)
+	}
+
+	return compareCreated(a, b)
+}
+
+type selectorSegment struct {
+	tag   string
+	index int
+}
+
+func parseSelector(selector string) []selectorSegment {
+	parts := strings.Split(strings.Trim(selector, "/"), "/")
+	result := make([]selectorSegment, 0, len(parts))
+
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		segment := selectorSegment{tag: strings.ToLower(part), index: 1}
+		if open := strings.Index(part, "["); open >= 0 && strings.HasSuffix(part, "]") {
+			segment.tag = strings.ToLower(part[:open])
+			if index, err := strconv.Atoi(part[open+1 : len(part)-1]); err == nil {
+				segment.index = index
+			}
+		}
+
+		result = append(result, segment)
+	}
+
+	return result
+}
+
+func compareCreated(a, b Highlight) int {
+	return a.Created.Compare(b.Created)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,169 @@
+package readdeck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortHighlights(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2025-03-26T14:00:00Z")
+
+	first := Highlight{ID: "first", StartSelector: "section/p[1]", StartOffset: 10, Created: base.Add(3 * time.Hour)}
+	second := Highlight{ID: "second", StartSelector: "section/p[1]", StartOffset: 40, Created: base.Add(1 * time.Hour)}
+	nested := Highlight{ID: "nested", StartSelector: "section/p[2]/em[1]", StartOffset: 0, Created: base}
+	third := Highlight{ID: "third", StartSelector: "section/p[10]", StartOffset: 0, Created: base.Add(2 * time.Hour)}
+	unknown := Highlight{ID: "unknown", Created: base.Add(4 * time.Hour)}
+
+	input := []Highlight{third, unknown, second, nested, first}
+
+	tests := []struct {
+		name  string
+		order HighlightOrder
+		want  []string
+	}{
+		{
+			name:  "position",
+			order: OrderPosition,
+			want:  []string{"first", "second", "nested", "third", "unknown"},
+		},
+		{
+			name:  "created",
+			order: OrderCreated,
+			want:  []string{"nested", "second", "third", "first", "unknown"},
+		},
+		{
+			name:  "api keeps the input order",
+			order: OrderAPI,
+			want:  []string{"third", "unknown", "second", "nested", "first"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SortHighlights(input, tt.order)
+
+			ids := make([]string, len(got))
+			for i, h := range got {
+				ids[i] = h.ID
+			}
+
+			assert.Equal(t, tt.want, ids)
+			assert.Equal(t, "third", input[0].ID, "input should not be modified")
+		})
+	}
+}
+
+func TestComparePosition(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2025-03-26T14:00:00Z")
+
+	tests := []struct {
+		name string
+		a    Highlight
+		b    Highlight
+		want int
+	}{
+		{
+			name: "sibling index is numeric",
+			a:    Highlight{StartSelector: "p[2]"},
+			b:    Highlight{StartSelector: "p[10]"},
+			want: -1,
+		},
+		{
+			name: "missing index means first",
+			a:    Highlight{StartSelector: "section/p"},
+			b:    Highlight{StartSelector: "section/p[2]"},
+			want: -1,
+		},
+		{
+			name: "ancestor comes before descendant",
+			a:    Highlight{StartSelector: "section/p[2]/em[1]"},
+			b:    Highlight{StartSelector: "section/p[2]"},
+			want: 1,
+		},
+		{
+			name: "same node compares offsets",
+			a:    Highlight{StartSelector: "p[1]", StartOffset: 5},
+			b:    Highlight{StartSelector: "p[1]", StartOffset: 5},
+			want: 0,
+		},
+		{
+			name: "different tags are ordered on the tag",
+			a:    Highlight{StartSelector: "section/ul[1]", Created: base},
+			b:    Highlight{StartSelector: "section/p[1]", Created: base.Add(time.Minute)},
+			want: 1,
+		},
+		{
+			name: "a missing selector comes last",
+			a:    Highlight{Created: base},
+			b:    Highlight{StartSelector: "section/p[1]", Created: base.Add(time.Minute)},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ComparePosition(tt.a, tt.b))
+		})
+	}
+}
+
+func TestComparePosition_ArticlePosition(t *testing.T) {
+	// The paragraph comes before the heading in the article, their tags alone would say otherwise
+	paragraph := Highlight{ID: "paragraph", StartSelector: "section/p[1]", Position: 2}
+	heading := Highlight{ID: "heading", StartSelector: "section/h2[1]", Position: 3}
+	unlocated := Highlight{ID: "unlocated", StartSelector: "section/a[1]"}
+
+	sorted := SortHighlights([]Highlight{unlocated, heading, paragraph}, OrderPosition)
+
+	assert.Equal(t, []string{"paragraph", "heading", "unlocated"}, []string{sorted[0].ID, sorted[1].ID, sorted[2].ID})
+}
+
+func TestComparePosition_Transitive(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2025-03-26T14:00:00Z")
+
+	// With a fallback on the creation time these formed a cycle: p[1] < p[2] < ul[1] < p[1]
+	highlights := []Highlight{
+		{ID: "p1", StartSelector: "section/p[1]", Created: base.Add(3 * time.Hour)},
+		{ID: "p2", StartSelector: "section/p[2]", Created: base.Add(1 * time.Hour)},
+		{ID: "ul1", StartSelector: "section/ul[1]", Created: base.Add(2 * time.Hour)},
+		{ID: "h2", StartSelector: "section/h2[1]", Created: base},
+		{ID: "quote", StartSelector: "section/blockquote[1]/p[1]", Created: base.Add(4 * time.Hour)},
+		{ID: "unknown", Created: base.Add(-time.Hour)},
+		{ID: "located", StartSelector: "section/p[3]", Position: 7, Created: base},
+		{ID: "located-first", StartSelector: "section/ul[2]", Position: 2, Created: base},
+	}
+
+	for _, a := range highlights {
+		assert.Zero(t, ComparePosition(a, a))
+		for _, b := range highlights {
+			assert.Equal(t, -ComparePosition(b, a), ComparePosition(a, b), "%s and %s", a.ID, b.ID)
+			for _, c := range highlights {
+				if ComparePosition(a, b) < 0 && ComparePosition(b, c) < 0 {
+					assert.Negative(t, ComparePosition(a, c), "%s < %s < %s", a.ID, b.ID, c.ID)
+				}
+			}
+		}
+	}
+
+	// The order is the same whatever the input order
+	reversed := make([]Highlight, len(highlights))
+	for i, h := range highlights {
+		reversed[len(highlights)-1-i] = h
+	}
+	assert.Equal(t, SortHighlights(highlights, OrderPosition), SortHighlights(reversed, OrderPosition))
+}
+
+func TestParseHighlightOrder(t *testing.T) {
+	order, err := ParseHighlightOrder("")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderPosition, order)
+
+	order, err = ParseHighlightOrder(" API ")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderAPI, order)
+
+	_, err = ParseHighlightOrder("alphabetical")
+	assert.Error(t, err)
+}
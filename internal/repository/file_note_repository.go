@@ -3,92 +3,226 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 )
 
 type OperationResult struct {
-	Type            string // "created", "updated", "unchanged"
+	Type            string // "created", "updated", "unchanged", "failed", "vetoed"
 	Note            model.Note
 	HighlightsAdded int
+	// NewHighlights are the highlights written by this operation
+	NewHighlights []readdeck.Highlight
+	// Err is why a "failed" operation failed or why a hook vetoed it, the note is left as it was
+	Err error
 }
 
 type FileNoteRepository struct {
-	fleetingPath string
-	noteService  NoteService
-	verbose      bool
+	fleetingPath   string
+	noteService    NoteService
+	verbose        bool
+	highlightNotes *HighlightNoteGenerator
+	excludedPaths  map[string]bool
+	extension      string
+	hook           WriteHook
+	progress       progress.Reporter
 }
 
-func NewFileNoteRepository(fleetingPath string, noteService NoteService, verbose bool) *FileNoteRepository {
-	return &FileNoteRepository{
-		fleetingPath: fleetingPath,
-		noteService:  noteService,
-		verbose:      verbose,
+type FileNoteRepositoryOption func(*FileNoteRepository)
+
+// WithHighlightNotes enables the atomic mode: next to the note per bookmark,
+// which then acts as the source note, every highlight gets a note of its own
+func WithHighlightNotes(generator *HighlightNoteGenerator) FileNoteRepositoryOption {
+	return func(f *FileNoteRepository) {
+		f.highlightNotes = generator
+	}
+}
+
+// WithExcludedPaths skips directories while looking for existing notes,
+// eg. the destination of a route that lives inside the fleeting path
+func WithExcludedPaths(paths ...string) FileNoteRepositoryOption {
+	return func(f *FileNoteRepository) {
+		for _, path := range paths {
+			f.excludedPaths[filepath.Clean(path)] = true
+		}
+	}
+}
+
+// WithExtension sets the extension of the note files, ".md" by default
+func WithExtension(extension string) FileNoteRepositoryOption {
+	return func(f *FileNoteRepository) {
+		f.extension = extension
+	}
+}
+
+// WithProgress reports the files that are scanned and the notes that are written
+func WithProgress(reporter progress.Reporter) FileNoteRepositoryOption {
+	return func(f *FileNoteRepository) {
+		f.progress = reporter
+	}
+}
+
+func NewFileNoteRepository(fleetingPath string, noteService NoteService, verbose bool, opts ...FileNoteRepositoryOption) *FileNoteRepository {
+	repository := &FileNoteRepository{
+		fleetingPath:  fleetingPath,
+		noteService:   noteService,
+		verbose:       verbose,
+		excludedPaths: make(map[string]bool),
+		extension:     ".md",
+		progress:      progress.Nop,
+	}
+
+	for _, opt := range opts {
+		opt(repository)
 	}
+
+	return repository
 }
 
 func (f *FileNoteRepository) UpsertAll(ctx context.Context, notes []model.Note) ([]OperationResult, error) {
 	notePaths, err := f.findNotesInDirectory(f.fleetingPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A destination that doesn't exist yet has no notes, writing the first one creates it
+		notePaths, err = nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not find note paths: %w", err)
 	}
 
 	parsedNotes, skippedPaths := f.readNoteFiles(notePaths)
 
-	if f.verbose && len(skippedPaths) > 0 {
-		fmt.Printf("\nSkipped %d note(s) due to parsing errors:\n", len(skippedPaths))
-		for path, err := range skippedPaths {
-			fmt.Printf("  - %s: %v\n", path, err)
-		}
+	for path, err := range skippedPaths {
+		slog.Log(ctx, f.problemLevel(), "skipped a note that could not be parsed", "path", path, "error", err)
 	}
 
 	lookup := f.createLookup(parsedNotes)
+	highlightLookup := f.createHighlightLookup(parsedNotes)
 	results := make([]OperationResult, 0, len(notes))
 
-	for _, toWriteNote := range notes {
-		result, err := f.processNote(toWriteNote, lookup)
+	for i, toWriteNote := range notes {
+		// Stop between notes, a note that is being written is always finished
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		f.progress.Report(progress.Event{Stage: progress.Notes, Done: i + 1, Total: len(notes)})
+
+		// A note that was started is finished, its hooks aren't killed when the export is interrupted
+		result, err := f.processNote(context.WithoutCancel(ctx), toWriteNote, lookup)
 		if err != nil {
-			if f.verbose {
-				fmt.Printf("Warning: Failed to process note for bookmark %s: %v\n",
-					toWriteNote.Bookmark.ID, err)
+			opType := "failed"
+			if errors.Is(err, ErrWriteVetoed) {
+				opType = "vetoed"
+				slog.Log(ctx, f.problemLevel(), "a hook vetoed the note", "bookmark_id", toWriteNote.Bookmark.ID, "error", err)
+			} else {
+				slog.Log(ctx, f.problemLevel(), "could not process the note", "bookmark_id", toWriteNote.Bookmark.ID, "error", err)
+			}
+			skipped := toWriteNote
+			if existing, ok := lookup[toWriteNote.Bookmark.ID]; ok {
+				skipped.Path = existing.Path
 			}
+			results = append(results, OperationResult{Type: opType, Note: skipped, Err: err})
 			continue
 		}
 		results = append(results, result)
+
+		if f.highlightNotes == nil {
+			continue
+		}
+
+		results = append(results, f.processHighlightNotes(ctx, result.Note, highlightLookup)...)
 	}
 
 	return results, nil
 }
 
-func (f *FileNoteRepository) processNote(note model.Note, lookup map[string]model.ParsedNote) (OperationResult, error) {
+// problemLevel is the level problems with single notes are logged at, they are only warnings in verbose mode
+func (f *FileNoteRepository) problemLevel() slog.Level {
+	if f.verbose {
+		return slog.LevelWarn
+	}
+	return slog.LevelDebug
+}
+
+// processHighlightNotes creates the atomic notes of highlights that don't have one yet.
+// Existing atomic notes are never rewritten, they are the user's to edit.
+// A highlight whose note can't be written is a failed result, the others are still written.
+func (f *FileNoteRepository) processHighlightNotes(ctx context.Context, source model.Note, lookup map[string]model.ParsedNote) []OperationResult {
+	sourceID := strings.TrimSuffix(filepath.Base(source.Path), filepath.Ext(source.Path))
+	results := make([]OperationResult, 0)
+
+	for _, h := range source.Highlights {
+		if _, exists := lookup[h.ID]; exists {
+			continue
+		}
+		// Every highlight note is a note of its own, the export stops before the next one
+		if ctx.Err() != nil {
+			break
+		}
+
+		operation, err := f.highlightNotes.GenerateHighlightNote(h, source.Bookmark, sourceID)
+		if err != nil {
+			err = fmt.Errorf("could not generate bytes for highlight %s: %w", h.ID, err)
+			slog.Log(ctx, f.problemLevel(), "could not process the highlight note", "bookmark_id", source.Bookmark.ID, "highlight_id", h.ID, "error", err)
+			results = append(results, OperationResult{
+				Type: "failed",
+				Note: model.Note{Bookmark: source.Bookmark, Highlights: []readdeck.Highlight{h}},
+				Err:  err,
+			})
+			continue
+		}
+
+		result := OperationResult{
+			Type: "created",
+			Note: model.Note{
+				Path:       fmt.Sprintf("%s/%s%s", f.fleetingPath, operation.Metadata.ID, f.extension),
+				Bookmark:   source.Bookmark,
+				Highlights: []readdeck.Highlight{h},
+			},
+			HighlightsAdded: 1,
+			NewHighlights:   []readdeck.Highlight{h},
+		}
+
+		if err := f.write(context.WithoutCancel(ctx), result, operation.Content); err != nil {
+			opType := "failed"
+			if errors.Is(err, ErrWriteVetoed) {
+				opType = "vetoed"
+				slog.Log(ctx, f.problemLevel(), "a hook vetoed the highlight note", "bookmark_id", source.Bookmark.ID, "path", result.Note.Path, "error", err)
+			} else {
+				slog.Log(ctx, f.problemLevel(), "could not write the highlight note", "bookmark_id", source.Bookmark.ID, "path", result.Note.Path, "error", err)
+			}
+			results = append(results, OperationResult{Type: opType, Note: result.Note, Err: err})
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func (f *FileNoteRepository) processNote(ctx context.Context, note model.Note, lookup map[string]model.ParsedNote) (OperationResult, error) {
 	bookmarkID := note.Bookmark.ID
 	existingNote, exists := lookup[bookmarkID]
 
 	if exists {
-		updatedNote, highlightsAdded, err := f.updateNote(existingNote, note)
+		result, err := f.updateNote(ctx, existingNote, note)
 		if err != nil {
 			return OperationResult{}, fmt.Errorf("could not update note %s (%s): %w",
 				bookmarkID, existingNote.Path, err)
 		}
-
-		opType := "updated"
-		if highlightsAdded == 0 {
-			opType = "unchanged"
-		}
-
-		return OperationResult{
-			Type:            opType,
-			Note:            updatedNote,
-			HighlightsAdded: highlightsAdded,
-		}, nil
+		return result, nil
 	}
 
-	newNote, err := f.createNote(note)
+	newNote, err := f.createNote(ctx, note)
 	if err != nil {
 		return OperationResult{}, fmt.Errorf("could not create note %s: %w",
 			bookmarkID, err)
@@ -98,11 +232,14 @@ func (f *FileNoteRepository) processNote(note model.Note, lookup map[string]mode
 		Type:            "created",
 		Note:            newNote,
 		HighlightsAdded: len(note.Highlights),
+		NewHighlights:   note.Highlights,
 	}, nil
 }
 
-func (f *FileNoteRepository) updateNote(existingNote model.ParsedNote, note model.Note) (model.Note, int, error) {
-	newHighlightsCount := 0
+// updateNote rewrites the note when the updater has new content for it. A rewritten note is
+// "updated" even without new highlights (eg. a backfilled citekey), so it is committed like any other change.
+func (f *FileNoteRepository) updateNote(ctx context.Context, existingNote model.ParsedNote, note model.Note) (OperationResult, error) {
+	var newHighlights []readdeck.Highlight
 	existingIDs := make(map[string]bool)
 	for _, id := range existingNote.HighlightIDs {
 		existingIDs[id] = true
@@ -110,31 +247,36 @@ func (f *FileNoteRepository) updateNote(existingNote model.ParsedNote, note mode
 
 	for _, h := range note.Highlights {
 		if !existingIDs[h.ID] {
-			newHighlightsCount++
+			newHighlights = append(newHighlights, h)
 		}
 	}
 
 	op, err := f.noteService.UpdateNoteContent(existingNote, note)
 	if err != nil {
-		return model.Note{}, 0, fmt.Errorf("could not generate bytes for update: %w", err)
+		return OperationResult{}, fmt.Errorf("could not generate bytes for update: %w", err)
 	}
 
+	updated := note
+	updated.Path = existingNote.Path
+
 	if len(op.Content) == 0 {
-		return note, 0, nil
+		return OperationResult{Type: "unchanged", Note: updated}, nil
 	}
 
-	result := note
-	result.Path = existingNote.Path
-
-	err = f.writeBytes(op.Content, existingNote.Path)
-	if err != nil {
-		return model.Note{}, 0, err
+	result := OperationResult{
+		Type:            "updated",
+		Note:            updated,
+		HighlightsAdded: len(newHighlights),
+		NewHighlights:   newHighlights,
+	}
+	if err := f.write(ctx, result, op.Content); err != nil {
+		return OperationResult{}, err
 	}
 
-	return result, newHighlightsCount, nil
+	return result, nil
 }
 
-func (f *FileNoteRepository) createNote(note model.Note) (model.Note, error) {
+func (f *FileNoteRepository) createNote(ctx context.Context, note model.Note) (model.Note, error) {
 	operation, err := f.noteService.GenerateNoteContent(note)
 	if err != nil {
 		return model.Note{}, fmt.Errorf("could not generate bytes for creation: %w", err)
@@ -143,10 +285,15 @@ func (f *FileNoteRepository) createNote(note model.Note) (model.Note, error) {
 	// TODO: Make immutable
 	result := note
 
-	notePath := fmt.Sprintf("%s/%s.md", f.fleetingPath, operation.Metadata.ID)
+	notePath := fmt.Sprintf("%s/%s%s", f.fleetingPath, operation.Metadata.ID, f.extension)
 	result.Path = notePath
 
-	err = f.writeBytes(operation.Content, notePath)
+	err = f.write(ctx, OperationResult{
+		Type:            "created",
+		Note:            result,
+		HighlightsAdded: len(note.Highlights),
+		NewHighlights:   note.Highlights,
+	}, operation.Content)
 	if err != nil {
 		return model.Note{}, err
 	}
@@ -154,6 +301,25 @@ func (f *FileNoteRepository) createNote(note model.Note) (model.Note, error) {
 	return result, nil
 }
 
+// write passes the content through the hook, if any, before writing it to the path of the note
+func (f *FileNoteRepository) write(ctx context.Context, result OperationResult, content []byte) error {
+	if f.hook == nil {
+		return f.writeBytes(content, result.Note.Path)
+	}
+
+	content, err := f.hook.BeforeWrite(ctx, result, content)
+	if err != nil {
+		return err
+	}
+
+	if err := f.writeBytes(content, result.Note.Path); err != nil {
+		return err
+	}
+
+	f.hook.AfterWrite(ctx, result)
+	return nil
+}
+
 func (f *FileNoteRepository) writeBytes(bytes []byte, path string) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -177,13 +343,24 @@ func (f *FileNoteRepository) writeBytes(bytes []byte, path string) error {
 func (f *FileNoteRepository) createLookup(parsedNotes []model.ParsedNote) map[string]model.ParsedNote {
 	lookup := make(map[string]model.ParsedNote, len(parsedNotes))
 	for _, p := range parsedNotes {
-		if p.Metadata.ReaddeckID != "" {
+		if p.Metadata.ReaddeckID != "" && p.Metadata.HighlightID == "" {
 			lookup[p.Metadata.ReaddeckID] = p
 		}
 	}
 	return lookup
 }
 
+// createHighlightLookup indexes the atomic notes on the ID of their highlight
+func (f *FileNoteRepository) createHighlightLookup(parsedNotes []model.ParsedNote) map[string]model.ParsedNote {
+	lookup := make(map[string]model.ParsedNote)
+	for _, p := range parsedNotes {
+		if p.Metadata.HighlightID != "" {
+			lookup[p.Metadata.HighlightID] = p
+		}
+	}
+	return lookup
+}
+
 func (f *FileNoteRepository) findNotesInDirectory(dirPath string) ([]string, error) {
 	notePaths := make([]string, 0)
 
@@ -199,7 +376,11 @@ func (f *FileNoteRepository) findNotesInDirectory(dirPath string) ([]string, err
 			return nil
 		}
 
-		if !d.IsDir() && strings.HasSuffix(path, ".md") {
+		if d.IsDir() && f.excludedPaths[filepath.Clean(path)] {
+			return filepath.SkipDir
+		}
+
+		if !d.IsDir() && strings.HasSuffix(path, f.extension) {
 			notePaths = append(notePaths, path)
 		}
 
@@ -217,7 +398,8 @@ func (f *FileNoteRepository) readNoteFiles(filePaths []string) ([]model.ParsedNo
 	results := make([]model.ParsedNote, 0, len(filePaths))
 	skippedPaths := make(map[string]error)
 
-	for _, path := range filePaths {
+	for i, path := range filePaths {
+		f.progress.Report(progress.Event{Stage: progress.Files, Done: i + 1, Total: len(filePaths)})
 		note, err := f.readNoteFile(path)
 		if err != nil {
 			skippedPaths[path] = err
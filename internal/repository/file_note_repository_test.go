@@ -1,13 +1,18 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -102,6 +107,19 @@ func TestFileNoteRepository_findNotesInDirectory(t *testing.T) {
 	}
 }
 
+func TestFileNoteRepository_findNotesInDirectory_ExcludedPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "literature"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "note.md"), []byte(""), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "literature", "other.md"), []byte(""), 0644))
+
+	f := NewFileNoteRepository(tempDir, nil, false, WithExcludedPaths(filepath.Join(tempDir, "literature/")))
+	got, err := f.findNotesInDirectory(tempDir)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tempDir, "note.md")}, got)
+}
+
 type mockNoteParser struct{}
 
 func (m *mockNoteParser) ParseNote(content []byte, path string) (model.ParsedNote, error) {
@@ -192,3 +210,347 @@ func TestFileNoteRepository_createLookup(t *testing.T) {
 		})
 	}
 }
+
+func TestFileNoteRepository_createHighlightLookup(t *testing.T) {
+	source := model.ParsedNote{Path: "source", Metadata: model.NoteMetadata{ID: "id1", ReaddeckID: "readdeck1"}}
+	atomic := model.ParsedNote{Path: "atomic", Metadata: model.NoteMetadata{ID: "id2", ReaddeckID: "readdeck1", HighlightID: "h1"}}
+
+	f := NewFileNoteRepository("", nil, false)
+
+	assert.Equal(t, map[string]model.ParsedNote{"readdeck1": source}, f.createLookup([]model.ParsedNote{source, atomic}))
+	assert.Equal(t, map[string]model.ParsedNote{"h1": atomic}, f.createHighlightLookup([]model.ParsedNote{source, atomic}))
+}
+
+func TestFileNoteRepository_UpsertAll_AtomicNotes(t *testing.T) {
+	tempDir := t.TempDir()
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	formatter.LinkHighlights = true
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	service := NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser))
+	repo := NewFileNoteRepository(tempDir, service, false, WithHighlightNotes(NewHighlightNoteGenerator(generator)))
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness"}
+	h1 := readdeck.Highlight{ID: "h1", Text: "The most striking example I know of schlep blindness is Stripe", Color: "green", Created: created}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Ugly problems", Color: "yellow", Created: created.Add(time.Hour)}
+
+	results, err := repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "created", results[0].Type)
+	assert.Equal(t, "created", results[1].Type)
+
+	atomicPath := filepath.Join(tempDir, HighlightNoteID(h1)+".md")
+	assert.Equal(t, atomicPath, results[1].Note.Path)
+
+	source, err := os.ReadFile(results[0].Note.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(source), "[["+HighlightNoteID(h1)+"]]")
+
+	atomic, err := os.ReadFile(atomicPath)
+	require.NoError(t, err)
+	parsed, err := parser.ParseNote(atomic, atomicPath)
+	require.NoError(t, err)
+	assert.Equal(t, "h1", parsed.Metadata.HighlightID)
+	assert.Equal(t, "b1", parsed.Metadata.ReaddeckID)
+	assert.Equal(t, "green", parsed.Metadata.Color)
+	assert.Contains(t, string(atomic), "> The most striking example I know of schlep blindness is Stripe")
+
+	// Second run: the source note gets updated, only the new highlight gets a note
+	results, err = repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "updated", results[0].Type)
+	assert.Equal(t, []readdeck.Highlight{h2}, results[0].NewHighlights)
+	assert.Equal(t, "created", results[1].Type)
+	assert.Equal(t, "h2", results[1].Note.Highlights[0].ID)
+
+	// Third run: nothing to do
+	results, err = repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "unchanged", results[0].Type)
+	assert.Empty(t, results[0].NewHighlights)
+}
+
+func TestFileNoteRepository_UpsertAll_AtomicNotesDoNotCollide(t *testing.T) {
+	tempDir := t.TempDir()
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	service := NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser))
+	repo := NewFileNoteRepository(tempDir, service, false, WithHighlightNotes(NewHighlightNoteGenerator(generator)))
+
+	// Same second and same first words, and text that has no latin characters to slug
+	highlights := []readdeck.Highlight{
+		{ID: "h1", Text: "The most striking example I know of schlep blindness is Stripe", Color: "yellow", Created: created},
+		{ID: "h2", Text: "The most striking example I know of schlep blindness is Airbnb", Color: "yellow", Created: created},
+		{ID: "h3", Text: "東京は日本の首都です", Color: "yellow", Created: created},
+		{ID: "h4", Text: "Москва столица России", Color: "yellow", Created: created},
+	}
+
+	results, err := repo.UpsertAll(context.Background(), []model.Note{{Bookmark: readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness"}, Highlights: highlights}})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	for i, h := range highlights {
+		atomic, err := os.ReadFile(results[i+1].Note.Path)
+		require.NoError(t, err)
+		parsed, err := parser.ParseNote(atomic, results[i+1].Note.Path)
+		require.NoError(t, err)
+		assert.Equal(t, h.ID, parsed.Metadata.HighlightID)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
+
+func TestFileNoteRepository_UpsertAll_Cancelled(t *testing.T) {
+	tempDir := t.TempDir()
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	repo := NewFileNoteRepository(tempDir, NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser)), false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	note := model.Note{
+		Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness"},
+		Highlights: []readdeck.Highlight{{ID: "h1", Text: "Ugly problems", Color: "yellow"}},
+	}
+	results, err := repo.UpsertAll(ctx, []model.Note{note})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// interruptingHook interrupts the export while the first note is being written
+type interruptingHook struct {
+	cancel context.CancelFunc
+	errs   []error
+}
+
+func (h *interruptingHook) BeforeWrite(ctx context.Context, result OperationResult, content []byte) ([]byte, error) {
+	h.cancel()
+	h.errs = append(h.errs, ctx.Err())
+	return content, nil
+}
+
+func (h *interruptingHook) AfterWrite(ctx context.Context, result OperationResult) {
+	h.errs = append(h.errs, ctx.Err())
+}
+
+func TestFileNoteRepository_UpsertAll_InterruptedMidNote(t *testing.T) {
+	tempDir := t.TempDir()
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hook := &interruptingHook{cancel: cancel}
+	repo := NewFileNoteRepository(tempDir, NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser)), false, WithWriteHook(hook))
+
+	notes := []model.Note{
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h1", Text: "Ugly problems", Color: "yellow"}},
+		},
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b2", Title: "Great Work", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h2", Text: "Curiosity", Color: "yellow"}},
+		},
+	}
+	results, err := repo.UpsertAll(ctx, notes)
+
+	// The hooks of the first note still run to the end, the second note isn't started
+	assert.ErrorIs(t, err, context.Canceled)
+	require.Len(t, results, 1)
+	assert.Equal(t, "created", results[0].Type)
+	assert.Equal(t, []error{nil, nil}, hook.errs)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+type recordingHook struct {
+	veto    string
+	fail    string
+	written []string
+}
+
+var errHookFailed = errors.New("hook failed")
+
+func (h *recordingHook) BeforeWrite(ctx context.Context, result OperationResult, content []byte) ([]byte, error) {
+	switch result.Note.Bookmark.ID {
+	case h.veto:
+		return nil, ErrWriteVetoed
+	case h.fail:
+		return nil, errHookFailed
+	}
+	return append(content, []byte("\nformatted\n")...), nil
+}
+
+func (h *recordingHook) AfterWrite(ctx context.Context, result OperationResult) {
+	h.written = append(h.written, result.Type+" "+result.Note.Bookmark.ID)
+}
+
+func TestFileNoteRepository_UpsertAll_WriteHook(t *testing.T) {
+	tempDir := t.TempDir()
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	hook := &recordingHook{veto: "b2"}
+	repo := NewFileNoteRepository(tempDir, NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser)), false, WithWriteHook(hook))
+
+	notes := []model.Note{
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h1", Text: "Ugly problems", Color: "yellow"}},
+		},
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b2", Title: "Vetoed", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h2", Text: "Never written", Color: "yellow"}},
+		},
+	}
+	results, err := repo.UpsertAll(context.Background(), notes)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "b1", results[0].Note.Bookmark.ID)
+	assert.Equal(t, "vetoed", results[1].Type)
+	assert.Equal(t, []string{"created b1"}, hook.written)
+
+	content, err := os.ReadFile(results[0].Note.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "formatted")
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileNoteRepository_UpsertAll_FailedNote(t *testing.T) {
+	tempDir := t.TempDir()
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	hook := &recordingHook{veto: "b2", fail: "b3"}
+	repo := NewFileNoteRepository(tempDir, NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser)), false, WithWriteHook(hook))
+
+	notes := []model.Note{
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h1", Text: "Ugly problems", Color: "yellow"}},
+		},
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b2", Title: "Vetoed", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h2", Text: "Never written", Color: "yellow"}},
+		},
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b3", Title: "Failed", Created: time.Now()},
+			Highlights: []readdeck.Highlight{{ID: "h3", Text: "Not written either", Color: "yellow"}},
+		},
+	}
+	results, err := repo.UpsertAll(context.Background(), notes)
+	require.NoError(t, err)
+
+	// Neither a vetoed nor a failed note is written, both are reported with their error
+	require.Len(t, results, 3)
+	assert.Equal(t, "created", results[0].Type)
+	assert.NoError(t, results[0].Err)
+
+	assert.Equal(t, "vetoed", results[1].Type)
+	assert.Equal(t, "b2", results[1].Note.Bookmark.ID)
+	assert.ErrorIs(t, results[1].Err, ErrWriteVetoed)
+	assert.Zero(t, results[1].HighlightsAdded)
+
+	assert.Equal(t, "failed", results[2].Type)
+	assert.Equal(t, "b3", results[2].Note.Bookmark.ID)
+	assert.ErrorIs(t, results[2].Err, errHookFailed)
+	assert.Zero(t, results[2].HighlightsAdded)
+	assert.Equal(t, []string{"created b1"}, hook.written)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileNoteRepository_UpsertAll_PartialExports(t *testing.T) {
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()}
+	h1 := readdeck.Highlight{ID: "h1", Text: "Yellow one", Color: "yellow"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Green two", Color: "green"}
+
+	for _, format := range []NoteFormat{MarkdownFormat, LogseqFormat, OrgFormat} {
+		t.Run(string(format), func(t *testing.T) {
+			tempDir := t.TempDir()
+			formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+			repo := NewFileNoteRepository(tempDir, NewFormatNoteService(format, formatter, "https://read.example.com"), false, WithExtension(format.Extension()))
+
+			// Filtered exports bring a part of the highlights, the full export all of them
+			for _, highlights := range [][]readdeck.Highlight{{h1}, {h2}, {h1, h2}, {h1, h2}} {
+				_, err := repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: highlights}})
+				require.NoError(t, err)
+			}
+
+			entries, err := os.ReadDir(tempDir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+
+			content, err := os.ReadFile(filepath.Join(tempDir, entries[0].Name()))
+			require.NoError(t, err)
+			assert.Equal(t, 1, strings.Count(string(content), "Yellow one"))
+			assert.Equal(t, 1, strings.Count(string(content), "Green two"))
+		})
+	}
+}
+
+func TestFileNoteRepository_UpsertAll_BackfillsCitekey(t *testing.T) {
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()}
+	h1 := readdeck.Highlight{ID: "h1", Text: "Yellow one", Color: "yellow"}
+
+	for _, format := range []NoteFormat{MarkdownFormat, LogseqFormat, OrgFormat} {
+		t.Run(string(format), func(t *testing.T) {
+			tempDir := t.TempDir()
+			formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+			repo := NewFileNoteRepository(tempDir, NewFormatNoteService(format, formatter, "https://read.example.com"), false, WithExtension(format.Extension()))
+
+			// Written before citations were tracked
+			results, err := repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}}})
+			require.NoError(t, err)
+			before, err := os.ReadFile(results[0].Note.Path)
+			require.NoError(t, err)
+			assert.NotContains(t, string(before), "graham2012schlep")
+
+			// No new highlights, the note still gets its citekey and is reported as updated, so it is committed
+			results, err = repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}, Citekey: "graham2012schlep"}})
+			require.NoError(t, err)
+			assert.Equal(t, "updated", results[0].Type)
+			assert.Zero(t, results[0].HighlightsAdded)
+			after, err := os.ReadFile(results[0].Note.Path)
+			require.NoError(t, err)
+			assert.Contains(t, string(after), "graham2012schlep")
+			assert.Equal(t, 1, strings.Count(string(after), "Yellow one"))
+
+			// Once it is there, the note is left alone
+			unchanged, err := repo.UpsertAll(context.Background(), []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}, Citekey: "graham2012schlep"}})
+			require.NoError(t, err)
+			assert.Equal(t, "unchanged", unchanged[0].Type)
+			again, err := os.ReadFile(results[0].Note.Path)
+			require.NoError(t, err)
+			assert.Equal(t, string(after), string(again))
+		})
+	}
+}
@@ -28,28 +28,35 @@ func DefaultColorConfig() ColorConfig {
 
 type HighlightFormatter struct {
 	ColorConfig ColorConfig
+	Order       readdeck.HighlightOrder
+	Grouping    GroupingConfig
+	// LinkHighlights renders a link to the atomic note of a highlight instead of its text
+	LinkHighlights bool
 }
 
-func NewHighlightFormatter(config ColorConfig) *HighlightFormatter {
+func NewHighlightFormatter(config ColorConfig, order readdeck.HighlightOrder) *HighlightFormatter {
 	return &HighlightFormatter{
 		ColorConfig: config,
+		Order:       order,
+		Grouping:    DefaultGroupingConfig(),
 	}
 }
 
-func (f *HighlightFormatter) FormatHighlightsByColor(highlights []readdeck.Highlight) map[string][]byte {
-	groups := f.groupHighlightsByColor(highlights)
-	result := make(map[string][]byte)
-
-	for color, hs := range groups {
-		var sectionBytes []byte
-		sectionBytes = append(sectionBytes, f.highlightTitleBytes(color)...)
-		sectionBytes = append(sectionBytes, f.highlightBodyBytes(hs)...)
-		result[color] = sectionBytes
+// FormatHighlights renders every group of highlights as an H2 section, in group order
+func (f *HighlightFormatter) FormatHighlights(bookmarkType string, highlights []readdeck.Highlight) []byte {
+	var result []byte
+	for _, group := range f.GroupHighlights(bookmarkType, highlights) {
+		result = append(result, f.groupTitleBytes(group)...)
+		result = append(result, f.highlightBodyBytes(group.Highlights)...)
 	}
-
 	return result
 }
 
+// GroupHighlights sorts the highlights and splits them up with the grouper configured for the bookmark type
+func (f *HighlightFormatter) GroupHighlights(bookmarkType string, highlights []readdeck.Highlight) []HighlightGroup {
+	return f.grouper(bookmarkType).Group(f.SortHighlights(highlights))
+}
+
 func (f *HighlightFormatter) GetSortedColorOrder(highlights map[string][]readdeck.Highlight) []string {
 	var result []string
 
@@ -72,20 +79,45 @@ func (f *HighlightFormatter) GetSortedColorOrder(highlights map[string][]readdec
 	return result
 }
 
-func (f *HighlightFormatter) highlightTitleBytes(color string) []byte {
-	friendlyColor := f.colorToFriendlyName(color)
-	return []byte(fmt.Sprintf("## %s\n", friendlyColor))
+// SortHighlights orders highlights the way they should appear within a section
+func (f *HighlightFormatter) SortHighlights(highlights []readdeck.Highlight) []readdeck.Highlight {
+	return readdeck.SortHighlights(highlights, f.Order)
+}
+
+func (f *HighlightFormatter) grouper(bookmarkType string) HighlightGrouper {
+	switch f.Grouping.ModeFor(bookmarkType) {
+	case GroupFlat:
+		return flatGrouper{}
+	case GroupByDate:
+		return dateGrouper{}
+	case GroupBySection:
+		return sectionGrouper{}
+	default:
+		return colorGrouper{formatter: f}
+	}
+}
+
+func (f *HighlightFormatter) groupTitleBytes(group HighlightGroup) []byte {
+	return []byte(fmt.Sprintf("## %s\n", group.Title))
 }
 
 func (f *HighlightFormatter) highlightBodyBytes(highlights []readdeck.Highlight) []byte {
 	var result []byte
 	for _, h := range highlights {
-		highlightBytes := []byte(fmt.Sprintf("%s\n\n", h.Text))
+		highlightBytes := []byte(fmt.Sprintf("%s\n\n", f.HighlightText(h)))
 		result = append(result, highlightBytes...)
 	}
 	return result
 }
 
+// HighlightText is what a note shows for a highlight: its text, or a link to its atomic note
+func (f *HighlightFormatter) HighlightText(h readdeck.Highlight) string {
+	if f.LinkHighlights {
+		return fmt.Sprintf("[[%s]]", HighlightNoteID(h))
+	}
+	return h.Text
+}
+
 func (f *HighlightFormatter) groupHighlightsByColor(highlights []readdeck.Highlight) map[string][]readdeck.Highlight {
 	result := make(map[string][]readdeck.Highlight)
 	for _, h := range highlights {
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+type GroupingMode string
+
+const (
+	// GroupByColor creates a section per colour, named after the ColorConfig
+	GroupByColor GroupingMode = "color"
+	// GroupFlat puts every highlight in a single section
+	GroupFlat GroupingMode = "flat"
+	// GroupByDate creates a section per day the highlights were made
+	GroupByDate GroupingMode = "date"
+	// GroupBySection creates a section per heading of the article
+	GroupBySection GroupingMode = "section"
+)
+
+const (
+	flatGroupTitle      = "Highlights"
+	introGroupTitle     = "Introduction"
+	unplacedGroupTitle  = "Other highlights"
+	dateGroupTimeLayout = "2006-01-02"
+	// referencesTitle is the section every note ends with, no group may take its title
+	referencesTitle = "References"
+)
+
+func ParseGroupingMode(input string) (GroupingMode, error) {
+	switch GroupingMode(strings.ToLower(strings.TrimSpace(input))) {
+	case GroupByColor, "":
+		return GroupByColor, nil
+	case GroupFlat:
+		return GroupFlat, nil
+	case GroupByDate:
+		return GroupByDate, nil
+	case GroupBySection:
+		return GroupBySection, nil
+	default:
+		return "", fmt.Errorf("unknown grouping %q, expected one of: color, flat, date, section", input)
+	}
+}
+
+// GroupingConfig holds the default grouping and the overrides per bookmark type (article, video, photo)
+type GroupingConfig struct {
+	Default GroupingMode
+	ByType  map[string]GroupingMode
+}
+
+func DefaultGroupingConfig() GroupingConfig {
+	return GroupingConfig{
+		Default: GroupByColor,
+		ByType:  map[string]GroupingMode{},
+	}
+}
+
+func (c GroupingConfig) ModeFor(bookmarkType string) GroupingMode {
+	if mode, ok := c.ByType[bookmarkType]; ok {
+		return mode
+	}
+	if c.Default == "" {
+		return GroupByColor
+	}
+	return c.Default
+}
+
+// Uses reports whether any bookmark type is grouped with the given mode
+func (c GroupingConfig) Uses(mode GroupingMode) bool {
+	if c.Default == mode {
+		return true
+	}
+	for _, m := range c.ByType {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+type HighlightGroup struct {
+	Key        string
+	Title      string
+	Highlights []readdeck.Highlight
+}
+
+// HighlightGrouper splits (already sorted) highlights into ordered groups.
+// Titles must be stable, the updater relies on them to find existing sections.
+type HighlightGrouper interface {
+	Group(highlights []readdeck.Highlight) []HighlightGroup
+}
+
+type colorGrouper struct {
+	formatter *HighlightFormatter
+}
+
+func (g colorGrouper) Group(highlights []readdeck.Highlight) []HighlightGroup {
+	byColor := g.formatter.groupHighlightsByColor(highlights)
+	result := make([]HighlightGroup, 0, len(byColor))
+
+	for _, color := range g.formatter.GetSortedColorOrder(byColor) {
+		result = append(result, HighlightGroup{
+			Key:        color,
+			Title:      g.formatter.colorToFriendlyName(color),
+			Highlights: byColor[color],
+		})
+	}
+
+	return result
+}
+
+type flatGrouper struct{}
+
+func (g flatGrouper) Group(highlights []readdeck.Highlight) []HighlightGroup {
+	if len(highlights) == 0 {
+		return nil
+	}
+
+	return []HighlightGroup{{Key: flatGroupTitle, Title: flatGroupTitle, Highlights: highlights}}
+}
+
+type dateGrouper struct{}
+
+func (g dateGrouper) Group(highlights []readdeck.Highlight) []HighlightGroup {
+	return groupInOrder(highlights, func(h readdeck.Highlight) string {
+		return h.Created.Local().Format(dateGroupTimeLayout)
+	}, true)
+}
+
+type sectionGrouper struct{}
+
+func (g sectionGrouper) Group(highlights []readdeck.Highlight) []HighlightGroup {
+	return groupInOrder(highlights, func(h readdeck.Highlight) string {
+		switch {
+		case h.Chapter == "" && h.Position == 0:
+			// Not found in the article (or without a selector), so there's no telling where it belongs
+			return unplacedGroupTitle
+		case h.Chapter == "":
+			return introGroupTitle
+		case h.Chapter == referencesTitle:
+			// The updater would take the chapter for the section with the links to the bookmark
+			return referencesTitle + " (chapter)"
+		}
+		return h.Chapter
+	}, false)
+}
+
+// groupInOrder groups on a key, groups appear in order of their first highlight
+// unless sortKeys is set, then they are ordered on the key itself
+func groupInOrder(highlights []readdeck.Highlight, keyOf func(readdeck.Highlight) string, sortKeys bool) []HighlightGroup {
+	var result []HighlightGroup
+	indexes := make(map[string]int)
+
+	for _, h := range highlights {
+		key := keyOf(h)
+		i, ok := indexes[key]
+		if !ok {
+			i = len(result)
+			indexes[key] = i
+			result = append(result, HighlightGroup{Key: key, Title: key})
+		}
+		result[i].Highlights = append(result[i].Highlights, h)
+	}
+
+	if sortKeys {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Key < result[j].Key
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightFormatter_GroupHighlights(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	day2 := time.Date(2025, 3, 2, 10, 0, 0, 0, time.Local)
+
+	h1 := readdeck.Highlight{ID: "h1", Color: "yellow", Created: day2, StartSelector: "p[1]", Chapter: "", Position: 1}
+	h2 := readdeck.Highlight{ID: "h2", Color: "green", Created: day1, StartSelector: "p[2]", Chapter: "Setup", Position: 3}
+	h3 := readdeck.Highlight{ID: "h3", Color: "yellow", Created: day1, StartSelector: "p[3]", Chapter: "Setup", Position: 4}
+	h4 := readdeck.Highlight{ID: "h4", Color: "purple", Created: day2, StartSelector: "p[4]", Chapter: "Results", Position: 6}
+	// Not found in the article
+	h5 := readdeck.Highlight{ID: "h5", Color: "purple", Created: day1, StartSelector: "p[20]"}
+	highlights := []readdeck.Highlight{h4, h3, h5, h2, h1}
+
+	type group struct {
+		Title string
+		IDs   []string
+	}
+
+	tests := []struct {
+		name string
+		mode GroupingMode
+		want []group
+	}{
+		{
+			name: "color",
+			mode: GroupByColor,
+			want: []group{
+				{"Key takeaways", []string{"h2"}},
+				{"General highlights", []string{"h1", "h3"}},
+				{"Purple highlights", []string{"h4", "h5"}},
+			},
+		},
+		{
+			name: "flat",
+			mode: GroupFlat,
+			want: []group{
+				{"Highlights", []string{"h1", "h2", "h3", "h4", "h5"}},
+			},
+		},
+		{
+			name: "date",
+			mode: GroupByDate,
+			want: []group{
+				{"2025-03-01", []string{"h2", "h3", "h5"}},
+				{"2025-03-02", []string{"h1", "h4"}},
+			},
+		},
+		{
+			name: "section",
+			mode: GroupBySection,
+			want: []group{
+				{"Introduction", []string{"h1"}},
+				{"Setup", []string{"h2", "h3"}},
+				{"Results", []string{"h4"}},
+				{"Other highlights", []string{"h5"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+			f.Grouping = GroupingConfig{Default: tt.mode}
+
+			var got []group
+			for _, g := range f.GroupHighlights("article", highlights) {
+				ids := make([]string, len(g.Highlights))
+				for i, h := range g.Highlights {
+					ids[i] = h.ID
+				}
+				got = append(got, group{g.Title, ids})
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGroupingConfig_ModeFor(t *testing.T) {
+	c := GroupingConfig{
+		Default: GroupBySection,
+		ByType:  map[string]GroupingMode{"video": GroupFlat},
+	}
+
+	assert.Equal(t, GroupBySection, c.ModeFor("article"))
+	assert.Equal(t, GroupFlat, c.ModeFor("video"))
+	assert.True(t, c.Uses(GroupFlat))
+	assert.False(t, c.Uses(GroupByDate))
+	assert.Equal(t, GroupByColor, GroupingConfig{}.ModeFor("article"))
+}
+
+func TestYAMLNoteUpdater_UpdateNoteContent_DateGrouping(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	day2 := time.Date(2025, 3, 2, 10, 0, 0, 0, time.Local)
+
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderCreated)
+	formatter.Grouping = GroupingConfig{Default: GroupByDate}
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	updater := NewYAMLNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Title", Type: "article"}
+	first := readdeck.Highlight{ID: "h1", Text: "First", Created: day1}
+	second := readdeck.Highlight{ID: "h2", Text: "Second", Created: day1.Add(time.Hour)}
+	third := readdeck.Highlight{ID: "h3", Text: "Third", Created: day2}
+
+	created, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{first}})
+	require.NoError(t, err)
+
+	existing, err := parser.ParseNote(created.Content, "note.md")
+	require.NoError(t, err)
+
+	updated, err := updater.UpdateNoteContent(existing, model.Note{
+		Bookmark:   bookmark,
+		Highlights: []readdeck.Highlight{first, second, third},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, string(updated.Content), "## 2025-03-01\nFirst\n\nSecond\n\n## 2025-03-02\nThird\n\n## References\n")
+}
+
+func TestYAMLNoteUpdater_UpdateNoteContent_ReferencesChapter(t *testing.T) {
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	formatter.Grouping = GroupingConfig{Default: GroupBySection}
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	updater := NewYAMLNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Title", Type: "article", SiteUrl: "https://example.com"}
+	first := readdeck.Highlight{ID: "h1", Text: "First", Chapter: "Method"}
+	second := readdeck.Highlight{ID: "h2", Text: "Second", Chapter: "References"}
+	third := readdeck.Highlight{ID: "h3", Text: "Third", Chapter: "References"}
+
+	created, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{first, second}})
+	require.NoError(t, err)
+
+	existing, err := parser.ParseNote(created.Content, "note.md")
+	require.NoError(t, err)
+
+	updated, err := updater.UpdateNoteContent(existing, model.Note{
+		Bookmark:   bookmark,
+		Highlights: []readdeck.Highlight{first, second, third},
+	})
+	require.NoError(t, err)
+
+	// The chapter is kept apart from the references of the note, which stay last
+	content := string(updated.Content)
+	assert.Contains(t, content, "## Method\nFirst\n\n## References (chapter)\nSecond\n\nThird\n\n## References\n[Title](https://example.com)\n")
+	assert.Equal(t, 1, strings.Count(content, "## References\n"))
+}
+
+func TestYAMLNoteUpdater_UpdateNoteContent_NewChapterInArticleOrder(t *testing.T) {
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	formatter.Grouping = GroupingConfig{Default: GroupBySection}
+	parser := NewYAMLNoteParser()
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	updater := NewYAMLNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Title", Type: "article"}
+	setup := readdeck.Highlight{ID: "h1", Text: "Setup", Chapter: "Setup", Position: 2}
+	method := readdeck.Highlight{ID: "h2", Text: "Method", Chapter: "Method", Position: 5}
+	results := readdeck.Highlight{ID: "h3", Text: "Results", Chapter: "Results", Position: 9}
+	lost := readdeck.Highlight{ID: "h4", Text: "Lost"}
+
+	created, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{setup, results}})
+	require.NoError(t, err)
+
+	existing, err := parser.ParseNote(created.Content, "note.md")
+	require.NoError(t, err)
+
+	updated, err := updater.UpdateNoteContent(existing, model.Note{
+		Bookmark:   bookmark,
+		Highlights: []readdeck.Highlight{setup, method, results, lost},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, string(updated.Content), "## Setup\nSetup\n\n## Method\nMethod\n\n## Results\nResults\n\n## Other highlights\nLost\n\n## References\n")
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+const highlightTitleWords = 8
+
+const highlightIDHashLength = 8
+
+// HighlightNoteID is derived from the highlight itself so that the source note
+// can link to an atomic note before it has been written. It holds a short hash of
+// the highlight ID, the excerpt and the creation time alone are not unique: text
+// without latin characters slugs to nothing and highlights can share a second.
+func HighlightNoteID(h readdeck.Highlight) string {
+	sum := sha1.Sum([]byte(h.ID))
+	key := hex.EncodeToString(sum[:])[:highlightIDHashLength]
+	return util.GenerateId(key+" "+highlightExcerpt(h.Text, highlightTitleWords), h.Created)
+}
+
+// HighlightNoteGenerator creates atomic (Zettelkasten) notes, holding a single highlight
+type HighlightNoteGenerator struct {
+	Generator *YAMLNoteGenerator
+}
+
+func NewHighlightNoteGenerator(generator *YAMLNoteGenerator) *HighlightNoteGenerator {
+	return &HighlightNoteGenerator{
+		Generator: generator,
+	}
+}
+
+// GenerateHighlightNote creates the note of a single highlight. sourceID is the ID of the
+// bookmark note that lists all highlights, the atomic note links back to it.
+func (g *HighlightNoteGenerator) GenerateHighlightNote(h readdeck.Highlight, bookmark readdeck.Bookmark, sourceID string) (NoteOperation, error) {
+	metadata, err := g.Generator.generateMetadata(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h}})
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	title := highlightExcerpt(h.Text, highlightTitleWords)
+	friendlyColor := g.Generator.HighlightFormatter.colorToFriendlyName(h.Color)
+
+	metadata.ID = HighlightNoteID(h)
+	metadata.Aliases = []string{util.Capitalize(title)}
+	metadata.Created = model.SimpleTime{Time: h.Created}
+	metadata.HighlightID = h.ID
+	metadata.Color = h.Color
+	if sourceID != "" {
+		metadata.Source = fmt.Sprintf("[[%s]]", sourceID)
+	}
+
+	var content []byte
+
+	frontmatter, err := g.Generator.generateFrontmatter(metadata)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+	content = append(content, frontmatter...)
+
+	content = append(content, []byte(fmt.Sprintf("# %s\n\n", metadata.Aliases[0]))...)
+	content = append(content, []byte(fmt.Sprintf("%s\n\n", quote(h.Text)))...)
+	content = append(content, []byte(fmt.Sprintf("*%s* from %s\n\n", friendlyColor, metadata.Source))...)
+
+	content = append(content, []byte("## "+referencesTitle+"\n")...)
+	content = append(content, g.Generator.generateReferences(metadata)...)
+
+	return NoteOperation{
+		Metadata: metadata,
+		Content:  content,
+	}, nil
+}
+
+func highlightExcerpt(text string, words int) string {
+	fields := strings.Fields(text)
+	if len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(fields[:words], " ") + "..."
+}
+
+func quote(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+type NoteMode string
+
+const (
+	// BookmarkNotes writes a single note per bookmark, holding all of its highlights
+	BookmarkNotes NoteMode = "bookmark"
+	// AtomicNotes writes a note per highlight, plus a source note per bookmark linking to them
+	AtomicNotes NoteMode = "atomic"
+)
+
+func ParseNoteMode(input string) (NoteMode, error) {
+	switch NoteMode(strings.ToLower(strings.TrimSpace(input))) {
+	case BookmarkNotes, "":
+		return BookmarkNotes, nil
+	case AtomicNotes:
+		return AtomicNotes, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected one of: bookmark, atomic", input)
+	}
+}
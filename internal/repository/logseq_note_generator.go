@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// The highlight ID is stored on every block, that's how Logseq notes stay idempotent
+const logseqHighlightProperty = "readdeck-highlight-id"
+
+// Logseq treats some page properties specially (eg. id:: must be a UUID),
+// so a few frontmatter keys are renamed
+var logseqPropertyKeys = map[string]string{
+	"id":      "note-id",
+	"aliases": "alias",
+}
+
+// LogseqNoteGenerator writes a page as an outline of blocks with key:: value properties
+type LogseqNoteGenerator struct {
+	Generator *YAMLNoteGenerator
+}
+
+func NewLogseqNoteGenerator(formatter *HighlightFormatter, baseUrl string) *LogseqNoteGenerator {
+	return &LogseqNoteGenerator{
+		Generator: NewYAMLNoteGenerator(formatter, baseUrl),
+	}
+}
+
+func (g *LogseqNoteGenerator) GenerateNoteContent(note model.Note) (NoteOperation, error) {
+	metadata, err := g.Generator.generateMetadata(note)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	properties, err := g.pageProperties(metadata, noteTitle(note.Bookmark))
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	var content strings.Builder
+	writeLogseqProperties(&content, properties)
+	content.WriteString("\n")
+
+	formatter := g.Generator.HighlightFormatter
+	for _, group := range formatter.GroupHighlights(note.Bookmark.Type, note.Highlights) {
+		content.WriteString(g.groupBlock(group.Title))
+		for _, h := range group.Highlights {
+			content.WriteString(g.highlightBlock(h))
+		}
+	}
+
+	content.WriteString(g.referencesBlock(metadata))
+
+	return NoteOperation{
+		Metadata: metadata,
+		Content:  []byte(content.String()),
+	}, nil
+}
+
+// pageProperties are the metadata as Logseq page properties. The title is passed on its own,
+// an updated note merges the aliases and the first one is not necessarily the current title.
+func (g *LogseqNoteGenerator) pageProperties(metadata model.NoteMetadata, title string) ([]model.Property, error) {
+	properties, err := metadataToProperties(metadata, formatPropertyList)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Property, 0, len(properties)+1)
+	result = append(result, model.Property{Key: "title", Value: title})
+
+	for _, p := range properties {
+		// The IDs live on the blocks, no need for the hash
+		if p.Key == "readdeck-hash" {
+			continue
+		}
+		if key, ok := logseqPropertyKeys[p.Key]; ok {
+			p.Key = key
+		}
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+func (g *LogseqNoteGenerator) groupBlock(title string) string {
+	return fmt.Sprintf("- ## %s\n", title)
+}
+
+func (g *LogseqNoteGenerator) highlightBlock(h readdeck.Highlight) string {
+	text := g.Generator.HighlightFormatter.HighlightText(h)
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	var block strings.Builder
+	block.WriteString(fmt.Sprintf("\t- %s\n", lines[0]))
+	block.WriteString(fmt.Sprintf("\t  %s:: %s\n", logseqHighlightProperty, h.ID))
+	for _, line := range lines[1:] {
+		block.WriteString(fmt.Sprintf("\t  %s\n", line))
+	}
+
+	return block.String()
+}
+
+func (g *LogseqNoteGenerator) referencesBlock(metadata model.NoteMetadata) string {
+	var block strings.Builder
+	block.WriteString(g.groupBlock(referencesTitle))
+	block.WriteString(g.referencesContent(metadata))
+	return block.String()
+}
+
+func (g *LogseqNoteGenerator) referencesContent(metadata model.NoteMetadata) string {
+	return fmt.Sprintf("\t- [%s](%s)\n\t- [Archived article](%s)\n", metadata.Media, metadata.Site, metadata.ArchiveUrl)
+}
+
+func writeLogseqProperties(builder *strings.Builder, properties []model.Property) {
+	for _, p := range properties {
+		builder.WriteString(fmt.Sprintf("%s:: %s\n", p.Key, p.Value))
+	}
+}
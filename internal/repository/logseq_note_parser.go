@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+)
+
+type LogseqNoteParser struct {
+	Validator         *validator.Validate
+	propertyRegex     *regexp.Regexp
+	highlightIDRegex  *regexp.Regexp
+	headingBlockRegex *regexp.Regexp
+}
+
+func NewLogseqNoteParser() *LogseqNoteParser {
+	return &LogseqNoteParser{
+		Validator:         validator.New(),
+		propertyRegex:     regexp.MustCompile(`^([A-Za-z0-9_-]+)::\s?(.*)$`),
+		highlightIDRegex:  regexp.MustCompile(`^\s+` + logseqHighlightProperty + `::\s*(\S+)\s*$`),
+		headingBlockRegex: regexp.MustCompile(`^#{1,6}\s+(.*)$`),
+	}
+}
+
+func (p *LogseqNoteParser) ParseNote(content []byte, path string) (model.ParsedNote, error) {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	properties, rest := p.parsePageProperties(lines)
+	if len(properties) == 0 {
+		return model.ParsedNote{}, fmt.Errorf("could not parse page properties: none found")
+	}
+
+	fields := make(map[string]interface{}, len(properties))
+	for _, property := range properties {
+		fields[p.frontmatterKey(property.Key)] = property.Value
+	}
+
+	metadata, err := propertiesToMetadata(fields, p.Validator)
+	if err != nil {
+		return model.ParsedNote{}, err
+	}
+
+	highlightIDs := make([]string, 0)
+	for _, line := range rest {
+		if matches := p.highlightIDRegex.FindStringSubmatch(line); matches != nil {
+			highlightIDs = append(highlightIDs, matches[1])
+		}
+	}
+
+	return model.ParsedNote{
+		Path:          path,
+		Metadata:      metadata,
+		Content:       p.ParseBlocks(rest),
+		HighlightIDs:  highlightIDs,
+		RawProperties: properties,
+	}, nil
+}
+
+func (p *LogseqNoteParser) parsePageProperties(lines []string) ([]model.Property, []string) {
+	properties := make([]model.Property, 0)
+
+	for i, line := range lines {
+		matches := p.propertyRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return properties, lines[i:]
+		}
+		properties = append(properties, model.Property{Key: matches[1], Value: strings.TrimSpace(matches[2])})
+	}
+
+	return properties, nil
+}
+
+func (p *LogseqNoteParser) frontmatterKey(key string) string {
+	for frontmatterKey, logseqKey := range logseqPropertyKeys {
+		if logseqKey == key {
+			return frontmatterKey
+		}
+	}
+	return key
+}
+
+// ParseBlocks turns every top level block into a section, its children become the content.
+// Heading blocks ("- ## Title") become H2 sections, other blocks keep their text as title.
+func (p *LogseqNoteParser) ParseBlocks(lines []string) []model.Section {
+	var sections []model.Section
+	var current *model.Section
+	var loose strings.Builder
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "- ") {
+			if current != nil {
+				sections = append(sections, *current)
+			}
+
+			title := strings.TrimSpace(strings.TrimPrefix(line, "- "))
+			current = &model.Section{Type: model.None, Title: title}
+			if matches := p.headingBlockRegex.FindStringSubmatch(title); matches != nil {
+				current.Type = model.H2
+				current.Title = strings.TrimSpace(matches[1])
+			}
+			continue
+		}
+
+		if current == nil {
+			if strings.TrimSpace(line) != "" {
+				loose.WriteString(line)
+				loose.WriteString("\n")
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) != "" {
+			current.Content += line + "\n"
+		}
+	}
+
+	if current != nil {
+		sections = append(sections, *current)
+	}
+
+	if loose.Len() > 0 {
+		sections = append([]model.Section{{Type: model.None, Content: loose.String()}}, sections...)
+	}
+
+	return sections
+}
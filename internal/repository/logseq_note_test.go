@@ -0,0 +1,127 @@
+package repository_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogseqNote_RoundTrip(t *testing.T) {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	parser := repository.NewLogseqNoteParser()
+	generator := repository.NewLogseqNoteGenerator(formatter, "https://read.example.com")
+	updater := repository.NewLogseqNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{
+		ID:      "b1",
+		Title:   "Schlep Blindness",
+		Type:    "article",
+		Created: created,
+		SiteUrl: "https://www.paulgraham.com/schlep.html",
+		Authors: []string{"Paul Graham"},
+	}
+	h1 := readdeck.Highlight{ID: "h1", Text: "First paragraph", Color: "yellow", StartSelector: "p[1]"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second paragraph\nspanning lines", Color: "yellow", StartSelector: "p[2]"}
+	h3 := readdeck.Highlight{ID: "h3", Text: "Third paragraph", Color: "yellow", StartSelector: "p[3]"}
+	h4 := readdeck.Highlight{ID: "h4", Text: "A takeaway", Color: "green", StartSelector: "p[4]"}
+
+	op, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h3}})
+	require.NoError(t, err)
+
+	content := string(op.Content)
+	assert.True(t, strings.HasPrefix(content, "title:: Schlep Blindness highlights\nnote-id:: "), content)
+	assert.Contains(t, content, "alias:: [[Schlep Blindness highlights]]\n")
+	assert.Contains(t, content, "tags:: [[highlights]], [[zettelkasten]], [[fleeting-note]]\n")
+	assert.Contains(t, content, "authors:: [[Paul Graham]]\n")
+	assert.Contains(t, content, "readdeck-id:: b1\n")
+	assert.NotContains(t, content, "readdeck-hash")
+	assert.Contains(t, content, "- ## General highlights\n\t- First paragraph\n\t  readdeck-highlight-id:: h1\n\t- Third paragraph\n\t  readdeck-highlight-id:: h3\n")
+	assert.True(t, strings.HasSuffix(content, "- ## References\n\t- [Schlep Blindness](https://www.paulgraham.com/schlep.html)\n\t- [Archived article](https://read.example.com/bookmarks/b1)\n"), content)
+
+	// The user adds a property and a block of their own
+	content = strings.Replace(content, "title::", "status:: reading\ntitle::", 1)
+	content = strings.Replace(content, "- ## References", "- My own thoughts\n\t- Nested thought\n- ## References", 1)
+
+	parsed, err := parser.ParseNote([]byte(content), "note.md")
+	require.NoError(t, err)
+	assert.Equal(t, op.Metadata.ID, parsed.Metadata.ID)
+	assert.Equal(t, "b1", parsed.Metadata.ReaddeckID)
+	assert.Equal(t, []string{"Schlep Blindness highlights"}, parsed.Metadata.Aliases)
+	assert.Equal(t, []string{"h1", "h3"}, parsed.HighlightIDs)
+
+	updated, err := updater.UpdateNoteContent(parsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2, h3, h4}})
+	require.NoError(t, err)
+
+	content = string(updated.Content)
+	assert.True(t, strings.HasPrefix(content, "status:: reading\ntitle::"), content)
+	assert.Contains(t, content, "- ## General highlights\n\t- First paragraph\n\t  readdeck-highlight-id:: h1\n\t- Second paragraph\n\t  readdeck-highlight-id:: h2\n\t  spanning lines\n\t- Third paragraph\n")
+	// The new group goes where a fresh note would have it, the user's own sections stay put
+	assert.Contains(t, content, "- ## Key takeaways\n\t- A takeaway\n\t  readdeck-highlight-id:: h4\n- ## General highlights\n")
+	assert.Contains(t, content, "- My own thoughts\n\t- Nested thought\n- ## References\n")
+
+	reparsed, err := parser.ParseNote(updated.Content, "note.md")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"h1", "h2", "h3", "h4"}, reparsed.HighlightIDs)
+
+	unchanged, err := updater.UpdateNoteContent(reparsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2, h3, h4}})
+	require.NoError(t, err)
+	assert.Empty(t, unchanged.Content)
+}
+
+func TestLogseqNote_ListsWithCommas(t *testing.T) {
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	parser := repository.NewLogseqNoteParser()
+	generator := repository.NewLogseqNoteGenerator(formatter, "https://read.example.com")
+	updater := repository.NewLogseqNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Hackers, Painters", Authors: []string{"Graham, Paul", "Livingston, Jessica"}}
+	h1 := readdeck.Highlight{ID: "h1", Text: "First", Color: "yellow"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second", Color: "yellow"}
+
+	op, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}})
+	require.NoError(t, err)
+	assert.Contains(t, string(op.Content), "authors:: [[Graham, Paul]], [[Livingston, Jessica]]\n")
+
+	parsed, err := parser.ParseNote(op.Content, "note.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hackers, Painters highlights"}, parsed.Metadata.Aliases)
+	assert.Equal(t, []string{"Graham, Paul", "Livingston, Jessica"}, parsed.Metadata.Authors)
+
+	updated, err := updater.UpdateNoteContent(parsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(updated.Content), "title:: Hackers, Painters highlights\n"), string(updated.Content))
+
+	reparsed, err := parser.ParseNote(updated.Content, "note.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hackers, Painters highlights"}, reparsed.Metadata.Aliases)
+	assert.Equal(t, []string{"Graham, Paul", "Livingston, Jessica"}, reparsed.Metadata.Authors)
+}
+
+func TestLogseqNoteParser_PlainLists(t *testing.T) {
+	// Notes written before lists were page refs separate the items with commas
+	content := "title:: Schlep Blindness highlights\nnote-id:: 1742760960-schlep-blindness\nalias:: Schlep Blindness highlights\ntags:: highlights, zettelkasten\nreaddeck-id:: b1\nauthors:: Paul Graham, [[Graham, Paul]]\n\n- ## References\n"
+
+	parsed, err := repository.NewLogseqNoteParser().ParseNote([]byte(content), "note.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Schlep Blindness highlights"}, parsed.Metadata.Aliases)
+	assert.Equal(t, []string{"highlights", "zettelkasten"}, parsed.Metadata.Tags)
+	assert.Equal(t, []string{"Paul Graham", "Graham, Paul"}, parsed.Metadata.Authors)
+}
+
+func TestLogseqNoteParser_ParseNoteErrors(t *testing.T) {
+	parser := repository.NewLogseqNoteParser()
+
+	_, err := parser.ParseNote([]byte("- just a block\n"), "note.md")
+	assert.Error(t, err)
+
+	_, err = parser.ParseNote([]byte("title:: No id\n\n- block\n"), "note.md")
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+type LogseqNoteUpdater struct {
+	Generator *LogseqNoteGenerator
+	Parser    *LogseqNoteParser
+	// Shares the metadata merging and highlight diffing with the markdown updater
+	base *YAMLNoteUpdater
+}
+
+func NewLogseqNoteUpdater(generator *LogseqNoteGenerator, parser *LogseqNoteParser) *LogseqNoteUpdater {
+	return &LogseqNoteUpdater{
+		Generator: generator,
+		Parser:    parser,
+		base:      NewYAMLNoteUpdater(generator.Generator, nil),
+	}
+}
+
+func (u *LogseqNoteUpdater) UpdateNoteContent(existing model.ParsedNote, note model.Note) (NoteOperation, error) {
+	highlights := u.base.getHighlights(existing.HighlightIDs, note.Highlights)
+
+	if len(highlights) == 0 && !u.base.missingCitekey(existing, note) {
+		return NoteOperation{}, nil
+	}
+
+	metadata, err := u.base.updateMetadata(existing, note)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	properties, err := u.Generator.pageProperties(metadata, noteTitle(note.Bookmark))
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	var content strings.Builder
+	writeLogseqProperties(&content, mergeProperties(existing.RawProperties, properties))
+	content.WriteString("\n")
+	content.WriteString(u.appendHighlightsToBlocks(existing.Content, note.Bookmark.Type, note.Highlights, highlights, metadata))
+
+	return NoteOperation{
+		Metadata: metadata,
+		Content:  []byte(content.String()),
+	}, nil
+}
+
+func (u *LogseqNoteUpdater) appendHighlightsToBlocks(sections []model.Section, bookmarkType string, allHighlights []readdeck.Highlight, highlights []readdeck.Highlight, metadata model.NoteMetadata) string {
+	var content strings.Builder
+	formatter := u.Generator.Generator.HighlightFormatter
+
+	newGroups := formatter.GroupHighlights(bookmarkType, highlights)
+	allGroups := formatter.GroupHighlights(bookmarkType, allHighlights)
+	sortedGroups := make(map[string][]readdeck.Highlight)
+	for _, group := range allGroups {
+		sortedGroups[group.Title] = group.Highlights
+	}
+	placer := newGroupPlacer(allGroups, newGroups, sections, model.H2)
+
+	newIDs := make(map[string]bool, len(highlights))
+	for _, h := range highlights {
+		newIDs[h.ID] = true
+	}
+
+	processedGroups := make(map[string]bool)
+	references := ""
+	hasReferences := false
+
+	for _, section := range sections {
+		if section.Type == model.H2 && section.Title == referencesTitle && !hasReferences {
+			references = section.Content
+			hasReferences = true
+			continue
+		}
+
+		if section.Type == model.H2 {
+			for _, group := range placer.before(section.Title) {
+				content.WriteString(u.Generator.groupBlock(group.Title))
+				for _, h := range group.Highlights {
+					content.WriteString(u.Generator.highlightBlock(h))
+				}
+				processedGroups[group.Title] = true
+			}
+		}
+
+		if section.Type == model.H2 && !processedGroups[section.Title] {
+			for _, group := range newGroups {
+				if group.Title == section.Title {
+					section.Content = u.insertBlocks(section.Content, sortedGroups[group.Title], newIDs)
+					processedGroups[group.Title] = true
+					break
+				}
+			}
+		}
+
+		u.writeBlock(&content, section)
+	}
+
+	for _, group := range newGroups {
+		if processedGroups[group.Title] {
+			continue
+		}
+		content.WriteString(u.Generator.groupBlock(group.Title))
+		for _, h := range group.Highlights {
+			content.WriteString(u.Generator.highlightBlock(h))
+		}
+	}
+
+	if !hasReferences {
+		references = u.Generator.referencesContent(metadata)
+	}
+	content.WriteString(u.Generator.groupBlock(referencesTitle))
+	content.WriteString(references)
+
+	return content.String()
+}
+
+func (u *LogseqNoteUpdater) writeBlock(content *strings.Builder, section model.Section) {
+	switch {
+	case section.Type == model.H2:
+		content.WriteString(u.Generator.groupBlock(section.Title))
+	case section.Title != "":
+		content.WriteString("- " + section.Title + "\n")
+	}
+	content.WriteString(section.Content)
+}
+
+// insertBlocks adds the blocks of new highlights after the block of their closest
+// preceding highlight, found through the highlight ID property
+func (u *LogseqNoteUpdater) insertBlocks(content string, sorted []readdeck.Highlight, newIDs map[string]bool) string {
+	prefix, blocks := splitChildBlocks(content)
+
+	for i, h := range sorted {
+		if !newIDs[h.ID] {
+			continue
+		}
+
+		at := len(blocks)
+		if i == 0 {
+			at = 0
+		}
+		for j := i - 1; j >= 0; j-- {
+			if idx := u.findBlock(blocks, sorted[j].ID); idx >= 0 {
+				at = idx + 1
+				break
+			}
+		}
+
+		block := u.Generator.highlightBlock(h)
+		blocks = append(blocks[:at], append([]string{block}, blocks[at:]...)...)
+	}
+
+	return prefix + strings.Join(blocks, "")
+}
+
+func (u *LogseqNoteUpdater) findBlock(blocks []string, highlightID string) int {
+	for i, block := range blocks {
+		for _, line := range strings.Split(block, "\n") {
+			if matches := u.Parser.highlightIDRegex.FindStringSubmatch(line); matches != nil && matches[1] == highlightID {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitChildBlocks splits the content of a section in its direct children,
+// lines before the first child are returned as prefix
+func splitChildBlocks(content string) (string, []string) {
+	var prefix strings.Builder
+	var blocks []string
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "\t- ") {
+			blocks = append(blocks, line)
+			continue
+		}
+		if len(blocks) == 0 {
+			prefix.WriteString(line)
+			continue
+		}
+		blocks[len(blocks)-1] += line
+	}
+
+	return prefix.String(), blocks
+}
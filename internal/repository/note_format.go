@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NoteFormat string
+
+const (
+	// MarkdownFormat writes YAML frontmatter and H2 sections
+	MarkdownFormat NoteFormat = "markdown"
+	// LogseqFormat writes an outline of blocks with key:: value properties
+	LogseqFormat NoteFormat = "logseq"
+	// OrgFormat writes org-roam nodes with a property drawer and headlines
+	OrgFormat NoteFormat = "org"
+)
+
+func ParseNoteFormat(input string) (NoteFormat, error) {
+	switch NoteFormat(strings.ToLower(strings.TrimSpace(input))) {
+	case MarkdownFormat, "":
+		return MarkdownFormat, nil
+	case LogseqFormat:
+		return LogseqFormat, nil
+	case OrgFormat:
+		return OrgFormat, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected one of: markdown, logseq, org", input)
+	}
+}
+
+// Extension is the file extension of notes in this format, including the dot
+func (f NoteFormat) Extension() string {
+	if f == OrgFormat {
+		return ".org"
+	}
+	return ".md"
+}
+
+// NewFormatNoteService creates the parser, generator and updater of a format
+func NewFormatNoteService(format NoteFormat, formatter *HighlightFormatter, baseUrl string) NoteService {
+	switch format {
+	case LogseqFormat:
+		parser := NewLogseqNoteParser()
+		generator := NewLogseqNoteGenerator(formatter, baseUrl)
+		updater := NewLogseqNoteUpdater(generator, parser)
+		return NewCustomNoteService(parser, generator, updater)
+	case OrgFormat:
+		parser := NewOrgNoteParser()
+		generator := NewOrgNoteGenerator(formatter, baseUrl)
+		updater := NewOrgNoteUpdater(generator, parser)
+		return NewCustomNoteService(parser, generator, updater)
+	default:
+		parser := NewYAMLNoteParser()
+		generator := NewYAMLNoteGenerator(formatter, baseUrl)
+		updater := NewYAMLNoteUpdater(generator, parser)
+		return NewCustomNoteService(parser, generator, updater)
+	}
+}
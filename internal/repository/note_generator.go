@@ -40,7 +40,7 @@ func NewYAMLNoteGenerator(formatter *HighlightFormatter, baseUrl string) *YAMLNo
 }
 
 func (g *YAMLNoteGenerator) GenerateNoteContent(note model.Note) (NoteOperation, error) {
-	metadata, err := g.generateMetadata(note.Bookmark, note.Highlights)
+	metadata, err := g.generateMetadata(note)
 	if err != nil {
 		return NoteOperation{}, err
 	}
@@ -57,19 +57,11 @@ func (g *YAMLNoteGenerator) GenerateNoteContent(note model.Note) (NoteOperation,
 	// Add title
 	content = append(content, []byte(fmt.Sprintf("# %s\n\n", metadata.Aliases[0]))...)
 
-	// Add highlights by color
-	highlightSections := g.HighlightFormatter.FormatHighlightsByColor(note.Highlights)
-	colorOrder := g.HighlightFormatter.GetSortedColorOrder(
-		g.HighlightFormatter.groupHighlightsByColor(note.Highlights))
-
-	for _, color := range colorOrder {
-		if section, ok := highlightSections[color]; ok {
-			content = append(content, section...)
-		}
-	}
+	// Add highlights, grouped as configured for this type of bookmark
+	content = append(content, g.HighlightFormatter.FormatHighlights(note.Bookmark.Type, note.Highlights)...)
 
 	// Add metadata
-	content = append(content, []byte("## References\n")...)
+	content = append(content, []byte("## "+referencesTitle+"\n")...)
 	content = append(content, g.generateReferences(metadata)...)
 
 	return NoteOperation{
@@ -78,9 +70,10 @@ func (g *YAMLNoteGenerator) GenerateNoteContent(note model.Note) (NoteOperation,
 	}, nil
 }
 
-func (g *YAMLNoteGenerator) generateMetadata(bookmark readdeck.Bookmark, highlights []readdeck.Highlight) (model.NoteMetadata, error) {
-	ids := make([]string, len(highlights))
-	for i, h := range highlights {
+func (g *YAMLNoteGenerator) generateMetadata(note model.Note) (model.NoteMetadata, error) {
+	bookmark := note.Bookmark
+	ids := make([]string, len(note.Highlights))
+	for i, h := range note.Highlights {
 		ids[i] = h.ID
 	}
 
@@ -97,7 +90,7 @@ func (g *YAMLNoteGenerator) generateMetadata(bookmark readdeck.Bookmark, highlig
 
 	return model.NoteMetadata{
 		ID:           util.GenerateId(bookmark.Title, time.Now()),
-		Aliases:      []string{fmt.Sprintf("%s highlights", util.Capitalize(bookmark.Title))},
+		Aliases:      []string{noteTitle(bookmark)},
 		Tags:         tags,
 		Created:      created,
 		ReaddeckID:   bookmark.ID,
@@ -108,9 +101,15 @@ func (g *YAMLNoteGenerator) generateMetadata(bookmark readdeck.Bookmark, highlig
 		ArchiveUrl:   fmt.Sprintf("%s/bookmarks/%s", g.BaseUrl, bookmark.ID),
 		Site:         bookmark.SiteUrl,
 		Authors:      bookmark.Authors,
+		Citekey:      note.Citekey,
 	}, nil
 }
 
+// noteTitle is the first alias of a new note, and the title of the note in the formats that have one
+func noteTitle(bookmark readdeck.Bookmark) string {
+	return fmt.Sprintf("%s highlights", util.Capitalize(bookmark.Title))
+}
+
 func (g *YAMLNoteGenerator) generateFrontmatter(metadata model.NoteMetadata) ([]byte, error) {
 	yamlData, err := yaml.Marshal(metadata)
 	if err != nil {
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"gopkg.in/yaml.v2"
+)
+
+// listProperties are the metadata fields that hold a list
+var listProperties = map[string]bool{
+	"aliases": true,
+	"tags":    true,
+	"authors": true,
+}
+
+// metadataToProperties flattens the metadata into properties, in the order of the YAML
+// frontmatter and with the same keys. Lists are written with formatList, empty values are left out.
+func metadataToProperties(metadata model.NoteMetadata, formatList func([]string) string) ([]model.Property, error) {
+	yamlBytes, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("could not format metadata: %w", err)
+	}
+
+	var fields yaml.MapSlice
+	if err := yaml.Unmarshal(yamlBytes, &fields); err != nil {
+		return nil, fmt.Errorf("could not format metadata: %w", err)
+	}
+
+	result := make([]model.Property, 0, len(fields))
+	for _, field := range fields {
+		value := propertyValue(field.Value, formatList)
+		if value == "" {
+			continue
+		}
+		result = append(result, model.Property{Key: fmt.Sprint(field.Key), Value: value})
+	}
+
+	return result, nil
+}
+
+func propertyValue(value interface{}, formatList func([]string) string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		if len(v) == 0 {
+			return ""
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return formatList(items)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// propertiesToMetadata reads metadata back from properties keyed like the YAML frontmatter.
+// Lists the parser already split are taken as they are, text values are split with splitPropertyList.
+func propertiesToMetadata(properties map[string]interface{}, validate *validator.Validate) (model.NoteMetadata, error) {
+	fields := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		if text, ok := value.(string); ok && listProperties[key] {
+			fields[key] = splitPropertyList(text)
+			continue
+		}
+		fields[key] = value
+	}
+
+	yamlBytes, err := yaml.Marshal(fields)
+	if err != nil {
+		return model.NoteMetadata{}, fmt.Errorf("could not remarshal properties: %w", err)
+	}
+
+	var metadata model.NoteMetadata
+	if err := yaml.Unmarshal(yamlBytes, &metadata); err != nil {
+		return model.NoteMetadata{}, fmt.Errorf("could not unmarshal to struct: %w", err)
+	}
+
+	if err := validate.Struct(&metadata); err != nil {
+		return model.NoteMetadata{}, fmt.Errorf("properties are invalid: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// formatPropertyList writes every item as a [[page ref]], so items can hold commas
+// (eg. an author "Graham, Paul")
+func formatPropertyList(items []string) string {
+	refs := make([]string, len(items))
+	for i, item := range items {
+		refs[i] = "[[" + strings.ReplaceAll(item, "]]", "] ]") + "]]"
+	}
+	return strings.Join(refs, ", ")
+}
+
+var propertyListRegex = regexp.MustCompile(`\[\[(.*?)\]\]|[^,\s][^,]*`)
+
+// splitPropertyList reads a list back. Page refs are taken whole, items outside of
+// them are separated by commas, as in notes written before lists used page refs.
+func splitPropertyList(value string) []string {
+	result := make([]string, 0)
+	for _, matches := range propertyListRegex.FindAllStringSubmatch(value, -1) {
+		item := strings.TrimSpace(matches[0])
+		if strings.HasPrefix(item, "[[") && strings.HasSuffix(item, "]]") {
+			item = strings.TrimSpace(matches[1])
+		}
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// mergeProperties updates the existing properties in place and appends the new ones,
+// so properties the user added by hand survive an update
+func mergeProperties(existing []model.Property, updated []model.Property) []model.Property {
+	result := make([]model.Property, len(existing))
+	copy(result, existing)
+
+	indexes := make(map[string]int, len(existing))
+	for i, p := range result {
+		indexes[p.Key] = i
+	}
+
+	for _, p := range updated {
+		if i, ok := indexes[p.Key]; ok {
+			result[i].Value = p.Value
+			continue
+		}
+		indexes[p.Key] = len(result)
+		result = append(result, p)
+	}
+
+	return result
+}
@@ -3,6 +3,7 @@ package repository
 
 import (
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 )
 
 type NoteService interface {
@@ -18,7 +19,7 @@ type ComprehensiveNoteService struct {
 }
 
 func NewNoteService(baseUrl string) NoteService {
-	formatter := NewHighlightFormatter(DefaultColorConfig())
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
 	parser := NewYAMLNoteParser()
 	generator := NewYAMLNoteGenerator(formatter, baseUrl)
 	updater := NewYAMLNoteUpdater(generator, parser)
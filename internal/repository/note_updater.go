@@ -26,11 +26,11 @@ func NewYAMLNoteUpdater(generator *YAMLNoteGenerator, parser *YAMLNoteParser) *Y
 func (u *YAMLNoteUpdater) UpdateNoteContent(existing model.ParsedNote, note model.Note) (NoteOperation, error) {
 	highlights := u.getHighlights(existing.HighlightIDs, note.Highlights)
 
-	if len(highlights) == 0 {
+	if len(highlights) == 0 && !u.missingCitekey(existing, note) {
 		return NoteOperation{}, nil
 	}
 
-	metadata, err := u.updateMetadata(existing.Metadata, note.Bookmark, note.Highlights)
+	metadata, err := u.updateMetadata(existing, note)
 	if err != nil {
 		return NoteOperation{}, err
 	}
@@ -43,7 +43,7 @@ func (u *YAMLNoteUpdater) UpdateNoteContent(existing model.ParsedNote, note mode
 	}
 	content = append(content, frontmatter...)
 
-	bodyBytes := u.appendHighlightsToSections(existing.Content, highlights, metadata)
+	bodyBytes := u.appendHighlightsToSections(existing.Content, note.Bookmark.Type, note.Highlights, highlights, metadata)
 	content = append(content, bodyBytes...)
 
 	return NoteOperation{
@@ -52,12 +52,30 @@ func (u *YAMLNoteUpdater) UpdateNoteContent(existing model.ParsedNote, note mode
 	}, nil
 }
 
-func (u *YAMLNoteUpdater) updateMetadata(existing model.NoteMetadata, bookmark readdeck.Bookmark, highlights []readdeck.Highlight) (model.NoteMetadata, error) {
-	metadata, err := u.Generator.generateMetadata(bookmark, highlights)
+func (u *YAMLNoteUpdater) updateMetadata(parsed model.ParsedNote, note model.Note) (model.NoteMetadata, error) {
+	existing := parsed.Metadata
+	metadata, err := u.Generator.generateMetadata(note)
 	if err != nil {
 		return model.NoteMetadata{}, fmt.Errorf("Could not generate new metadata: %w", err)
 	}
 
+	// The hash keeps the highlights that were exported before. A filtered export only
+	// brings some of them, they would be added again by the next full export otherwise.
+	ids := make([]string, 0, len(note.Highlights))
+	for _, h := range note.Highlights {
+		ids = append(ids, h.ID)
+	}
+	hash, err := u.Generator.Hasher.Encode(u.merge(parsed.HighlightIDs, ids))
+	if err != nil {
+		return model.NoteMetadata{}, fmt.Errorf("could not hash highlights: %w", err)
+	}
+
+	// Notes keep their citekey when citations aren't tracked in this run
+	citekey := metadata.Citekey
+	if citekey == "" {
+		citekey = existing.Citekey
+	}
+
 	return model.NoteMetadata{
 		ID:           existing.ID,
 		Aliases:      u.merge(existing.Aliases, metadata.Aliases),
@@ -70,10 +88,17 @@ func (u *YAMLNoteUpdater) updateMetadata(existing model.NoteMetadata, bookmark r
 		ArchiveUrl:   metadata.ArchiveUrl,
 		Site:         metadata.Site,
 		Authors:      u.merge(existing.Authors, metadata.Authors),
-		ReaddeckHash: metadata.ReaddeckHash,
+		ReaddeckHash: hash,
+		Citekey:      citekey,
 	}, nil
 }
 
+// missingCitekey is true for notes written before citations were tracked,
+// they get their citekey even when there are no new highlights
+func (u *YAMLNoteUpdater) missingCitekey(existing model.ParsedNote, note model.Note) bool {
+	return note.Citekey != "" && existing.Metadata.Citekey == ""
+}
+
 func (u *YAMLNoteUpdater) updateFrontmatter(existing map[string]interface{}, metadata model.NoteMetadata) ([]byte, error) {
 	metadataBytes, err := yaml.Marshal(metadata)
 	if err != nil {
@@ -97,9 +122,10 @@ func (u *YAMLNoteUpdater) updateFrontmatter(existing map[string]interface{}, met
 	return fmt.Appendf(nil, "---\n%s---\n", frontmatterBytes), nil
 }
 
+// findReferenceSection returns the last references section, the one the generator ends the note with
 func (u *YAMLNoteUpdater) findReferenceSection(sections []model.Section) *model.Section {
-	for i, section := range sections {
-		if section.Type == model.H2 && section.Title == "References" {
+	for i := len(sections) - 1; i >= 0; i-- {
+		if sections[i].Type == model.H2 && sections[i].Title == referencesTitle {
 			return &sections[i]
 		}
 	}
@@ -107,58 +133,70 @@ func (u *YAMLNoteUpdater) findReferenceSection(sections []model.Section) *model.
 	return nil
 }
 
-func (u *YAMLNoteUpdater) appendHighlightsToSections(sections []model.Section, highlights []readdeck.Highlight, metadata model.NoteMetadata) []byte {
+func (u *YAMLNoteUpdater) appendHighlightsToSections(sections []model.Section, bookmarkType string, allHighlights []readdeck.Highlight, highlights []readdeck.Highlight, metadata model.NoteMetadata) []byte {
 	var buffer bytes.Buffer
 	referenceSection := u.findReferenceSection(sections)
 	if referenceSection == nil {
 		referenceSection = &model.Section{
 			Type:    model.H2,
-			Title:   "References",
+			Title:   referencesTitle,
 			Content: string(u.Generator.generateReferences(metadata)),
 		}
 	}
 
 	// Reuse the formatter's grouping and ordering logic to ensure consistent
 	// presentation between new notes and updated notes
-	highlightGroups := u.Generator.HighlightFormatter.groupHighlightsByColor(highlights)
-	colorOrder := u.Generator.HighlightFormatter.GetSortedColorOrder(highlightGroups)
-	highlightBodies := make(map[string][]byte)
-	for color, hs := range highlightGroups {
-		highlightBodies[color] = u.Generator.HighlightFormatter.highlightBodyBytes(hs)
+	formatter := u.Generator.HighlightFormatter
+	newGroups := formatter.GroupHighlights(bookmarkType, highlights)
+
+	// All highlights of a group, sorted, so new ones can be slotted in next to their neighbours
+	allGroups := formatter.GroupHighlights(bookmarkType, allHighlights)
+	sortedGroups := make(map[string][]readdeck.Highlight)
+	for _, group := range allGroups {
+		sortedGroups[group.Title] = group.Highlights
+	}
+	placer := newGroupPlacer(allGroups, newGroups, sections, model.H2)
+
+	newIDs := make(map[string]bool, len(highlights))
+	for _, h := range highlights {
+		newIDs[h.ID] = true
 	}
 
 	// Track which highlight groups have been handled so we know which ones
-	// need new sections at the end of the document
-	processedColors := make(map[string]bool)
+	// need new sections
+	processedGroups := make(map[string]bool)
 
 	for i := range sections {
-		// section := sections[i]
-		if &sections[i] != referenceSection {
-			writeSection(&buffer, sections[i])
-		}
+		section := sections[i]
 
-		if sections[i].Type == model.H2 {
-			for color := range highlightGroups {
-				friendlyName := u.Generator.HighlightFormatter.colorToFriendlyName(color)
+		if section.Type == model.H2 {
+			for _, group := range placer.before(section.Title) {
+				buffer.Write(formatter.groupTitleBytes(group))
+				buffer.Write(formatter.highlightBodyBytes(group.Highlights))
+				processedGroups[group.Title] = true
+			}
+		}
 
-				if friendlyName == sections[i].Title && len(highlightGroups[color]) > 0 {
-					// Use the highlight bodies directly instead of trying to extract them
-					buffer.Write(highlightBodies[color])
-					processedColors[color] = true
+		if section.Type == model.H2 && !processedGroups[section.Title] {
+			for _, group := range newGroups {
+				if group.Title == section.Title {
+					section.Content = u.insertHighlights(section.Content, sortedGroups[group.Title], newIDs)
+					processedGroups[group.Title] = true
 					break
 				}
 			}
+		}
 
+		if &sections[i] != referenceSection {
+			writeSection(&buffer, section)
 		}
 	}
 
-	// For highlight colors without matching sections, add them as new sections
-	// at the end of the document in the proper order
-	for _, color := range colorOrder {
-		if !processedColors[color] && len(highlightGroups[color]) > 0 {
-			title := u.Generator.HighlightFormatter.highlightTitleBytes(color)
-			buffer.Write(title)
-			buffer.Write(highlightBodies[color])
+	// Groups that come after all existing ones are added at the end, in order
+	for _, group := range newGroups {
+		if !processedGroups[group.Title] {
+			buffer.Write(formatter.groupTitleBytes(group))
+			buffer.Write(formatter.highlightBodyBytes(group.Highlights))
 		}
 	}
 
@@ -166,25 +204,97 @@ func (u *YAMLNoteUpdater) appendHighlightsToSections(sections []model.Section, h
 	return buffer.Bytes()
 }
 
-func writeSection(buffer *bytes.Buffer, section model.Section) {
-	if section.Type != model.None {
-		level := 0
-		switch section.Type {
-		case model.H1:
-			level = 1
-		case model.H2:
-			level = 2
-		case model.H3:
-			level = 3
-		case model.H4:
-			level = 4
-		case model.H5:
-			level = 5
-		case model.H6:
-			level = 6
+// groupPlacer finds where the groups that don't have a section yet go: before the first existing
+// group section that follows them in the order of all highlights. So a chapter highlighted later
+// still ends up in the order of the article.
+type groupPlacer struct {
+	order   map[string]int
+	pending []HighlightGroup
+}
+
+func newGroupPlacer(allGroups []HighlightGroup, newGroups []HighlightGroup, sections []model.Section, groupType model.SectionType) *groupPlacer {
+	existing := make(map[string]bool)
+	for _, section := range sections {
+		if section.Type == groupType {
+			existing[section.Title] = true
+		}
+	}
+
+	placer := &groupPlacer{order: make(map[string]int, len(allGroups))}
+	for i, group := range allGroups {
+		placer.order[group.Title] = i
+	}
+	for _, group := range newGroups {
+		if !existing[group.Title] {
+			placer.pending = append(placer.pending, group)
+		}
+	}
+	return placer
+}
+
+// before returns the new groups that go before the section with this title, sections that
+// aren't groups (eg. the user's own) don't move anything
+func (p *groupPlacer) before(title string) []HighlightGroup {
+	index, ok := p.order[title]
+	if !ok {
+		return nil
+	}
+
+	var result, rest []HighlightGroup
+	for _, group := range p.pending {
+		if p.order[group.Title] < index {
+			result = append(result, group)
+		} else {
+			rest = append(rest, group)
+		}
+	}
+	p.pending = rest
+	return result
+}
+
+// insertHighlights places every new highlight right after the closest preceding
+// highlight that is already in the section. When none of its predecessors can be
+// found (eg. the user edited the quote), it falls back on appending.
+func (u *YAMLNoteUpdater) insertHighlights(content string, sorted []readdeck.Highlight, newIDs map[string]bool) string {
+	formatter := u.Generator.HighlightFormatter
+	return insertRendered(content, sorted, newIDs, func(h readdeck.Highlight) string {
+		return string(formatter.highlightBodyBytes([]readdeck.Highlight{h}))
+	})
+}
+
+// insertRendered does the positional insertion for any format, render returns
+// exactly what a highlight looks like within the section
+func insertRendered(content string, sorted []readdeck.Highlight, newIDs map[string]bool, render func(readdeck.Highlight) string) string {
+	for i, h := range sorted {
+		if !newIDs[h.ID] {
+			continue
 		}
 
-		buffer.WriteString(strings.Repeat("#", level))
+		at := insertionPoint(content, sorted[:i], render)
+		content = content[:at] + render(h) + content[at:]
+	}
+
+	return content
+}
+
+func insertionPoint(content string, preceding []readdeck.Highlight, render func(readdeck.Highlight) string) int {
+	if len(preceding) == 0 {
+		return 0
+	}
+
+	for i := len(preceding) - 1; i >= 0; i-- {
+		body := render(preceding[i])
+		if idx := strings.LastIndex(content, body); idx >= 0 {
+			return idx + len(body)
+		}
+	}
+
+	return len(content)
+}
+
+func writeSection(buffer *bytes.Buffer, section model.Section) {
+	if section.Type != model.None {
+		buffer.WriteString(strings.Repeat("#", headingLevel(section.Type)))
 		buffer.WriteString(" ")
 		buffer.WriteString(section.Title)
 		buffer.WriteString("\n")
@@ -249,3 +359,22 @@ func (u *YAMLNoteUpdater) merge(list1 []string, list2 []string) []string {
 
 	return unique
 }
+
+func headingLevel(sectionType model.SectionType) int {
+	switch sectionType {
+	case model.H1:
+		return 1
+	case model.H2:
+		return 2
+	case model.H3:
+		return 3
+	case model.H4:
+		return 4
+	case model.H5:
+		return 5
+	case model.H6:
+		return 6
+	default:
+		return 0
+	}
+}
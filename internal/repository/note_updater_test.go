@@ -5,7 +5,10 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestYAMLNoteUpdater_diffHighlights(t *testing.T) {
@@ -167,3 +170,92 @@ func equalHighlightSets(a, b []readdeck.Highlight) bool {
 	// Compare the maps
 	return reflect.DeepEqual(mapA, mapB)
 }
+
+func TestYAMLNoteUpdater_insertHighlights(t *testing.T) {
+	h1 := readdeck.Highlight{ID: "h1", Text: "Highlight 1", StartSelector: "p[1]"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Highlight 2", StartSelector: "p[2]"}
+	h3 := readdeck.Highlight{ID: "h3", Text: "Highlight 3", StartSelector: "p[3]"}
+	h4 := readdeck.Highlight{ID: "h4", Text: "Highlight 4", StartSelector: "p[4]"}
+
+	tests := []struct {
+		name    string
+		content string
+		sorted  []readdeck.Highlight
+		newIDs  map[string]bool
+		want    string
+	}{
+		{
+			name:    "insert in the middle",
+			content: "Highlight 1\n\nHighlight 3\n\n",
+			sorted:  []readdeck.Highlight{h1, h2, h3},
+			newIDs:  map[string]bool{"h2": true},
+			want:    "Highlight 1\n\nHighlight 2\n\nHighlight 3\n\n",
+		},
+		{
+			name:    "insert at the start",
+			content: "Highlight 2\n\nHighlight 3\n\n",
+			sorted:  []readdeck.Highlight{h1, h2, h3},
+			newIDs:  map[string]bool{"h1": true},
+			want:    "Highlight 1\n\nHighlight 2\n\nHighlight 3\n\n",
+		},
+		{
+			name:    "consecutive new highlights keep their order",
+			content: "Highlight 1\n\n",
+			sorted:  []readdeck.Highlight{h1, h2, h3, h4},
+			newIDs:  map[string]bool{"h2": true, "h3": true, "h4": true},
+			want:    "Highlight 1\n\nHighlight 2\n\nHighlight 3\n\nHighlight 4\n\n",
+		},
+		{
+			name:    "edited predecessor falls back on an earlier one",
+			content: "Highlight 1\n\nMy own words\n\n",
+			sorted:  []readdeck.Highlight{h1, h2, h3},
+			newIDs:  map[string]bool{"h3": true},
+			want:    "Highlight 1\n\nHighlight 3\n\nMy own words\n\n",
+		},
+		{
+			name:    "no predecessor found appends",
+			content: "Edited\n\n",
+			sorted:  []readdeck.Highlight{h1, h2},
+			newIDs:  map[string]bool{"h2": true},
+			want:    "Edited\n\nHighlight 2\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+			u := NewYAMLNoteUpdater(NewYAMLNoteGenerator(formatter, ""), NewYAMLNoteParser())
+
+			got := u.insertHighlights(tt.content, tt.sorted, tt.newIDs)
+
+			if got != tt.want {
+				t.Errorf("insertHighlights() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLNoteUpdater_KeepsCitekey(t *testing.T) {
+	formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+	generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+	parser := NewYAMLNoteParser()
+	updater := NewYAMLNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness"}
+	h1 := readdeck.Highlight{ID: "h1", Text: "First", Color: "yellow"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second", Color: "yellow"}
+
+	created, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}, Citekey: "graham2012schlep"})
+	require.NoError(t, err)
+	assert.Contains(t, string(created.Content), "citekey: graham2012schlep\n")
+
+	parsed, err := parser.ParseNote(created.Content, "note.md")
+	require.NoError(t, err)
+	assert.Equal(t, "graham2012schlep", parsed.Metadata.Citekey)
+
+	// An export without citations keeps the key of the note
+	updated, err := updater.UpdateNoteContent(parsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}})
+	require.NoError(t, err)
+	assert.Equal(t, "graham2012schlep", updated.Metadata.Citekey)
+	assert.Contains(t, string(updated.Content), "citekey: graham2012schlep\n")
+}
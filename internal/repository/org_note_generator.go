@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// org-roam reads a few properties of its own, the frontmatter keys that
+// hold the same information are renamed. Other keys are upper cased.
+var orgPropertyKeys = map[string]string{
+	"id":        "ID",
+	"media-url": "ROAM_REFS",
+	"aliases":   "ROAM_ALIASES",
+}
+
+// Org tags can only hold letters, digits and _@#%
+var orgTagRegex = regexp.MustCompile(`[^\p{L}\p{N}_@#%]+`)
+
+// OrgNoteGenerator writes org-roam nodes: a property drawer, #+title and #+filetags
+// keywords, a headline per group and a quote block per highlight
+type OrgNoteGenerator struct {
+	Generator *YAMLNoteGenerator
+}
+
+func NewOrgNoteGenerator(formatter *HighlightFormatter, baseUrl string) *OrgNoteGenerator {
+	return &OrgNoteGenerator{
+		Generator: NewYAMLNoteGenerator(formatter, baseUrl),
+	}
+}
+
+func (g *OrgNoteGenerator) GenerateNoteContent(note model.Note) (NoteOperation, error) {
+	metadata, err := g.Generator.generateMetadata(note)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	properties, err := g.drawerProperties(metadata)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	var content strings.Builder
+	g.writeHeader(&content, properties, metadata, noteTitle(note.Bookmark))
+	content.WriteString("\n")
+
+	formatter := g.Generator.HighlightFormatter
+	for _, group := range formatter.GroupHighlights(note.Bookmark.Type, note.Highlights) {
+		content.WriteString(g.headline(1, group.Title))
+		for _, h := range group.Highlights {
+			content.WriteString(g.quoteBlock(h))
+		}
+	}
+
+	content.WriteString(g.headline(1, referencesTitle))
+	content.WriteString(g.referencesContent(metadata))
+
+	return NoteOperation{
+		Metadata: metadata,
+		Content:  []byte(content.String()),
+	}, nil
+}
+
+// drawerProperties are the metadata, in frontmatter order, with org property keys.
+// Tags are left out, they are written as #+filetags.
+func (g *OrgNoteGenerator) drawerProperties(metadata model.NoteMetadata) ([]model.Property, error) {
+	properties, err := metadataToProperties(metadata, formatOrgList)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Property, 0, len(properties))
+	for _, p := range properties {
+		if p.Key == "tags" {
+			continue
+		}
+		p.Key = orgPropertyKey(p.Key)
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// writeHeader writes the property drawer and the keywords. The title is passed on its own,
+// an updated note merges the aliases and the first one is not necessarily the current title.
+func (g *OrgNoteGenerator) writeHeader(builder *strings.Builder, properties []model.Property, metadata model.NoteMetadata, title string) {
+	builder.WriteString(":PROPERTIES:\n")
+	for _, p := range properties {
+		builder.WriteString(fmt.Sprintf(":%s: %s\n", p.Key, p.Value))
+	}
+	builder.WriteString(":END:\n")
+
+	builder.WriteString(fmt.Sprintf("#+title: %s\n", title))
+	if tags := formatOrgTags(metadata.Tags); tags != "" {
+		builder.WriteString(fmt.Sprintf("#+filetags: %s\n", tags))
+	}
+}
+
+func (g *OrgNoteGenerator) headline(level int, title string) string {
+	return fmt.Sprintf("%s %s\n", strings.Repeat("*", level), title)
+}
+
+func (g *OrgNoteGenerator) quoteBlock(h readdeck.Highlight) string {
+	text := strings.TrimSpace(g.Generator.HighlightFormatter.HighlightText(h))
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		// A leading star would start a headline, org escapes it with a comma
+		if strings.HasPrefix(line, "*") || strings.HasPrefix(line, "#+") {
+			lines[i] = "," + line
+		}
+	}
+
+	return fmt.Sprintf("#+begin_quote\n%s\n#+end_quote\n\n", strings.Join(lines, "\n"))
+}
+
+func (g *OrgNoteGenerator) referencesContent(metadata model.NoteMetadata) string {
+	return fmt.Sprintf("- [[%s][%s]]\n- [[%s][Archived article]]\n", metadata.Site, metadata.Media, metadata.ArchiveUrl)
+}
+
+func orgPropertyKey(key string) string {
+	if orgKey, ok := orgPropertyKeys[key]; ok {
+		return orgKey
+	}
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// formatOrgList quotes every item, as org-roam does for aliases, so items can hold commas and spaces
+func formatOrgList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf(`"%s"`, strings.ReplaceAll(item, `"`, "'"))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// formatOrgTags returns the tags as ":a:b:", or an empty string without tags
+func formatOrgTags(tags []string) string {
+	seen := make(map[string]bool, len(tags))
+	var result []string
+	for _, tag := range tags {
+		tag = strings.Trim(orgTagRegex.ReplaceAllString(strings.TrimSpace(tag), "_"), "_")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+
+	if len(result) == 0 {
+		return ""
+	}
+	return ":" + strings.Join(result, ":") + ":"
+}
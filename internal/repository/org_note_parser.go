@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+)
+
+type OrgNoteParser struct {
+	Validator     *validator.Validate
+	propertyRegex *regexp.Regexp
+	keywordRegex  *regexp.Regexp
+	headlineRegex *regexp.Regexp
+	aliasRegex    *regexp.Regexp
+	// Shares the highlight hash decoding and heading levels with the markdown parser
+	base *YAMLNoteParser
+}
+
+func NewOrgNoteParser() *OrgNoteParser {
+	return &OrgNoteParser{
+		Validator:     validator.New(),
+		propertyRegex: regexp.MustCompile(`^:([A-Za-z0-9_-]+):\s*(.*)$`),
+		keywordRegex:  regexp.MustCompile(`^#\+([A-Za-z_-]+):\s*(.*)$`),
+		headlineRegex: regexp.MustCompile(`^(\*+)\s+(.*)$`),
+		aliasRegex:    regexp.MustCompile(`"([^"]*)"|(\S+)`),
+		base:          NewYAMLNoteParser(),
+	}
+}
+
+func (p *OrgNoteParser) ParseNote(content []byte, path string) (model.ParsedNote, error) {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	properties, rest, err := p.parseDrawer(lines)
+	if err != nil {
+		return model.ParsedNote{}, err
+	}
+
+	keywords, rest := p.parseKeywords(rest)
+
+	// Lists are split here, joining them again would break items that hold a comma
+	fields := make(map[string]interface{}, len(properties)+1)
+	for _, property := range properties {
+		key := p.frontmatterKey(property.Key)
+		if listProperties[key] {
+			fields[key] = p.parseList(key, property.Value)
+			continue
+		}
+		fields[key] = property.Value
+	}
+	if tags, ok := keywords["filetags"]; ok {
+		fields["tags"] = p.parseTags(tags)
+	}
+
+	metadata, err := propertiesToMetadata(fields, p.Validator)
+	if err != nil {
+		return model.ParsedNote{}, err
+	}
+
+	highlightIDs, err := p.base.decodeHighlightIDsHash(metadata.ReaddeckHash)
+	if err != nil {
+		return model.ParsedNote{}, err
+	}
+
+	return model.ParsedNote{
+		Path:          path,
+		Metadata:      metadata,
+		Content:       p.ParseContent(rest),
+		HighlightIDs:  highlightIDs,
+		RawProperties: properties,
+	}, nil
+}
+
+// parseDrawer reads the property drawer at the top of the file
+func (p *OrgNoteParser) parseDrawer(lines []string) ([]model.Property, []string, error) {
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) || !strings.EqualFold(strings.TrimSpace(lines[start]), ":PROPERTIES:") {
+		return nil, nil, fmt.Errorf("could not parse property drawer: none found")
+	}
+
+	properties := make([]model.Property, 0)
+	for i := start + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.EqualFold(line, ":END:") {
+			return properties, lines[i+1:], nil
+		}
+
+		matches := p.propertyRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, nil, fmt.Errorf("could not parse property drawer: invalid line %q", line)
+		}
+		properties = append(properties, model.Property{Key: matches[1], Value: strings.TrimSpace(matches[2])})
+	}
+
+	return nil, nil, fmt.Errorf("could not parse property drawer: missing :END:")
+}
+
+// parseKeywords reads the #+title and #+filetags keywords that follow the drawer.
+// Other keywords are left in the content, so they survive an update.
+func (p *OrgNoteParser) parseKeywords(lines []string) (map[string]string, []string) {
+	keywords := make(map[string]string)
+	rest := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		if p.headlineRegex.MatchString(line) {
+			rest = append(rest, lines[i:]...)
+			break
+		}
+
+		matches := p.keywordRegex.FindStringSubmatch(line)
+		if matches != nil {
+			key := strings.ToLower(matches[1])
+			if key == "title" || key == "filetags" {
+				keywords[key] = strings.TrimSpace(matches[2])
+				continue
+			}
+		}
+		rest = append(rest, line)
+	}
+
+	return keywords, rest
+}
+
+func (p *OrgNoteParser) frontmatterKey(key string) string {
+	for frontmatterKey, orgKey := range orgPropertyKeys {
+		if strings.EqualFold(orgKey, key) {
+			return frontmatterKey
+		}
+	}
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// parseList reads a list of quoted items. Aliases follow org-roam, where unquoted words are
+// aliases of their own, other lists written before they were quoted are separated by commas.
+func (p *OrgNoteParser) parseList(key string, value string) []string {
+	if key != "aliases" && !strings.Contains(value, `"`) {
+		return splitPropertyList(value)
+	}
+
+	result := make([]string, 0)
+	for _, matches := range p.aliasRegex.FindAllStringSubmatch(value, -1) {
+		alias := matches[1] + matches[2]
+		if alias != "" {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+func (p *OrgNoteParser) parseTags(value string) []string {
+	result := make([]string, 0)
+	for _, tag := range strings.Split(value, ":") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// ParseContent turns every headline into a section, the headline level becomes the section type.
+// Text before the first headline is kept in a section without a title.
+func (p *OrgNoteParser) ParseContent(lines []string) []model.Section {
+	var sections []model.Section
+	current := model.Section{Type: model.None}
+
+	for _, line := range lines {
+		if matches := p.headlineRegex.FindStringSubmatch(line); matches != nil {
+			if current.Type != model.None || strings.TrimSpace(current.Content) != "" {
+				sections = append(sections, current)
+			}
+
+			level := len(matches[1])
+			if level > 6 {
+				level = 6
+			}
+			current = model.Section{Type: p.base.headingTypeFromLevel(level), Title: strings.TrimSpace(matches[2])}
+			continue
+		}
+
+		current.Content += line + "\n"
+	}
+
+	if current.Type != model.None || strings.TrimSpace(current.Content) != "" {
+		sections = append(sections, current)
+	}
+
+	// The file ends with a newline, which is not part of the last section
+	if len(sections) > 0 {
+		last := &sections[len(sections)-1]
+		last.Content = strings.TrimSuffix(last.Content, "\n")
+	}
+
+	return sections
+}
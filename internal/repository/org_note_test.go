@@ -0,0 +1,150 @@
+package repository_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrgNote_RoundTrip(t *testing.T) {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	parser := repository.NewOrgNoteParser()
+	generator := repository.NewOrgNoteGenerator(formatter, "https://read.example.com")
+	updater := repository.NewOrgNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{
+		ID:      "b1",
+		Title:   "Schlep Blindness",
+		Type:    "article",
+		Created: created,
+		SiteUrl: "https://www.paulgraham.com/schlep.html",
+		Authors: []string{"Paul Graham"},
+	}
+	h1 := readdeck.Highlight{ID: "h1", Text: "First paragraph", Color: "yellow", StartSelector: "p[1]"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second paragraph\n* not a headline", Color: "yellow", StartSelector: "p[2]"}
+	h3 := readdeck.Highlight{ID: "h3", Text: "Third paragraph", Color: "yellow", StartSelector: "p[3]"}
+	h4 := readdeck.Highlight{ID: "h4", Text: "A takeaway", Color: "green", StartSelector: "p[4]"}
+
+	op, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h3}})
+	require.NoError(t, err)
+
+	content := string(op.Content)
+	assert.True(t, strings.HasPrefix(content, ":PROPERTIES:\n:ID: "+op.Metadata.ID+"\n"), content)
+	assert.Contains(t, content, ":ROAM_ALIASES: \"Schlep Blindness highlights\"\n")
+	assert.Contains(t, content, ":ROAM_REFS: https://www.paulgraham.com/schlep.html\n")
+	assert.Contains(t, content, ":READDECK_ID: b1\n")
+	assert.Contains(t, content, ":AUTHORS: \"Paul Graham\"\n")
+	assert.Contains(t, content, ":END:\n#+title: Schlep Blindness highlights\n#+filetags: :highlights:zettelkasten:fleeting_note:\n")
+	assert.Contains(t, content, "* General highlights\n#+begin_quote\nFirst paragraph\n#+end_quote\n\n#+begin_quote\nThird paragraph\n#+end_quote\n\n")
+	assert.True(t, strings.HasSuffix(content, "* References\n- [[https://www.paulgraham.com/schlep.html][Schlep Blindness]]\n- [[https://read.example.com/bookmarks/b1][Archived article]]\n"), content)
+
+	// The user adds a property and a headline of their own
+	content = strings.Replace(content, ":END:", ":STATUS: reading\n:END:", 1)
+	content = strings.Replace(content, "* References", "* My own thoughts\n** Nested thought\nSome text\n\n* References", 1)
+
+	parsed, err := parser.ParseNote([]byte(content), "note.org")
+	require.NoError(t, err)
+	assert.Equal(t, op.Metadata.ID, parsed.Metadata.ID)
+	assert.Equal(t, "b1", parsed.Metadata.ReaddeckID)
+	assert.Equal(t, "https://www.paulgraham.com/schlep.html", parsed.Metadata.Site)
+	assert.Equal(t, []string{"Schlep Blindness highlights"}, parsed.Metadata.Aliases)
+	assert.Equal(t, []string{"highlights", "zettelkasten", "fleeting_note"}, parsed.Metadata.Tags)
+	assert.ElementsMatch(t, []string{"h1", "h3"}, parsed.HighlightIDs)
+
+	updated, err := updater.UpdateNoteContent(parsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2, h3, h4}})
+	require.NoError(t, err)
+
+	content = string(updated.Content)
+	assert.Contains(t, content, ":STATUS: reading\n:END:\n")
+	assert.Contains(t, content, "* General highlights\n#+begin_quote\nFirst paragraph\n#+end_quote\n\n#+begin_quote\nSecond paragraph\n,* not a headline\n#+end_quote\n\n#+begin_quote\nThird paragraph\n")
+	assert.Contains(t, content, "* Key takeaways\n#+begin_quote\nA takeaway\n#+end_quote\n\n* General highlights\n")
+	assert.Contains(t, content, "* My own thoughts\n** Nested thought\nSome text\n\n* References\n")
+	assert.Equal(t, 1, strings.Count(content, "fleeting_note"), content)
+
+	reparsed, err := parser.ParseNote(updated.Content, "note.org")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"h1", "h2", "h3", "h4"}, reparsed.HighlightIDs)
+
+	unchanged, err := updater.UpdateNoteContent(reparsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2, h3, h4}})
+	require.NoError(t, err)
+	assert.Empty(t, unchanged.Content)
+}
+
+func TestOrgNote_ListsWithCommas(t *testing.T) {
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	parser := repository.NewOrgNoteParser()
+	generator := repository.NewOrgNoteGenerator(formatter, "https://read.example.com")
+	updater := repository.NewOrgNoteUpdater(generator, parser)
+
+	bookmark := readdeck.Bookmark{ID: "b1", Title: "Hackers, Painters", Authors: []string{"Graham, Paul", "Livingston, Jessica"}}
+	h1 := readdeck.Highlight{ID: "h1", Text: "First", Color: "yellow"}
+	h2 := readdeck.Highlight{ID: "h2", Text: "Second", Color: "yellow"}
+
+	op, err := generator.GenerateNoteContent(model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1}})
+	require.NoError(t, err)
+	assert.Contains(t, string(op.Content), ":AUTHORS: \"Graham, Paul\" \"Livingston, Jessica\"\n")
+
+	parsed, err := parser.ParseNote(op.Content, "note.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hackers, Painters highlights"}, parsed.Metadata.Aliases)
+	assert.Equal(t, []string{"Graham, Paul", "Livingston, Jessica"}, parsed.Metadata.Authors)
+
+	updated, err := updater.UpdateNoteContent(parsed, model.Note{Bookmark: bookmark, Highlights: []readdeck.Highlight{h1, h2}})
+	require.NoError(t, err)
+	assert.Contains(t, string(updated.Content), ":ROAM_ALIASES: \"Hackers, Painters highlights\"\n")
+	assert.Contains(t, string(updated.Content), "#+title: Hackers, Painters highlights\n")
+
+	reparsed, err := parser.ParseNote(updated.Content, "note.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hackers, Painters highlights"}, reparsed.Metadata.Aliases)
+	assert.Equal(t, []string{"Graham, Paul", "Livingston, Jessica"}, reparsed.Metadata.Authors)
+}
+
+func TestOrgNoteParser_PlainAuthors(t *testing.T) {
+	// Notes written before authors were quoted separate them with commas
+	content := ":PROPERTIES:\n:ID: 1742760960-schlep-blindness\n:ROAM_ALIASES: \"Schlep Blindness highlights\"\n:READDECK_ID: b1\n:AUTHORS: Paul Graham, Jessica Livingston\n:END:\n#+title: Schlep Blindness highlights\n\n* References\n"
+
+	parsed, err := repository.NewOrgNoteParser().ParseNote([]byte(content), "note.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Paul Graham", "Jessica Livingston"}, parsed.Metadata.Authors)
+}
+
+func TestOrgNoteParser_ParseNoteErrors(t *testing.T) {
+	parser := repository.NewOrgNoteParser()
+
+	_, err := parser.ParseNote([]byte("#+title: No drawer\n* Headline\n"), "note.org")
+	assert.Error(t, err)
+
+	_, err = parser.ParseNote([]byte(":PROPERTIES:\n:READDECK_ID: b1\n* Headline\n"), "note.org")
+	assert.Error(t, err)
+
+	_, err = parser.ParseNote([]byte(":PROPERTIES:\n:READDECK_ID: b1\n:END:\n#+title: No id\n"), "note.org")
+	assert.Error(t, err)
+}
+
+func TestNoteFormat_Extension(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "markdown", expected: ".md"},
+		{input: "logseq", expected: ".md"},
+		{input: "org", expected: ".org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			format, err := repository.ParseNoteFormat(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format.Extension())
+		})
+	}
+}
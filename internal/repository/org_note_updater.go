@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+type OrgNoteUpdater struct {
+	Generator *OrgNoteGenerator
+	Parser    *OrgNoteParser
+	// Shares the metadata merging and highlight diffing with the markdown updater
+	base *YAMLNoteUpdater
+}
+
+func NewOrgNoteUpdater(generator *OrgNoteGenerator, parser *OrgNoteParser) *OrgNoteUpdater {
+	return &OrgNoteUpdater{
+		Generator: generator,
+		Parser:    parser,
+		base:      NewYAMLNoteUpdater(generator.Generator, nil),
+	}
+}
+
+func (u *OrgNoteUpdater) UpdateNoteContent(existing model.ParsedNote, note model.Note) (NoteOperation, error) {
+	highlights := u.base.getHighlights(existing.HighlightIDs, note.Highlights)
+
+	if len(highlights) == 0 && !u.base.missingCitekey(existing, note) {
+		return NoteOperation{}, nil
+	}
+
+	metadata, err := u.base.updateMetadata(existing, note)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	properties, err := u.Generator.drawerProperties(metadata)
+	if err != nil {
+		return NoteOperation{}, err
+	}
+
+	var content strings.Builder
+	u.Generator.writeHeader(&content, mergeProperties(existing.RawProperties, properties), metadata, noteTitle(note.Bookmark))
+	content.WriteString("\n")
+	content.WriteString(u.appendHighlightsToHeadlines(existing.Content, note.Bookmark.Type, note.Highlights, highlights, metadata))
+
+	return NoteOperation{
+		Metadata: metadata,
+		Content:  []byte(content.String()),
+	}, nil
+}
+
+func (u *OrgNoteUpdater) appendHighlightsToHeadlines(sections []model.Section, bookmarkType string, allHighlights []readdeck.Highlight, highlights []readdeck.Highlight, metadata model.NoteMetadata) string {
+	var content strings.Builder
+	formatter := u.Generator.Generator.HighlightFormatter
+
+	newGroups := formatter.GroupHighlights(bookmarkType, highlights)
+	allGroups := formatter.GroupHighlights(bookmarkType, allHighlights)
+	sortedGroups := make(map[string][]readdeck.Highlight)
+	for _, group := range allGroups {
+		sortedGroups[group.Title] = group.Highlights
+	}
+	placer := newGroupPlacer(allGroups, newGroups, sections, model.H1)
+
+	newIDs := make(map[string]bool, len(highlights))
+	for _, h := range highlights {
+		newIDs[h.ID] = true
+	}
+
+	processedGroups := make(map[string]bool)
+	references := ""
+	hasReferences := false
+
+	for _, section := range sections {
+		if section.Type == model.H1 && section.Title == referencesTitle && !hasReferences {
+			references = section.Content
+			hasReferences = true
+			continue
+		}
+
+		if section.Type == model.H1 {
+			for _, group := range placer.before(section.Title) {
+				content.WriteString(u.Generator.headline(1, group.Title))
+				for _, h := range group.Highlights {
+					content.WriteString(u.Generator.quoteBlock(h))
+				}
+				processedGroups[group.Title] = true
+			}
+		}
+
+		if section.Type == model.H1 && !processedGroups[section.Title] {
+			for _, group := range newGroups {
+				if group.Title == section.Title {
+					section.Content = insertRendered(section.Content, sortedGroups[group.Title], newIDs, u.Generator.quoteBlock)
+					processedGroups[group.Title] = true
+					break
+				}
+			}
+		}
+
+		u.writeHeadline(&content, section)
+	}
+
+	for _, group := range newGroups {
+		if processedGroups[group.Title] {
+			continue
+		}
+		content.WriteString(u.Generator.headline(1, group.Title))
+		for _, h := range group.Highlights {
+			content.WriteString(u.Generator.quoteBlock(h))
+		}
+	}
+
+	if !hasReferences {
+		references = u.Generator.referencesContent(metadata)
+	}
+	content.WriteString(u.Generator.headline(1, referencesTitle))
+	content.WriteString(references)
+
+	return content.String()
+}
+
+func (u *OrgNoteUpdater) writeHeadline(content *strings.Builder, section model.Section) {
+	if section.Type != model.None {
+		content.WriteString(u.Generator.headline(headingLevel(section.Type), section.Title))
+	}
+
+	body := section.Content
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	content.WriteString(body)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// Route sends the highlights it matches to its own repository.
+// Empty criteria match everything, set criteria must all match.
+type Route struct {
+	Color      string
+	Label      string
+	Type       string
+	Repository NoteRepository
+}
+
+func (r Route) Matches(h readdeck.Highlight, bookmark readdeck.Bookmark) bool {
+	if r.Color != "" && !strings.EqualFold(r.Color, h.Color) {
+		return false
+	}
+
+	if r.Type != "" && !strings.EqualFold(r.Type, bookmark.Type) {
+		return false
+	}
+
+	if r.Label != "" {
+		found := false
+		for _, label := range bookmark.Labels {
+			if strings.EqualFold(r.Label, label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RoutingNoteRepository splits the highlights of every note over its routes, the first
+// matching route wins. Highlights without a matching route go to the fallback repository.
+// Every destination keeps track of what it exported on its own, so a bookmark can have
+// a note in several destinations, each holding the highlights that were routed there.
+// Routes that share a repository are one destination, it gets all their highlights at once.
+type RoutingNoteRepository struct {
+	routes   []Route
+	fallback NoteRepository
+}
+
+var _ NoteRepository = (*RoutingNoteRepository)(nil)
+
+func NewRoutingNoteRepository(fallback NoteRepository, routes []Route) *RoutingNoteRepository {
+	return &RoutingNoteRepository{
+		routes:   routes,
+		fallback: fallback,
+	}
+}
+
+// UpsertAll writes the notes of every destination. When a destination fails, the results
+// of the destinations that were written before are returned with the error.
+func (r *RoutingNoteRepository) UpsertAll(ctx context.Context, notes []model.Note) ([]OperationResult, error) {
+	repositories, destinations := r.route(notes)
+	results := make([]OperationResult, 0, len(notes))
+
+	for i, routed := range destinations {
+		if len(routed) == 0 {
+			continue
+		}
+
+		routeResults, err := repositories[i].UpsertAll(ctx, routed)
+		results = append(results, routeResults...)
+		if err != nil {
+			return results, fmt.Errorf("could not write notes for destination %d: %w", i+1, err)
+		}
+	}
+
+	return results, nil
+}
+
+// destinations returns the distinct repositories in the order of the routes, with the
+// destination of every route. The fallback repository is the last destination.
+func (r *RoutingNoteRepository) destinations() ([]NoteRepository, []int) {
+	repositories := make([]NoteRepository, 0, len(r.routes)+1)
+	indexes := make([]int, 0, len(r.routes)+1)
+
+	add := func(repository NoteRepository) {
+		for i, existing := range repositories {
+			if existing == repository {
+				indexes = append(indexes, i)
+				return
+			}
+		}
+		indexes = append(indexes, len(repositories))
+		repositories = append(repositories, repository)
+	}
+
+	for _, route := range r.routes {
+		add(route.Repository)
+	}
+	add(r.fallback)
+	return repositories, indexes
+}
+
+// route returns the destinations with their notes
+func (r *RoutingNoteRepository) route(notes []model.Note) ([]NoteRepository, [][]model.Note) {
+	repositories, indexes := r.destinations()
+	destinations := make([][]model.Note, len(repositories))
+
+	for _, note := range notes {
+		highlights := make([][]readdeck.Highlight, len(repositories))
+
+		for _, h := range note.Highlights {
+			destination := indexes[len(r.routes)]
+			for i, route := range r.routes {
+				if route.Matches(h, note.Bookmark) {
+					destination = indexes[i]
+					break
+				}
+			}
+			highlights[destination] = append(highlights[destination], h)
+		}
+
+		for i, hs := range highlights {
+			if len(hs) == 0 {
+				continue
+			}
+
+			routed := note
+			routed.Highlights = hs
+			destinations[i] = append(destinations[i], routed)
+		}
+	}
+
+	return repositories, destinations
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingRepository struct {
+	name  string
+	notes []model.Note
+	err   error
+}
+
+func (r *recordingRepository) UpsertAll(ctx context.Context, notes []model.Note) ([]OperationResult, error) {
+	r.notes = append(r.notes, notes...)
+	results := make([]OperationResult, len(notes))
+	for i, n := range notes {
+		results[i] = OperationResult{Type: "created", Note: model.Note{Path: r.name, Bookmark: n.Bookmark, Highlights: n.Highlights}}
+	}
+	return results, r.err
+}
+
+func highlightIDs(notes []model.Note) map[string][]string {
+	result := make(map[string][]string)
+	for _, n := range notes {
+		for _, h := range n.Highlights {
+			result[n.Bookmark.ID] = append(result[n.Bookmark.ID], h.ID)
+		}
+	}
+	return result
+}
+
+func TestRoutingNoteRepository_UpsertAll(t *testing.T) {
+	literature := &recordingRepository{name: "literature"}
+	inbox := &recordingRepository{name: "inbox"}
+	videos := &recordingRepository{name: "videos"}
+	fleeting := &recordingRepository{name: "fleeting"}
+
+	repo := NewRoutingNoteRepository(fleeting, []Route{
+		{Color: "blue", Repository: literature},
+		{Color: "green", Label: "work", Repository: inbox},
+		{Type: "video", Repository: videos},
+	})
+
+	article := readdeck.Bookmark{ID: "article", Type: "article", Labels: []string{"Work"}}
+	video := readdeck.Bookmark{ID: "video", Type: "video"}
+
+	notes := []model.Note{
+		{Bookmark: article, Highlights: []readdeck.Highlight{
+			{ID: "a1", Color: "blue"},
+			{ID: "a2", Color: "green"},
+			{ID: "a3", Color: "yellow"},
+			{ID: "a4", Color: "Blue"},
+		}},
+		{Bookmark: video, Highlights: []readdeck.Highlight{
+			{ID: "v1", Color: "blue"},
+			{ID: "v2", Color: "green"},
+		}},
+	}
+
+	results, err := repo.UpsertAll(context.Background(), notes)
+	require.NoError(t, err)
+	assert.Len(t, results, 5)
+
+	assert.Equal(t, map[string][]string{"article": {"a1", "a4"}, "video": {"v1"}}, highlightIDs(literature.notes))
+	assert.Equal(t, map[string][]string{"article": {"a2"}}, highlightIDs(inbox.notes))
+	assert.Equal(t, map[string][]string{"video": {"v2"}}, highlightIDs(videos.notes))
+	assert.Equal(t, map[string][]string{"article": {"a3"}}, highlightIDs(fleeting.notes))
+}
+
+func TestRoutingNoteRepository_UpsertAllError(t *testing.T) {
+	written := &recordingRepository{name: "written"}
+	failing := &recordingRepository{name: "failing", err: errors.New("disk full")}
+	repo := NewRoutingNoteRepository(&recordingRepository{}, []Route{
+		{Color: "green", Repository: written},
+		{Color: "blue", Repository: failing},
+	})
+
+	results, err := repo.UpsertAll(context.Background(), []model.Note{
+		{Bookmark: readdeck.Bookmark{ID: "b1"}, Highlights: []readdeck.Highlight{{ID: "h1", Color: "green"}, {ID: "h2", Color: "blue"}}},
+	})
+
+	assert.ErrorContains(t, err, "disk full")
+	// The destinations written before the failure are kept
+	require.Len(t, results, 2)
+	assert.Equal(t, "written", results[0].Note.Path)
+}
+
+func TestRoutingNoteRepository_UpsertAll_SharedDestination(t *testing.T) {
+	shared := &recordingRepository{name: "shared"}
+	fleeting := &recordingRepository{name: "fleeting"}
+	repo := NewRoutingNoteRepository(fleeting, []Route{
+		{Color: "blue", Repository: shared},
+		{Color: "green", Repository: shared},
+	})
+
+	results, err := repo.UpsertAll(context.Background(), []model.Note{
+		{Bookmark: readdeck.Bookmark{ID: "b1"}, Highlights: []readdeck.Highlight{
+			{ID: "h1", Color: "blue"},
+			{ID: "h2", Color: "green"},
+			{ID: "h3", Color: "yellow"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	// One note with the highlights of both routes, not a note per route
+	require.Len(t, shared.notes, 1)
+	assert.Equal(t, map[string][]string{"b1": {"h1", "h2"}}, highlightIDs(shared.notes))
+	assert.Equal(t, map[string][]string{"b1": {"h3"}}, highlightIDs(fleeting.notes))
+}
+
+func TestRoutingNoteRepository_UpsertAll_SharedFolderIsIdempotent(t *testing.T) {
+	tempDir := t.TempDir()
+	fleetingPath := filepath.Join(tempDir, "fleeting")
+	// The route folder doesn't exist yet, the first export creates it
+	literaturePath := filepath.Join(tempDir, "literature")
+
+	newRepository := func(path string) *FileNoteRepository {
+		formatter := NewHighlightFormatter(DefaultColorConfig(), readdeck.OrderPosition)
+		parser := NewYAMLNoteParser()
+		generator := NewYAMLNoteGenerator(formatter, "https://read.example.com")
+		return NewFileNoteRepository(path, NewCustomNoteService(parser, generator, NewYAMLNoteUpdater(generator, parser)), false)
+	}
+	literature := newRepository(literaturePath)
+	repo := NewRoutingNoteRepository(newRepository(fleetingPath), []Route{
+		{Color: "blue", Repository: literature},
+		{Color: "green", Repository: literature},
+	})
+
+	notes := []model.Note{{
+		Bookmark: readdeck.Bookmark{ID: "b1", Title: "Schlep Blindness", Created: time.Now()},
+		Highlights: []readdeck.Highlight{
+			{ID: "h1", Text: "Blue quote", Color: "blue"},
+			{ID: "h2", Text: "Green quote", Color: "green"},
+		},
+	}}
+
+	for run := 0; run < 3; run++ {
+		results, err := repo.UpsertAll(context.Background(), notes)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+	}
+
+	entries, err := os.ReadDir(literaturePath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	content, err := os.ReadFile(filepath.Join(literaturePath, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "Blue quote"))
+	assert.Equal(t, 1, strings.Count(string(content), "Green quote"))
+}
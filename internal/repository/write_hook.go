@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWriteVetoed is returned by a WriteHook that doesn't want the note to be written
+var ErrWriteVetoed = errors.New("write vetoed")
+
+// WriteHook is called around every note file that is written. BeforeWrite gets the content
+// that is about to be written and returns the content to write instead, or ErrWriteVetoed
+// to leave the file alone. Any other error fails the note. AfterWrite is called once the file is written.
+type WriteHook interface {
+	BeforeWrite(ctx context.Context, result OperationResult, content []byte) ([]byte, error)
+	AfterWrite(ctx context.Context, result OperationResult)
+}
+
+// WithWriteHook calls the hook around every note that is written
+func WithWriteHook(hook WriteHook) FileNoteRepositoryOption {
+	return func(f *FileNoteRepository) {
+		f.hook = hook
+	}
+}
@@ -0,0 +1,117 @@
+package review
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	tomorrow := time.Date(2025, 3, 24, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		card             Card
+		grade            Grade
+		expectedInterval int
+		expectedReps     int
+		expectedEase     float64
+	}{
+		{name: "new card good", card: NewCard(), grade: Good, expectedInterval: 1, expectedReps: 1, expectedEase: 2.5},
+		{name: "new card easy", card: NewCard(), grade: Easy, expectedInterval: 1, expectedReps: 1, expectedEase: 2.6},
+		{name: "second review", card: Card{Repetitions: 1, Interval: 1, Ease: 2.5}, grade: Good, expectedInterval: 6, expectedReps: 2, expectedEase: 2.5},
+		{name: "third review hard", card: Card{Repetitions: 2, Interval: 6, Ease: 2.5}, grade: Hard, expectedInterval: 14, expectedReps: 3, expectedEase: 2.36},
+		{name: "again starts over", card: Card{Repetitions: 5, Interval: 40, Ease: 2.5}, grade: Again, expectedInterval: 1, expectedReps: 0, expectedEase: 1.96},
+		{name: "ease has a floor", card: Card{Repetitions: 0, Interval: 1, Ease: 1.4}, grade: Again, expectedInterval: 1, expectedReps: 0, expectedEase: 1.3},
+		{name: "missing ease", card: Card{}, grade: Good, expectedInterval: 1, expectedReps: 1, expectedEase: 2.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := Schedule(tt.card, tt.grade, now)
+
+			assert.Equal(t, tt.expectedInterval, card.Interval)
+			assert.Equal(t, tt.expectedReps, card.Repetitions)
+			assert.InDelta(t, tt.expectedEase, card.Ease, 0.0001)
+			assert.Equal(t, now, card.LastReviewed)
+			assert.Equal(t, tomorrow.AddDate(0, 0, tt.expectedInterval-1), card.Due)
+		})
+	}
+}
+
+func TestState_Select(t *testing.T) {
+	now := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	entries := []library.Entry{{HighlightID: "new1"}, {HighlightID: "due"}, {HighlightID: "later"}, {HighlightID: "new2"}, {HighlightID: "overdue"}}
+
+	s := State{Cards: map[string]Card{
+		"due":     {Ease: 2.5, Due: now.Add(-time.Hour)},
+		"overdue": {Ease: 2.5, Due: now.AddDate(0, 0, -3)},
+		"later":   {Ease: 2.5, Due: now.AddDate(0, 0, 2)},
+	}}
+
+	selected := s.Select(entries, 3, now)
+	require.Len(t, selected, 3)
+	assert.Equal(t, "overdue", selected[0].HighlightID)
+	assert.Equal(t, "due", selected[1].HighlightID)
+	assert.Contains(t, []string{"new1", "new2"}, selected[2].HighlightID)
+
+	// The pick is stable within a day
+	assert.Equal(t, selected, s.Select(entries, 3, now.Add(time.Hour)))
+
+	all := s.Select(entries, 10, now)
+	assert.Len(t, all, 4)
+
+	// Reviews done today count towards the limit
+	s.Grade("overdue", Good, now)
+	s.Grade("due", Good, now)
+	remaining := s.Select(entries, 3, now)
+	require.Len(t, remaining, 1)
+	assert.NotEqual(t, "later", remaining[0].HighlightID)
+
+	assert.Empty(t, s.Select(entries, 2, now))
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	now := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	store := state.NewStore(t.TempDir())
+
+	s, err := LoadState(store)
+	require.NoError(t, err)
+
+	card := s.Grade("h1", Easy, now)
+	require.NoError(t, s.Save(store))
+
+	reloaded, err := LoadState(store)
+	require.NoError(t, err)
+	assert.Equal(t, card.Ease, reloaded.Cards["h1"].Ease)
+	assert.True(t, card.Due.Equal(reloaded.Cards["h1"].Due))
+	assert.Equal(t, 1, reloaded.ReviewedOn(now))
+}
+
+func TestParseGrade(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Grade
+	}{
+		{input: "a", expected: Again},
+		{input: "Hard", expected: Hard},
+		{input: "3", expected: Good},
+		{input: " e ", expected: Easy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			grade, err := ParseGrade(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, grade)
+		})
+	}
+
+	_, err := ParseGrade("maybe")
+	assert.Error(t, err)
+}
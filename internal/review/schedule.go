@@ -0,0 +1,121 @@
+package review
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type Grade int
+
+const (
+	Again Grade = iota
+	Hard
+	Good
+	Easy
+)
+
+func ParseGrade(input string) (Grade, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "a", "again", "1":
+		return Again, nil
+	case "h", "hard", "2":
+		return Hard, nil
+	case "g", "good", "3":
+		return Good, nil
+	case "e", "easy", "4":
+		return Easy, nil
+	default:
+		return 0, fmt.Errorf("unknown grade %q, expected one of: again, hard, good, easy", input)
+	}
+}
+
+func (g Grade) String() string {
+	switch g {
+	case Again:
+		return "again"
+	case Hard:
+		return "hard"
+	case Good:
+		return "good"
+	case Easy:
+		return "easy"
+	default:
+		return fmt.Sprintf("grade(%d)", int(g))
+	}
+}
+
+// quality maps the grade on the 0-5 scale of SM-2
+func (g Grade) quality() int {
+	switch g {
+	case Again:
+		return 1
+	case Hard:
+		return 3
+	case Good:
+		return 4
+	default:
+		return 5
+	}
+}
+
+const (
+	initialEase = 2.5
+	minimumEase = 1.3
+)
+
+// Card holds the scheduling data of a single highlight
+type Card struct {
+	Repetitions int `json:"repetitions"`
+	// Interval is the number of days until the next review
+	Interval     int       `json:"interval"`
+	Ease         float64   `json:"ease"`
+	Due          time.Time `json:"due"`
+	LastReviewed time.Time `json:"last_reviewed"`
+	Reviews      int       `json:"reviews"`
+}
+
+func NewCard() Card {
+	return Card{Ease: initialEase}
+}
+
+// Schedule applies SM-2: failed cards start over tomorrow, passed cards
+// wait 1 day, then 6 days, then the previous interval times the ease
+func Schedule(card Card, grade Grade, now time.Time) Card {
+	if card.Ease == 0 {
+		card.Ease = initialEase
+	}
+
+	q := float64(grade.quality())
+	card.Ease = math.Max(minimumEase, card.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+
+	if grade == Again {
+		card.Repetitions = 0
+		card.Interval = 1
+	} else {
+		card.Repetitions++
+		switch card.Repetitions {
+		case 1:
+			card.Interval = 1
+		case 2:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.Ease))
+		}
+	}
+
+	card.Reviews++
+	card.LastReviewed = now
+	card.Due = startOfDay(now).AddDate(0, 0, card.Interval)
+	return card
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func sameDay(a, b time.Time) bool {
+	return startOfDay(a).Equal(startOfDay(b.In(a.Location())))
+}
@@ -0,0 +1,101 @@
+package review
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const stateName = "review.json"
+
+// State holds the scheduling data per highlight ID
+type State struct {
+	Cards map[string]Card `json:"cards"`
+}
+
+func LoadState(store *state.Store) (State, error) {
+	s := State{Cards: map[string]Card{}}
+	if err := store.Load(stateName, &s); err != nil {
+		return State{}, err
+	}
+	if s.Cards == nil {
+		s.Cards = map[string]Card{}
+	}
+	return s, nil
+}
+
+func (s State) Save(store *state.Store) error {
+	return store.Save(stateName, s)
+}
+
+func (s State) Grade(highlightID string, grade Grade, now time.Time) Card {
+	card, ok := s.Cards[highlightID]
+	if !ok {
+		card = NewCard()
+	}
+
+	card = Schedule(card, grade, now)
+	s.Cards[highlightID] = card
+	return card
+}
+
+// ReviewedOn counts the highlights that were last reviewed on the day of now
+func (s State) ReviewedOn(now time.Time) int {
+	count := 0
+	for _, card := range s.Cards {
+		if !card.LastReviewed.IsZero() && sameDay(now, card.LastReviewed) {
+			count++
+		}
+	}
+	return count
+}
+
+// Select picks the highlights to review today, up to the daily limit minus what was already reviewed.
+// Due cards come first, the most overdue and hardest first. The rest is filled with highlights
+// that were never reviewed, shuffled with the date as seed so the pick is stable within a day.
+func (s State) Select(entries []library.Entry, limit int, now time.Time) []library.Entry {
+	remaining := limit - s.ReviewedOn(now)
+	if remaining <= 0 {
+		return nil
+	}
+
+	var due, fresh []library.Entry
+	for _, entry := range entries {
+		card, ok := s.Cards[entry.HighlightID]
+		switch {
+		case !ok:
+			fresh = append(fresh, entry)
+		case !card.Due.After(now):
+			due = append(due, entry)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		a, b := s.Cards[due[i].HighlightID], s.Cards[due[j].HighlightID]
+		if !a.Due.Equal(b.Due) {
+			return a.Due.Before(b.Due)
+		}
+		return a.Ease < b.Ease
+	})
+
+	random := rand.New(rand.NewSource(daySeed(now)))
+	random.Shuffle(len(fresh), func(i, j int) {
+		fresh[i], fresh[j] = fresh[j], fresh[i]
+	})
+
+	selected := append(due, fresh...)
+	if len(selected) > remaining {
+		selected = selected[:remaining]
+	}
+	return selected
+}
+
+func daySeed(now time.Time) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(now.Format("2006-01-02")))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Scheduler runs a job periodically. Runs are spread with some jitter, so several
+// machines started together don't hit the server at the same moment, and failed
+// runs are retried sooner, with an exponential backoff.
+type Scheduler struct {
+	Interval time.Duration
+	// Jitter is the fraction of the delay that is randomly added or removed, eg. 0.1 for ±10%
+	Jitter float64
+	// RetryDelay is the delay after the first failure, it doubles with every failure that follows
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the backoff, it never exceeds the interval either
+	MaxRetryDelay time.Duration
+
+	random func() float64
+}
+
+func NewScheduler(interval time.Duration) *Scheduler {
+	return &Scheduler{
+		Interval:      interval,
+		Jitter:        0.1,
+		RetryDelay:    time.Minute,
+		MaxRetryDelay: 30 * time.Minute,
+		random:        rand.Float64,
+	}
+}
+
+// Delay is the time to wait before the next run, after the given number of consecutive failures
+func (s *Scheduler) Delay(failures int) time.Duration {
+	delay := s.Interval
+	if failures > 0 {
+		delay = s.RetryDelay
+		for i := 1; i < failures && delay < s.MaxRetryDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, s.MaxRetryDelay, s.Interval)
+	}
+
+	jitter := time.Duration(float64(delay) * s.Jitter * (2*s.random() - 1))
+	return delay + jitter
+}
+
+// Run runs the job right away and then after every delay, until the context is done.
+// A job that is running when the context is done is waited for. After every run,
+// next is called with the error of the run and the delay until the next one.
+func (s *Scheduler) Run(ctx context.Context, job func(context.Context) error, next func(err error, delay time.Duration)) {
+	failures := 0
+	for {
+		err := job(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		delay := s.Delay(failures)
+		if next != nil {
+			next(err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
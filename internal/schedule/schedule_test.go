@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_Delay(t *testing.T) {
+	scheduler := NewScheduler(15 * time.Minute)
+	scheduler.MaxRetryDelay = 10 * time.Minute
+	scheduler.random = func() float64 { return 0.5 }
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 15 * time.Minute},
+		{failures: 1, expected: time.Minute},
+		{failures: 2, expected: 2 * time.Minute},
+		{failures: 4, expected: 8 * time.Minute},
+		{failures: 5, expected: 10 * time.Minute},
+		{failures: 100, expected: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, scheduler.Delay(tt.failures), "failures: %d", tt.failures)
+	}
+
+	// The backoff never waits longer than a regular run
+	scheduler.Interval = 3 * time.Minute
+	assert.Equal(t, 3*time.Minute, scheduler.Delay(5))
+}
+
+func TestScheduler_DelayJitter(t *testing.T) {
+	scheduler := NewScheduler(10 * time.Minute)
+
+	scheduler.random = func() float64 { return 0 }
+	assert.Equal(t, 9*time.Minute, scheduler.Delay(0))
+
+	scheduler.random = func() float64 { return 1 }
+	assert.Equal(t, 11*time.Minute, scheduler.Delay(0))
+}
+
+func TestScheduler_Run(t *testing.T) {
+	scheduler := NewScheduler(time.Millisecond)
+	scheduler.RetryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var errs []error
+	runs := 0
+	scheduler.Run(ctx, func(ctx context.Context) error {
+		runs++
+		if runs == 2 {
+			return errors.New("failed")
+		}
+		if runs == 4 {
+			// The run that is going on when the context is cancelled still finishes
+			cancel()
+		}
+		return nil
+	}, func(err error, delay time.Duration) {
+		errs = append(errs, err)
+	})
+
+	assert.Equal(t, 4, runs)
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[1], "failed")
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+)
+
+const stateName = "search-index.json"
+
+// Index is an inverted index of the highlight texts in the library
+type Index struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	// Terms has the positions of every term, by highlight ID
+	Terms map[string]map[string][]int `json:"terms"`
+	// Documents has a hash of the indexed text of every highlight, so unchanged highlights are skipped
+	Documents map[string]Document `json:"documents"`
+}
+
+type Document struct {
+	Hash  string   `json:"hash"`
+	Terms []string `json:"terms"`
+}
+
+type SyncResult struct {
+	Indexed int
+	Removed int
+}
+
+func Load(store *state.Store) (*Index, error) {
+	index := &Index{}
+	if err := store.Load(stateName, index); err != nil {
+		return nil, err
+	}
+	if index.Terms == nil {
+		index.Terms = make(map[string]map[string][]int)
+	}
+	if index.Documents == nil {
+		index.Documents = make(map[string]Document)
+	}
+	return index, nil
+}
+
+func (i *Index) Save(store *state.Store) error {
+	return store.Save(stateName, i)
+}
+
+// Sync brings the index in line with the library: new and changed highlights are (re)indexed,
+// highlights that are gone are removed. Unchanged highlights are left alone.
+func (i *Index) Sync(entries []library.Entry, at time.Time) SyncResult {
+	var result SyncResult
+	current := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		current[entry.HighlightID] = true
+		hash := fmt.Sprintf("%x", sha1.Sum([]byte(entry.Text)))
+		if doc, ok := i.Documents[entry.HighlightID]; ok && doc.Hash == hash {
+			continue
+		}
+
+		i.remove(entry.HighlightID)
+		i.add(entry.HighlightID, entry.Text, hash)
+		result.Indexed++
+	}
+
+	for id := range i.Documents {
+		if !current[id] {
+			i.remove(id)
+			result.Removed++
+		}
+	}
+
+	if result.Indexed > 0 || result.Removed > 0 {
+		i.UpdatedAt = at.UTC()
+	}
+	return result
+}
+
+func (i *Index) add(id string, text string, hash string) {
+	seen := make(map[string]bool)
+	var terms []string
+
+	for position, term := range Tokenize(text) {
+		if i.Terms[term] == nil {
+			i.Terms[term] = make(map[string][]int)
+		}
+		i.Terms[term][id] = append(i.Terms[term][id], position)
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	i.Documents[id] = Document{Hash: hash, Terms: terms}
+}
+
+func (i *Index) remove(id string) {
+	doc, ok := i.Documents[id]
+	if !ok {
+		return
+	}
+	for _, term := range doc.Terms {
+		delete(i.Terms[term], id)
+		if len(i.Terms[term]) == 0 {
+			delete(i.Terms, term)
+		}
+	}
+	delete(i.Documents, id)
+}
+
+// Tokenize splits the text into lower case words, punctuation is dropped
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+)
+
+// Query holds the words that all have to be in a highlight, and the phrases that have to be in it as is
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+}
+
+// ParseQuery reads words and "quoted phrases", an unclosed quote runs until the end of the input
+func ParseQuery(input string) Query {
+	var query Query
+	for i, part := range strings.Split(input, `"`) {
+		words := Tokenize(part)
+		// Every odd part was between quotes
+		if i%2 == 1 && len(words) > 1 {
+			query.Phrases = append(query.Phrases, words)
+			continue
+		}
+		query.Terms = append(query.Terms, words...)
+	}
+	return query
+}
+
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// Filter narrows the results down on the metadata of the highlight and its bookmark
+type Filter struct {
+	Colors []string
+	// Site, Author and Label match case-insensitively, Site and Author on a part of the value
+	Site   string
+	Author string
+	Label  string
+	// Since and Until limit the creation time of the highlight, Until is exclusive
+	Since *time.Time
+	Until *time.Time
+}
+
+func (f Filter) IsEmpty() bool {
+	return len(f.Colors) == 0 && f.Site == "" && f.Author == "" && f.Label == "" && f.Since == nil && f.Until == nil
+}
+
+func (f Filter) Matches(entry library.Entry) bool {
+	if len(f.Colors) > 0 && !containsFold(f.Colors, entry.Color) {
+		return false
+	}
+	if f.Site != "" && !strings.Contains(host(entry.URL), strings.ToLower(f.Site)) {
+		return false
+	}
+	if f.Author != "" && !anyContains(entry.Authors, f.Author) {
+		return false
+	}
+	if f.Label != "" && !containsFold(entry.Labels, f.Label) {
+		return false
+	}
+	if f.Since != nil && entry.Created.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !entry.Created.Before(*f.Until) {
+		return false
+	}
+	return true
+}
+
+type Result struct {
+	Entry library.Entry
+	// Score is the number of times the query words occur in the highlight
+	Score int
+}
+
+// Search finds the highlights that match the query and the filter, best matches first.
+// An empty query lists every highlight that matches the filter, newest first.
+func (i *Index) Search(query Query, filter Filter, entries map[string]library.Entry) []Result {
+	words := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		words = append(words, phrase...)
+	}
+
+	var results []Result
+	for id, entry := range entries {
+		if !filter.Matches(entry) {
+			continue
+		}
+
+		score, ok := i.score(id, words, query.Phrases)
+		if !ok {
+			continue
+		}
+		results = append(results, Result{Entry: entry, Score: score})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		if !results[a].Entry.Created.Equal(results[b].Entry.Created) {
+			return results[a].Entry.Created.After(results[b].Entry.Created)
+		}
+		return results[a].Entry.HighlightID < results[b].Entry.HighlightID
+	})
+	return results
+}
+
+func (i *Index) score(id string, words []string, phrases [][]string) (int, bool) {
+	score := 0
+	for _, word := range words {
+		positions := i.Terms[word][id]
+		if len(positions) == 0 {
+			return 0, false
+		}
+		score += len(positions)
+	}
+
+	for _, phrase := range phrases {
+		if !i.hasPhrase(id, phrase) {
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// hasPhrase checks that the words of the phrase follow each other somewhere in the highlight
+func (i *Index) hasPhrase(id string, phrase []string) bool {
+	for _, start := range i.Terms[phrase[0]][id] {
+		found := true
+		for offset, word := range phrase[1:] {
+			if !containsInt(i.Terms[word][id], start+offset+1) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyContains(values []string, part string) bool {
+	part = strings.ToLower(part)
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), part) {
+			return true
+		}
+	}
+	return false
+}
+
+func host(siteUrl string) string {
+	parsed, err := url.Parse(siteUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
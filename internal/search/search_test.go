@@ -0,0 +1,113 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntries() []library.Entry {
+	march := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	return []library.Entry{
+		{
+			HighlightID: "h1", Text: "The most striking example of schlep blindness is Stripe.", Color: "yellow", Created: march,
+			BookmarkTitle: "Schlep Blindness", Authors: []string{"Paul Graham"}, Labels: []string{"Startups"},
+			URL: "https://www.paulgraham.com/schlep.html", Path: "/notes/schlep.md",
+		},
+		{
+			HighlightID: "h2", Text: "Blindness to schleps, and schleps again.", Color: "green", Created: march.AddDate(0, 1, 0),
+			BookmarkTitle: "Schlep Blindness", Authors: []string{"Paul Graham"}, URL: "https://www.paulgraham.com/schlep.html",
+		},
+		{
+			HighlightID: "h3", Text: "Stripe is a payment company", Color: "yellow", Created: march,
+			BookmarkTitle: "Payments", URL: "https://example.com/payments",
+		},
+	}
+}
+
+func byID(entries []library.Entry) map[string]library.Entry {
+	result := make(map[string]library.Entry, len(entries))
+	for _, e := range entries {
+		result[e.HighlightID] = e
+	}
+	return result
+}
+
+func ids(results []Result) []string {
+	result := make([]string, 0, len(results))
+	for _, r := range results {
+		result = append(result, r.Entry.HighlightID)
+	}
+	return result
+}
+
+func TestParseQuery(t *testing.T) {
+	assert.Equal(t, Query{Terms: []string{"stripe", "example"}, Phrases: [][]string{{"schlep", "blindness"}}},
+		ParseQuery(`Stripe "schlep blindness" example`))
+	assert.Equal(t, Query{Terms: []string{"stripe", "unclosed"}}, ParseQuery(`stripe "unclosed`))
+	assert.True(t, ParseQuery(` "" , `).IsEmpty())
+}
+
+func TestIndex_Search(t *testing.T) {
+	entries := testEntries()
+	index, err := Load(state.NewStore(t.TempDir()))
+	require.NoError(t, err)
+	index.Sync(entries, time.Now())
+
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    string
+		filter   Filter
+		expected []string
+	}{
+		{name: "all words have to match", query: "stripe schlep", expected: []string{"h1"}},
+		{name: "most occurrences first", query: "schleps", expected: []string{"h2"}},
+		{name: "punctuation and case are ignored", query: "STRIPE.", expected: []string{"h1", "h3"}},
+		{name: "phrase", query: `"schlep blindness"`, expected: []string{"h1"}},
+		{name: "phrase words out of order", query: `"blindness schlep"`, expected: []string{}},
+		{name: "colour", query: "stripe", filter: Filter{Colors: []string{"Yellow"}}, expected: []string{"h1", "h3"}},
+		{name: "site", query: "stripe", filter: Filter{Site: "paulgraham"}, expected: []string{"h1"}},
+		{name: "author", query: "blindness", filter: Filter{Author: "graham"}, expected: []string{"h2", "h1"}},
+		{name: "label", query: "", filter: Filter{Label: "startups"}, expected: []string{"h1"}},
+		{name: "date", query: "", filter: Filter{Since: &march, Until: &april}, expected: []string{"h1", "h3"}},
+		{name: "no match", query: "unknown", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := index.Search(ParseQuery(tt.query), tt.filter, byID(entries))
+			assert.Equal(t, tt.expected, ids(results))
+		})
+	}
+}
+
+func TestIndex_Sync(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	index, err := Load(store)
+	require.NoError(t, err)
+
+	entries := testEntries()
+	assert.Equal(t, SyncResult{Indexed: 3}, index.Sync(entries, time.Now()))
+	require.NoError(t, index.Save(store))
+
+	index, err = Load(store)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{}, index.Sync(entries, time.Now()))
+
+	// The text of h3 changed and h2 is gone
+	entries[2].Text = "Payments are a schlep"
+	entries = []library.Entry{entries[0], entries[2]}
+	assert.Equal(t, SyncResult{Indexed: 1, Removed: 1}, index.Sync(entries, time.Now()))
+
+	assert.Equal(t, []string{"h1"}, ids(index.Search(ParseQuery("stripe"), Filter{}, byID(entries))))
+	assert.Equal(t, []string{"h3"}, ids(index.Search(ParseQuery("payments"), Filter{}, byID(entries))))
+	assert.NotContains(t, index.Terms, "again")
+	assert.NotContains(t, index.Documents, "h2")
+}
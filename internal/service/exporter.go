@@ -3,61 +3,214 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
 )
 
 type Exporter struct {
-	readdeckClient readdeck.Client
-	noteRepository repository.NoteRepository
+	readdeckClient  readdeck.Client
+	noteRepository  repository.NoteRepository
+	resolveChapters bool
+	fetchSelectors  bool
+	filter          Filter
+	citations       CitationRegistry
+	bookmarkIDs     []string
+	stages          []Stage
+	reportStage     func(StageReport)
+	progress        progress.Reporter
 }
 
-func NewExporter(client readdeck.Client, repo repository.NoteRepository) *Exporter {
-	return &Exporter{
+type ExporterOption func(*Exporter)
+
+// WithChapters fetches the article of every bookmark to find the heading each highlight falls under.
+// It costs an extra request per bookmark, so only enable it when sections are grouped by chapter.
+func WithChapters() ExporterOption {
+	return func(e *Exporter) {
+		e.resolveChapters = true
+	}
+}
+
+// WithSelectors fetches the annotations of the bookmarks whose highlights came without a start selector.
+// The list of all highlights doesn't reliably carry them, while ordering on the position and grouping
+// by chapter depend on them. It costs a request per such bookmark.
+func WithSelectors() ExporterOption {
+	return func(e *Exporter) {
+		e.fetchSelectors = true
+	}
+}
+
+// CitationRegistry hands out the citekeys of bookmarks, by bookmark ID
+type CitationRegistry interface {
+	Assign(bookmarks []readdeck.Bookmark) map[string]string
+}
+
+// WithCitations sets the citekey of every collected note
+func WithCitations(registry CitationRegistry) ExporterOption {
+	return func(e *Exporter) {
+		e.citations = registry
+	}
+}
+
+// WithBookmarks limits the export to the highlights of these bookmarks. Clients that can fetch
+// the highlights of a single bookmark only fetch those, others fetch all and leave out the rest.
+func WithBookmarks(ids ...string) ExporterOption {
+	return func(e *Exporter) {
+		e.bookmarkIDs = ids
+	}
+}
+
+// WithProgress reports every bookmark that is resolved
+func WithProgress(reporter progress.Reporter) ExporterOption {
+	return func(e *Exporter) {
+		e.progress = reporter
+	}
+}
+
+func NewExporter(client readdeck.Client, repo repository.NoteRepository, opts ...ExporterOption) *Exporter {
+	exporter := &Exporter{
 		readdeckClient: client,
 		noteRepository: repo,
+		progress:       progress.Nop,
+	}
+
+	for _, opt := range opts {
+		opt(exporter)
 	}
+
+	return exporter
 }
 
 // Entrypoint
 // Needs to get highlights, details, parse them and save them
 func (e *Exporter) Export(ctx context.Context) ([]repository.OperationResult, error) {
-	highlights, err := e.readdeckClient.GetHighlights(ctx, nil)
+	notes, err := e.Collect(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	groupedHighlights := e.groupHighlightsByBookmark(highlights)
+	return e.noteRepository.UpsertAll(ctx, notes)
+}
+
+// Collect fetches the highlights, groups them per bookmark and runs the stages, without writing anything
+func (e *Exporter) Collect(ctx context.Context) ([]model.Note, error) {
+	highlights, err := e.getHighlights(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groupedHighlights := e.groupHighlightsByBookmark(e.filterHighlights(highlights))
 	bookmarkHighlights, err := e.resolveBookmarks(ctx, groupedHighlights)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return e.noteRepository.UpsertAll(ctx, bookmarkHighlights)
+	return e.runStages(ctx, bookmarkHighlights)
+}
+
+func (e *Exporter) getHighlights(ctx context.Context) ([]readdeck.Highlight, error) {
+	if len(e.bookmarkIDs) == 0 {
+		return e.readdeckClient.GetHighlights(ctx, e.filter.Since)
+	}
+
+	if client, ok := e.readdeckClient.(readdeck.BookmarkHighlightsClient); ok {
+		var res []readdeck.Highlight
+		for _, id := range e.bookmarkIDs {
+			highlights, err := client.GetBookmarkHighlights(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("Could not retrieve highlights of bookmark with id %s: %w", id, err)
+			}
+			res = append(res, highlights...)
+		}
+		return res, nil
+	}
+
+	highlights, err := e.readdeckClient.GetHighlights(ctx, e.filter.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(e.bookmarkIDs))
+	for _, id := range e.bookmarkIDs {
+		wanted[id] = true
+	}
+
+	res := make([]readdeck.Highlight, 0, len(highlights))
+	for _, h := range highlights {
+		if wanted[h.BookmarkID] {
+			res = append(res, h)
+		}
+	}
+	return res, nil
 }
 
+// resolveBookmarks fetches the bookmarks, the most recently highlighted first, and keeps those
+// that match the filter. Once the limit of the filter is reached, the rest isn't fetched.
 func (e *Exporter) resolveBookmarks(ctx context.Context, dict map[string][]readdeck.Highlight) ([]model.Note, error) {
 	res := make([]model.Note, 0, len(dict))
 
-	for id, highlights := range dict {
+	for i, id := range recentFirst(dict) {
+		if e.filter.Limit > 0 && len(res) >= e.filter.Limit {
+			break
+		}
+
 		b, err := e.readdeckClient.GetBookmark(ctx, id)
 
 		if err != nil {
 			return nil, fmt.Errorf("Could not retrieve bookmark with id %s: %w", id, err)
 		}
+		e.progress.Report(progress.Event{Stage: progress.Bookmarks, Done: i + 1, Total: len(dict)})
+
+		if !e.filter.MatchesBookmark(b) {
+			continue
+		}
 
 		res = append(res, model.Note{
 			Bookmark:   b,
-			Highlights: highlights,
+			Highlights: dict[id],
 		})
 	}
 
 	return res, nil
 }
 
+// recentFirst orders the bookmark IDs by their latest highlight, newest first
+func recentFirst(dict map[string][]readdeck.Highlight) []string {
+	latest := make(map[string]time.Time, len(dict))
+	ids := make([]string, 0, len(dict))
+	for id, highlights := range dict {
+		ids = append(ids, id)
+		for _, h := range highlights {
+			if h.Created.After(latest[id]) {
+				latest[id] = h.Created
+			}
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if !latest[ids[i]].Equal(latest[ids[j]]) {
+			return latest[ids[i]].After(latest[ids[j]])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func (e *Exporter) filterHighlights(highlights []readdeck.Highlight) []readdeck.Highlight {
+	res := make([]readdeck.Highlight, 0, len(highlights))
+	for _, h := range highlights {
+		if e.filter.MatchesHighlight(h) {
+			res = append(res, h)
+		}
+	}
+	return res
+}
+
 func (e *Exporter) groupHighlightsByBookmark(highlights []readdeck.Highlight) map[string][]readdeck.Highlight {
 	res := make(map[string][]readdeck.Highlight)
 
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/progress"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
 	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
 	"github.com/stretchr/testify/assert"
@@ -98,6 +99,28 @@ func TestExport(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCollect(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	mockRepo := new(MockNoteRepository)
+	exporter := NewExporter(mockClient, mockRepo)
+
+	ctx := context.Background()
+
+	highlight := readdeck.Highlight{ID: "h1", BookmarkID: "book1", Text: "First highlight"}
+	bookmark := readdeck.Bookmark{ID: "book1", Title: "Test Book 1"}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{highlight}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(bookmark, nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Note{{Bookmark: bookmark, Highlights: []readdeck.Highlight{highlight}}}, notes)
+
+	mockClient.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpsertAll", mock.Anything, mock.Anything)
+}
+
 func TestResolveBookmarks(t *testing.T) {
 	mockClient := new(MockReaddeckClient)
 	exporter := NewExporter(mockClient, nil)
@@ -220,3 +243,210 @@ func TestGroupHighlightsByBookmark(t *testing.T) {
 	assert.Equal(t, "h1", grouped["book1"][0].ID)
 	assert.Equal(t, "h2", grouped["book1"][1].ID)
 }
+
+type MockArticleClient struct {
+	MockReaddeckClient
+}
+
+func (m *MockArticleClient) GetArticle(ctx context.Context, bookmarkId string) (string, error) {
+	args := m.Called(ctx, bookmarkId)
+	return args.String(0), args.Error(1)
+}
+
+func TestExportWithChapters(t *testing.T) {
+	mockClient := new(MockArticleClient)
+	mockRepo := new(MockNoteRepository)
+	exporter := NewExporter(mockClient, mockRepo, WithChapters())
+
+	ctx := context.Background()
+
+	highlight := readdeck.Highlight{ID: "h1", BookmarkID: "book1", StartSelector: "p[1]"}
+	bookmark := readdeck.Bookmark{ID: "book1", Title: "Test Book 1"}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{highlight}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(bookmark, nil)
+	mockClient.On("GetArticle", ctx, "book1").Return("<h2>Chapter one</h2><p>Text</p>", nil)
+	mockRepo.On("UpsertAll", ctx, mock.MatchedBy(func(notes []model.Note) bool {
+		return len(notes) == 1 && notes[0].Highlights[0].Chapter == "Chapter one"
+	})).Return([]repository.OperationResult{}, nil)
+
+	_, err := exporter.Export(ctx)
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportWithChaptersUnsupportedClient(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	exporter := NewExporter(mockClient, nil, WithChapters())
+
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+
+	_, err := exporter.Export(ctx)
+
+	assert.ErrorContains(t, err, "fetch articles")
+}
+
+type stubCitations map[string]string
+
+func (s stubCitations) Assign(bookmarks []readdeck.Bookmark) map[string]string {
+	return s
+}
+
+func TestCollectWithCitations(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	exporter := NewExporter(mockClient, nil, WithCitations(stubCitations{"book1": "graham2012schlep"}))
+
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "graham2012schlep", notes[0].Citekey)
+}
+
+type MockBookmarkHighlightsClient struct {
+	MockReaddeckClient
+}
+
+func (m *MockBookmarkHighlightsClient) GetBookmarkHighlights(ctx context.Context, bookmarkId string) ([]readdeck.Highlight, error) {
+	args := m.Called(ctx, bookmarkId)
+	return args.Get(0).([]readdeck.Highlight), args.Error(1)
+}
+
+// MockFullClient serves both the articles and the highlights of a bookmark
+type MockFullClient struct {
+	MockBookmarkHighlightsClient
+}
+
+func (m *MockFullClient) GetArticle(ctx context.Context, bookmarkId string) (string, error) {
+	args := m.Called(ctx, bookmarkId)
+	return args.String(0), args.Error(1)
+}
+
+func TestCollectWithChaptersFetchesSelectors(t *testing.T) {
+	mockClient := new(MockFullClient)
+	exporter := NewExporter(mockClient, nil, WithChapters())
+
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+	mockClient.On("GetBookmarkHighlights", ctx, "book1").Return([]readdeck.Highlight{{ID: "h1", StartSelector: "p[2]"}}, nil)
+	mockClient.On("GetArticle", ctx, "book1").Return("<p>Intro</p><h2>Chapter one</h2><p>Text</p>", nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Chapter one", notes[0].Highlights[0].Chapter)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCollectWithBookmarks(t *testing.T) {
+	mockClient := new(MockBookmarkHighlightsClient)
+	exporter := NewExporter(mockClient, nil, WithBookmarks("book1"))
+
+	ctx := context.Background()
+
+	mockClient.On("GetBookmarkHighlights", ctx, "book1").Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "GetHighlights", ctx)
+}
+
+func TestCollectWithBookmarksUnsupportedClient(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	exporter := NewExporter(mockClient, nil, WithBookmarks("book2"))
+
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}, {ID: "h2", BookmarkID: "book2"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book2").Return(readdeck.Bookmark{ID: "book2"}, nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+	assert.Equal(t, "h2", notes[0].Highlights[0].ID)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCollectWithSelectors(t *testing.T) {
+	mockClient := new(MockBookmarkHighlightsClient)
+	exporter := NewExporter(mockClient, nil, WithSelectors())
+
+	ctx := context.Background()
+
+	// The list of all highlights comes without selectors, those of a bookmark carry them
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{
+		{ID: "h1", BookmarkID: "book1"},
+		{ID: "h2", BookmarkID: "book1"},
+		{ID: "h3", BookmarkID: "book2", StartSelector: "p[1]"},
+	}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+	mockClient.On("GetBookmark", ctx, "book2").Return(readdeck.Bookmark{ID: "book2"}, nil)
+	mockClient.On("GetBookmarkHighlights", ctx, "book1").Return([]readdeck.Highlight{
+		{ID: "h1", StartSelector: "section/p[2]", StartOffset: 4, EndSelector: "section/p[2]", EndOffset: 20},
+		{ID: "h2", StartSelector: "section/p[1]"},
+	}, nil)
+
+	notes, err := exporter.Collect(ctx)
+
+	assert.NoError(t, err)
+	byID := make(map[string]readdeck.Highlight)
+	for _, note := range notes {
+		for _, h := range note.Highlights {
+			byID[h.ID] = h
+		}
+	}
+	assert.Equal(t, "section/p[2]", byID["h1"].StartSelector)
+	assert.Equal(t, 4, byID["h1"].StartOffset)
+	assert.Equal(t, 20, byID["h1"].EndOffset)
+	assert.Equal(t, "section/p[1]", byID["h2"].StartSelector)
+	assert.Equal(t, "book1", byID["h1"].BookmarkID)
+	mockClient.AssertExpectations(t)
+	// Bookmarks whose highlights all have a selector aren't fetched again
+	mockClient.AssertNotCalled(t, "GetBookmarkHighlights", ctx, "book2")
+}
+
+type recordingReporter struct {
+	events []progress.Event
+}
+
+func (r *recordingReporter) Report(event progress.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestCollectWithProgress(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	march := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	h1 := readdeck.Highlight{ID: "h1", BookmarkID: "b1", Created: march}
+	h2 := readdeck.Highlight{ID: "h2", BookmarkID: "b2", Created: march.AddDate(0, 0, 1)}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{h1, h2}, nil)
+	mockClient.On("GetBookmark", ctx, "b1").Return(readdeck.Bookmark{ID: "b1"}, nil)
+	mockClient.On("GetBookmark", ctx, "b2").Return(readdeck.Bookmark{ID: "b2"}, nil)
+
+	reporter := &recordingReporter{}
+	_, err := NewExporter(mockClient, nil, WithProgress(reporter)).Collect(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []progress.Event{
+		{Stage: progress.Bookmarks, Done: 1, Total: 2},
+		{Stage: progress.Bookmarks, Done: 2, Total: 2},
+	}, reporter.events)
+}
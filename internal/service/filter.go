@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// Filter limits the highlights that are collected. Empty fields don't filter.
+type Filter struct {
+	// Since and Until limit the creation time of highlights, Until is exclusive
+	Since *time.Time
+	Until *time.Time
+	// Labels keeps the bookmarks that carry at least one of them
+	Labels []string
+	// Sites keeps the bookmarks of these domains, subdomains included
+	Sites []string
+	// Types keeps the bookmarks of these types, eg. article or video
+	Types []string
+	// Colors keeps the highlights of these colours
+	Colors []string
+	// Exclude drops the highlights and bookmarks that match any of its rules
+	Exclude Rules
+	// Limit is the maximum number of bookmarks, the most recently highlighted ones are kept
+	Limit int
+}
+
+// Rules match the labels, site and type of a bookmark, or the colour of a highlight
+type Rules struct {
+	Labels []string
+	Sites  []string
+	Types  []string
+	Colors []string
+}
+
+func WithFilter(filter Filter) ExporterOption {
+	return func(e *Exporter) {
+		e.filter = filter
+	}
+}
+
+func (f Filter) MatchesHighlight(h readdeck.Highlight) bool {
+	if f.Since != nil && h.Created.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !h.Created.Before(*f.Until) {
+		return false
+	}
+	if len(f.Colors) > 0 && !containsFold(f.Colors, h.Color) {
+		return false
+	}
+	return !containsFold(f.Exclude.Colors, h.Color)
+}
+
+func (f Filter) MatchesBookmark(b readdeck.Bookmark) bool {
+	if len(f.Labels) > 0 && !anyFold(f.Labels, b.Labels) {
+		return false
+	}
+	if len(f.Sites) > 0 && !matchesSite(f.Sites, b.SiteUrl) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsFold(f.Types, b.Type) {
+		return false
+	}
+
+	return !anyFold(f.Exclude.Labels, b.Labels) &&
+		!matchesSite(f.Exclude.Sites, b.SiteUrl) &&
+		!containsFold(f.Exclude.Types, b.Type)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyFold(wanted []string, values []string) bool {
+	for _, value := range values {
+		if containsFold(wanted, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSite checks the domain of the URL against the sites, www. is ignored
+// and a site matches its subdomains too: example.com matches blog.example.com
+func matchesSite(sites []string, siteUrl string) bool {
+	parsed, err := url.Parse(siteUrl)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+
+	for _, site := range sites {
+		site = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(site)), "www.")
+		if site != "" && (host == site || strings.HasSuffix(host, "."+site)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTime reads a moment for a filter: an RFC 3339 time, a date (2006-01-02)
+// or a duration back from now in days, weeks, months or years (eg. 30d, 2w, 1m, 1y)
+func ParseTime(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", input, now.Location()); err == nil {
+		return t, nil
+	}
+
+	if len(input) > 1 {
+		amount, err := strconv.Atoi(input[:len(input)-1])
+		if err == nil && amount >= 0 {
+			switch input[len(input)-1] {
+			case 'd':
+				return now.AddDate(0, 0, -amount), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*amount), nil
+			case 'm':
+				return now.AddDate(0, -amount, 0), nil
+			case 'y':
+				return now.AddDate(-amount, 0, 0), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date (2006-01-02), an RFC 3339 time or a duration like 30d, 2w, 1m or 1y", input)
+}
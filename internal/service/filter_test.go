@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectWithFilter(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	march := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	h1 := readdeck.Highlight{ID: "h1", BookmarkID: "book1", Created: march}
+	h2 := readdeck.Highlight{ID: "h2", BookmarkID: "book1", Created: april}
+	h3 := readdeck.Highlight{ID: "h3", BookmarkID: "book2", Created: march}
+	book1 := readdeck.Bookmark{ID: "book1", Title: "Tagged", Labels: []string{"Reading"}}
+	book2 := readdeck.Bookmark{ID: "book2", Title: "Untagged"}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{h1, h2, h3}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(book1, nil)
+	mockClient.On("GetBookmark", ctx, "book2").Return(book2, nil)
+
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	exporter := NewExporter(mockClient, nil, WithFilter(Filter{Since: &since, Until: &until, Labels: []string{"reading"}}))
+
+	notes, err := exporter.Collect(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, []model.Note{{Bookmark: book1, Highlights: []readdeck.Highlight{h1}}}, notes)
+}
+
+func TestFilter_MatchesBookmark(t *testing.T) {
+	bookmark := readdeck.Bookmark{Labels: []string{"Startups", "essays"}, SiteUrl: "https://www.paulgraham.com/schlep.html", Type: "article"}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{name: "no filter", filter: Filter{}, expected: true},
+		{name: "label", filter: Filter{Labels: []string{"startups"}}, expected: true},
+		{name: "other label", filter: Filter{Labels: []string{"books"}}, expected: false},
+		{name: "site", filter: Filter{Sites: []string{"paulgraham.com"}}, expected: true},
+		{name: "parent domain", filter: Filter{Sites: []string{"graham.com"}}, expected: false},
+		{name: "type", filter: Filter{Types: []string{"video", "Article"}}, expected: true},
+		{name: "other type", filter: Filter{Types: []string{"video"}}, expected: false},
+		{name: "all rules have to match", filter: Filter{Labels: []string{"essays"}, Types: []string{"video"}}, expected: false},
+		{name: "excluded label", filter: Filter{Labels: []string{"essays"}, Exclude: Rules{Labels: []string{"startups"}}}, expected: false},
+		{name: "excluded site", filter: Filter{Exclude: Rules{Sites: []string{"www.paulgraham.com"}}}, expected: false},
+		{name: "excluded type", filter: Filter{Exclude: Rules{Types: []string{"article"}}}, expected: false},
+		{name: "other exclusions", filter: Filter{Exclude: Rules{Labels: []string{"books"}, Sites: []string{"example.com"}, Types: []string{"photo"}}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.MatchesBookmark(bookmark))
+		})
+	}
+}
+
+func TestFilter_MatchesHighlight(t *testing.T) {
+	highlight := readdeck.Highlight{Color: "yellow"}
+
+	assert.True(t, Filter{Colors: []string{"Yellow", "green"}}.MatchesHighlight(highlight))
+	assert.False(t, Filter{Colors: []string{"green"}}.MatchesHighlight(highlight))
+	assert.False(t, Filter{Exclude: Rules{Colors: []string{"yellow"}}}.MatchesHighlight(highlight))
+}
+
+func TestCollectWithLimit(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	march := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	h1 := readdeck.Highlight{ID: "h1", BookmarkID: "old", Created: march}
+	h2 := readdeck.Highlight{ID: "h2", BookmarkID: "video", Created: march.AddDate(0, 0, 2)}
+	h3 := readdeck.Highlight{ID: "h3", BookmarkID: "recent", Created: march.AddDate(0, 0, 1)}
+	h4 := readdeck.Highlight{ID: "h4", BookmarkID: "old", Created: march.AddDate(0, 0, -1)}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{h1, h2, h3, h4}, nil)
+	mockClient.On("GetBookmark", ctx, "video").Return(readdeck.Bookmark{ID: "video", Type: "video"}, nil)
+	mockClient.On("GetBookmark", ctx, "recent").Return(readdeck.Bookmark{ID: "recent", Type: "article"}, nil)
+
+	// The most recently highlighted bookmark that matches is kept, the older one isn't even fetched
+	exporter := NewExporter(mockClient, nil, WithFilter(Filter{Types: []string{"article"}, Limit: 1}))
+	notes, err := exporter.Collect(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "recent", notes[0].Bookmark.ID)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "GetBookmark", ctx, "old")
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{input: "2025-03-01", expected: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2025-03-01T10:00:00Z", expected: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{input: "30d", expected: time.Date(2025, 3, 16, 12, 0, 0, 0, time.UTC)},
+		{input: "2w", expected: time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)},
+		{input: " 1m ", expected: time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{input: "1y", expected: time.Date(2024, 4, 15, 12, 0, 0, 0, time.UTC)},
+		{input: "last month", wantErr: true},
+		{input: "-1d", wantErr: true},
+		{input: "d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseTime(tt.input, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExportWindowedThenFull(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	march := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)
+	h1 := readdeck.Highlight{ID: "h1", BookmarkID: "book1", Text: "Written in March", Color: "yellow", Created: march}
+	h2 := readdeck.Highlight{ID: "h2", BookmarkID: "book1", Text: "Written in April", Color: "yellow", Created: april}
+	book1 := readdeck.Bookmark{ID: "book1", Title: "Schlep Blindness", Created: march}
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{h1, h2}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(book1, nil)
+
+	dir := t.TempDir()
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	repo := repository.NewFileNoteRepository(dir, repository.NewFormatNoteService(repository.MarkdownFormat, formatter, "https://read.example.com"), false)
+
+	// Only March, only April, then full: every highlight is in the note once
+	boundary := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	for _, filter := range []Filter{{Until: &boundary}, {Since: &boundary}, {}, {}} {
+		_, err := NewExporter(mockClient, repo, WithFilter(filter)).Export(ctx)
+		require.NoError(t, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	content, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "Written in March"))
+	assert.Equal(t, 1, strings.Count(string(content), "Written in April"))
+}
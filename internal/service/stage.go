@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// Stage transforms the notes between the resolution of their bookmarks and their upsert,
+// eg. to clean up the text of highlights or to enrich the notes
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, notes []model.Note) ([]model.Note, error)
+}
+
+// StageReport is what a stage did during a run
+type StageReport struct {
+	Stage    string
+	Duration time.Duration
+	// NotesIn and NotesOut are the number of notes before and after the stage
+	NotesIn  int
+	NotesOut int
+	Err      error
+}
+
+// WithStages adds transform stages, they run in order after the built-in enrichment
+// (citekeys and chapters)
+func WithStages(stages ...Stage) ExporterOption {
+	return func(e *Exporter) {
+		e.stages = append(e.stages, stages...)
+	}
+}
+
+// WithStageReport is called after every stage, also when the stage failed
+func WithStageReport(report func(StageReport)) ExporterOption {
+	return func(e *Exporter) {
+		e.reportStage = report
+	}
+}
+
+// pipeline lists the stages of a run: the enrichment the options asked for, then the added stages
+func (e *Exporter) pipeline() []Stage {
+	var stages []Stage
+	if e.citations != nil {
+		stages = append(stages, citekeyStage{registry: e.citations})
+	}
+	// Chapters are found from the selectors
+	if e.fetchSelectors || e.resolveChapters {
+		stages = append(stages, selectorStage{client: e.readdeckClient})
+	}
+	if e.resolveChapters {
+		stages = append(stages, chapterStage{client: e.readdeckClient})
+	}
+	return append(stages, e.stages...)
+}
+
+func (e *Exporter) runStages(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	for _, stage := range e.pipeline() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		processed, err := stage.Process(ctx, notes)
+		report := StageReport{Stage: stage.Name(), Duration: time.Since(start), NotesIn: len(notes), NotesOut: len(processed), Err: err}
+		if e.reportStage != nil {
+			e.reportStage(report)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("stage %s failed: %w", stage.Name(), err)
+		}
+		notes = processed
+	}
+
+	return notes, nil
+}
+
+// citekeyStage sets the citekey of every note
+type citekeyStage struct {
+	registry CitationRegistry
+}
+
+func (s citekeyStage) Name() string {
+	return "citekeys"
+}
+
+func (s citekeyStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	bookmarks := make([]readdeck.Bookmark, 0, len(notes))
+	for _, note := range notes {
+		bookmarks = append(bookmarks, note.Bookmark)
+	}
+
+	keys := s.registry.Assign(bookmarks)
+	for i := range notes {
+		notes[i].Citekey = keys[notes[i].Bookmark.ID]
+	}
+	return notes, nil
+}
+
+// selectorStage completes the highlights that came without a start selector,
+// from the annotations of their bookmark
+type selectorStage struct {
+	client readdeck.Client
+}
+
+func (s selectorStage) Name() string {
+	return "selectors"
+}
+
+func (s selectorStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	annotations, ok := s.client.(readdeck.BookmarkHighlightsClient)
+	if !ok {
+		// The highlights keep the selectors they have, positions fall back on creation
+		return notes, nil
+	}
+
+	for i, note := range notes {
+		if !missingSelectors(note.Highlights) {
+			continue
+		}
+
+		fetched, err := annotations.GetBookmarkHighlights(ctx, note.Bookmark.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Could not retrieve highlights of bookmark with id %s: %w", note.Bookmark.ID, err)
+		}
+		byID := make(map[string]readdeck.Highlight, len(fetched))
+		for _, h := range fetched {
+			byID[h.ID] = h
+		}
+
+		highlights := make([]readdeck.Highlight, len(note.Highlights))
+		for j, h := range note.Highlights {
+			if found, ok := byID[h.ID]; ok && h.StartSelector == "" {
+				h.StartSelector, h.StartOffset = found.StartSelector, found.StartOffset
+				h.EndSelector, h.EndOffset = found.EndSelector, found.EndOffset
+			}
+			highlights[j] = h
+		}
+		notes[i].Highlights = highlights
+	}
+
+	return notes, nil
+}
+
+func missingSelectors(highlights []readdeck.Highlight) bool {
+	for _, h := range highlights {
+		if h.StartSelector == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// chapterStage finds the heading of the article every highlight falls under
+type chapterStage struct {
+	client readdeck.Client
+}
+
+func (s chapterStage) Name() string {
+	return "chapters"
+}
+
+func (s chapterStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	articles, ok := s.client.(readdeck.ArticleClient)
+	if !ok {
+		return nil, fmt.Errorf("grouping by chapter needs a client that can fetch articles")
+	}
+
+	res := make([]model.Note, len(notes))
+	for i, note := range notes {
+		article, err := articles.GetArticle(ctx, note.Bookmark.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Could not retrieve article for bookmark with id %s: %w", note.Bookmark.ID, err)
+		}
+
+		res[i] = note
+		res[i].Highlights = readdeck.ResolveChapters(article, note.Highlights)
+	}
+
+	return res, nil
+}
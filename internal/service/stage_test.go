@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStage appends its name to the title of every bookmark, so the order of the stages shows
+type stubStage struct {
+	name string
+	err  error
+}
+
+func (s stubStage) Name() string {
+	return s.name
+}
+
+func (s stubStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for i := range notes {
+		notes[i].Bookmark.Title += " " + s.name
+	}
+	return notes, nil
+}
+
+func TestCollectWithStages(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1", Title: "Title"}, nil)
+
+	var reports []StageReport
+	exporter := NewExporter(mockClient, nil,
+		WithCitations(stubCitations{"book1": "key"}),
+		WithStages(stubStage{name: "first"}, stubStage{name: "second"}),
+		WithStageReport(func(report StageReport) { reports = append(reports, report) }))
+
+	notes, err := exporter.Collect(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Title first second", notes[0].Bookmark.Title)
+	assert.Equal(t, "key", notes[0].Citekey)
+
+	require.Len(t, reports, 3)
+	for i, name := range []string{"citekeys", "first", "second"} {
+		assert.Equal(t, name, reports[i].Stage)
+		assert.Equal(t, 1, reports[i].NotesIn)
+		assert.Equal(t, 1, reports[i].NotesOut)
+		assert.NoError(t, reports[i].Err)
+	}
+}
+
+func TestCollectWithFailingStage(t *testing.T) {
+	mockClient := new(MockReaddeckClient)
+	ctx := context.Background()
+
+	mockClient.On("GetHighlights", ctx).Return([]readdeck.Highlight{{ID: "h1", BookmarkID: "book1"}}, nil)
+	mockClient.On("GetBookmark", ctx, "book1").Return(readdeck.Bookmark{ID: "book1"}, nil)
+
+	failure := errors.New("boom")
+	var reports []StageReport
+	exporter := NewExporter(mockClient, nil,
+		WithStages(stubStage{name: "broken", err: failure}, stubStage{name: "never"}),
+		WithStageReport(func(report StageReport) { reports = append(reports, report) }))
+
+	_, err := exporter.Collect(ctx)
+
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "stage broken")
+	require.Len(t, reports, 1)
+	assert.ErrorIs(t, reports[0].Err, failure)
+}
+
+func TestBuiltinStages(t *testing.T) {
+	tests := []struct {
+		stage    string
+		texts    []string
+		expected []string
+	}{
+		{
+			stage:    "clean-whitespace",
+			texts:    []string{"  Too   many spaces \r\n\r\n\r\n\r\n  next\tparagraph  "},
+			expected: []string{"Too many spaces\n\nnext paragraph"},
+		},
+		{
+			stage:    "straight-quotes",
+			texts:    []string{"“Schlep” isn’t ‘ugly’"},
+			expected: []string{`"Schlep" isn't 'ugly'`},
+		},
+		{
+			stage:    "dedupe",
+			texts:    []string{"Ugly problems", "Something else", "ugly  problems"},
+			expected: []string{"Ugly problems", "Something else"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			stage, err := NewStage(tt.stage)
+			require.NoError(t, err)
+			assert.Equal(t, tt.stage, stage.Name())
+
+			note := model.Note{Bookmark: readdeck.Bookmark{ID: "book1"}}
+			for _, text := range tt.texts {
+				note.Highlights = append(note.Highlights, readdeck.Highlight{Text: text})
+			}
+
+			notes, err := stage.Process(context.Background(), []model.Note{note})
+			require.NoError(t, err)
+
+			var texts []string
+			for _, h := range notes[0].Highlights {
+				texts = append(texts, h.Text)
+			}
+			assert.Equal(t, tt.expected, texts)
+		})
+	}
+}
+
+func TestNewStages(t *testing.T) {
+	stages, err := NewStages([]string{"dedupe", " Clean-Whitespace "})
+	require.NoError(t, err)
+	require.Len(t, stages, 2)
+	assert.Equal(t, "dedupe", stages[0].Name())
+	assert.Equal(t, "clean-whitespace", stages[1].Name())
+
+	_, err = NewStages([]string{"dedupe", "translate"})
+	assert.ErrorContains(t, err, `unknown stage "translate"`)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+)
+
+// builtinStages are the stages that can be listed in the configuration, by name
+var builtinStages = map[string]func() Stage{
+	"clean-whitespace": func() Stage { return highlightStage{name: "clean-whitespace", transform: cleanWhitespace} },
+	"straight-quotes":  func() Stage { return highlightStage{name: "straight-quotes", transform: straightQuotes.Replace} },
+	"dedupe":           func() Stage { return dedupeStage{} },
+}
+
+// NewStage creates the built-in stage with the given name
+func NewStage(name string) (Stage, error) {
+	create, ok := builtinStages[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown stage %q, expected one of: %s", name, strings.Join(StageNames(), ", "))
+	}
+	return create(), nil
+}
+
+// NewStages creates the built-in stages in the given order
+func NewStages(names []string) ([]Stage, error) {
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		stage, err := NewStage(name)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+func StageNames() []string {
+	names := make([]string, 0, len(builtinStages))
+	for name := range builtinStages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// highlightStage rewrites the text of every highlight
+type highlightStage struct {
+	name      string
+	transform func(string) string
+}
+
+func (s highlightStage) Name() string {
+	return s.name
+}
+
+func (s highlightStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	res := make([]model.Note, len(notes))
+	for i, note := range notes {
+		res[i] = note
+		res[i].Highlights = make([]readdeck.Highlight, len(note.Highlights))
+		for j, h := range note.Highlights {
+			h.Text = s.transform(h.Text)
+			res[i].Highlights[j] = h
+		}
+	}
+	return res, nil
+}
+
+var (
+	spaces     = regexp.MustCompile(`[ \t\x{00a0}]+`)
+	emptyLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// cleanWhitespace collapses runs of spaces, trims every line and keeps at most one empty line between paragraphs
+func cleanWhitespace(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(emptyLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var straightQuotes = strings.NewReplacer("‘", "'", "’", "'", "‚", "'", "“", `"`, "”", `"`, "„", `"`)
+
+// dedupeStage drops highlights of a note whose text was highlighted before in the same note,
+// eg. when the same passage was highlighted twice
+type dedupeStage struct{}
+
+func (s dedupeStage) Name() string {
+	return "dedupe"
+}
+
+func (s dedupeStage) Process(ctx context.Context, notes []model.Note) ([]model.Note, error) {
+	res := make([]model.Note, len(notes))
+	for i, note := range notes {
+		res[i] = note
+		res[i].Highlights = make([]readdeck.Highlight, 0, len(note.Highlights))
+
+		seen := make(map[string]bool, len(note.Highlights))
+		for _, h := range note.Highlights {
+			key := strings.ToLower(strings.Join(strings.Fields(h.Text), " "))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			res[i].Highlights = append(res[i].Highlights, h)
+		}
+	}
+	return res, nil
+}
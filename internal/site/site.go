@@ -0,0 +1,348 @@
+package site
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Page is a single file of the site, Path is relative to the output directory
+type Page struct {
+	Path    string
+	Content []byte
+}
+
+// Generator renders a static site: an index, a page per bookmark, a page per label and a search index
+type Generator struct {
+	Formatter *repository.HighlightFormatter
+	Title     string
+	templates map[string]*template.Template
+}
+
+func NewGenerator(formatter *repository.HighlightFormatter, title string) (*Generator, error) {
+	templates := make(map[string]*template.Template)
+	functions := template.FuncMap{"join": strings.Join}
+
+	for _, name := range []string{"index", "bookmark", "tag"} {
+		t, err := template.New(name).Funcs(functions).ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s template: %w", name, err)
+		}
+		templates[name] = t
+	}
+
+	return &Generator{
+		Formatter: formatter,
+		Title:     title,
+		templates: templates,
+	}, nil
+}
+
+type pageData struct {
+	Title     string
+	SiteTitle string
+	// Root is the relative path back to the output directory, so the site works from any location
+	Root string
+	Data interface{}
+}
+
+type bookmarkLink struct {
+	Title string
+	Path  string
+	Count int
+}
+
+type tagLink struct {
+	Name string
+	Path string
+}
+
+type indexSection struct {
+	Name   string
+	Groups []indexGroup
+}
+
+type indexGroup struct {
+	ID        string
+	Title     string
+	Bookmarks []bookmarkLink
+}
+
+type indexData struct {
+	Highlights int
+	Bookmarks  []bookmarkLink
+	Sections   []indexSection
+}
+
+type bookmarkData struct {
+	Title     string
+	Authors   []string
+	URL       string
+	Site      string
+	Published time.Time
+	Tags      []tagLink
+	Groups    []highlightGroup
+}
+
+type highlightGroup struct {
+	Title      string
+	Highlights []highlightData
+}
+
+type highlightData struct {
+	ID        string
+	Color     string
+	ColorName string
+	Lines     []string
+}
+
+type searchEntry struct {
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	Text  string   `json:"text"`
+	Tags  []string `json:"tags"`
+}
+
+// Build renders every page of the site. Pages only depend on the notes,
+// so rendering the same notes twice gives the same bytes.
+func (g *Generator) Build(notes []model.Note) ([]Page, error) {
+	sorted := make([]model.Note, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Bookmark.Title) < strings.ToLower(sorted[j].Bookmark.Title)
+	})
+
+	var pages []Page
+	var search []searchEntry
+	links := make([]bookmarkLink, 0, len(sorted))
+	bySite := make(map[string][]bookmarkLink)
+	byAuthor := make(map[string][]bookmarkLink)
+	byTag := make(map[string][]bookmarkLink)
+	highlights := 0
+
+	for _, note := range sorted {
+		path := bookmarkPath(note.Bookmark)
+		link := bookmarkLink{Title: note.Bookmark.Title, Path: path, Count: len(note.Highlights)}
+		links = append(links, link)
+		highlights += len(note.Highlights)
+
+		site := siteName(note.Bookmark.SiteUrl)
+		bySite[site] = append(bySite[site], link)
+		for _, author := range note.Bookmark.Authors {
+			byAuthor[author] = append(byAuthor[author], link)
+		}
+		for _, label := range note.Bookmark.Labels {
+			byTag[label] = append(byTag[label], link)
+		}
+
+		for _, h := range g.Formatter.SortHighlights(note.Highlights) {
+			search = append(search, searchEntry{
+				Title: note.Bookmark.Title,
+				URL:   path + "#" + h.ID,
+				Text:  strings.TrimSpace(h.Text),
+				Tags:  nonNil(note.Bookmark.Labels),
+			})
+		}
+	}
+
+	// Labels like "AI" and "ai" share a slug, every label needs a page of its own
+	tags := sortedKeys(byTag)
+	tagSlugs := uniqueSlugs(tags)
+
+	for _, note := range sorted {
+		content, err := g.render("bookmark", note.Bookmark.Title, "../", g.bookmarkData(note, siteName(note.Bookmark.SiteUrl), tagSlugs))
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, Page{Path: bookmarkPath(note.Bookmark), Content: content})
+	}
+
+	for _, tag := range tags {
+		content, err := g.render("tag", tag, "../", byTag[tag])
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, Page{Path: tagPath(tagSlugs[tag]), Content: content})
+	}
+
+	index := indexData{
+		Highlights: highlights,
+		Bookmarks:  links,
+		Sections: []indexSection{
+			{Name: "site", Groups: indexGroups("site", bySite)},
+			{Name: "author", Groups: indexGroups("author", byAuthor)},
+			{Name: "label", Groups: indexGroups("label", byTag)},
+		},
+	}
+	content, err := g.render("index", "Index", "", index)
+	if err != nil {
+		return nil, err
+	}
+	pages = append(pages, Page{Path: "index.html", Content: content})
+
+	searchIndex, err := json.Marshal(search)
+	if err != nil {
+		return nil, fmt.Errorf("could not build search index: %w", err)
+	}
+	pages = append(pages, Page{Path: "search-index.js", Content: []byte(fmt.Sprintf("window.SEARCH_INDEX = %s;\n", searchIndex))})
+
+	for _, asset := range []string{"style.css", "search.js"} {
+		content, err := templateFiles.ReadFile("templates/" + asset)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", asset, err)
+		}
+		pages = append(pages, Page{Path: asset, Content: content})
+	}
+
+	return pages, nil
+}
+
+func (g *Generator) bookmarkData(note model.Note, site string, tagSlugs map[string]string) bookmarkData {
+	b := note.Bookmark
+	data := bookmarkData{
+		Title:     b.Title,
+		Authors:   b.Authors,
+		URL:       b.SiteUrl,
+		Site:      site,
+		Published: b.Published,
+	}
+
+	for _, label := range b.Labels {
+		data.Tags = append(data.Tags, tagLink{Name: label, Path: tagPath(tagSlugs[label])})
+	}
+
+	for _, group := range g.Formatter.GroupHighlights(b.Type, note.Highlights) {
+		hg := highlightGroup{Title: group.Title}
+		for _, h := range group.Highlights {
+			hg.Highlights = append(hg.Highlights, g.highlightData(h))
+		}
+		data.Groups = append(data.Groups, hg)
+	}
+
+	return data
+}
+
+func (g *Generator) highlightData(h readdeck.Highlight) highlightData {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(h.Text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	name := h.Color
+	if friendly, ok := g.Formatter.ColorConfig.ColorNames[h.Color]; ok {
+		name = friendly
+	}
+
+	return highlightData{
+		ID:        h.ID,
+		Color:     util.Slug(h.Color),
+		ColorName: name,
+		Lines:     lines,
+	}
+}
+
+func (g *Generator) render(name string, title string, root string, data interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := g.templates[name].ExecuteTemplate(&buffer, "layout", pageData{
+		Title:     title,
+		SiteTitle: g.Title,
+		Root:      root,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not render %s page %q: %w", name, title, err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func indexGroups(prefix string, links map[string][]bookmarkLink) []indexGroup {
+	keys := sortedKeys(links)
+	slugs := uniqueSlugs(keys)
+	groups := make([]indexGroup, 0, len(links))
+	for _, key := range keys {
+		groups = append(groups, indexGroup{ID: prefix + "-" + slugs[key], Title: key, Bookmarks: links[key]})
+	}
+	return groups
+}
+
+// bookmarkPath uses the same ID as the notes, so pages and notes are easy to match
+func bookmarkPath(b readdeck.Bookmark) string {
+	return "bookmarks/" + util.GenerateId(b.Title, b.Created) + ".html"
+}
+
+func tagPath(tagSlug string) string {
+	return "tags/" + tagSlug + ".html"
+}
+
+// uniqueSlugs gives every name its own slug, names that slug the same as an earlier
+// one get a numbered suffix. The names are sorted, so the suffixes are stable.
+func uniqueSlugs(names []string) map[string]string {
+	slugs := make(map[string]string, len(names))
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		base := slug(name)
+		s := base
+		for i := 2; used[s]; i++ {
+			s = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[s] = true
+		slugs[name] = s
+	}
+	return slugs
+}
+
+// slug falls back on a hash for names without any latin letters or digits
+func slug(name string) string {
+	if s := util.Slug(name); s != "" {
+		return s
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%x", h.Sum32())
+}
+
+func siteName(siteUrl string) string {
+	parsed, err := url.Parse(siteUrl)
+	if err != nil || parsed.Host == "" {
+		return "Unknown site"
+	}
+	return strings.TrimPrefix(parsed.Host, "www.")
+}
+
+func sortedKeys(m map[string][]bookmarkLink) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if a, b := strings.ToLower(keys[i]), strings.ToLower(keys[j]); a != b {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,161 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/model"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/readdeck"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotes() []model.Note {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+
+	return []model.Note{
+		{
+			Bookmark: readdeck.Bookmark{
+				ID:      "b1",
+				Title:   "Schlep Blindness",
+				Created: created,
+				SiteUrl: "https://www.paulgraham.com/schlep.html",
+				Authors: []string{"Paul Graham"},
+				Labels:  []string{"startups"},
+				Type:    "article",
+			},
+			Highlights: []readdeck.Highlight{
+				{ID: "h1", Text: "Schleps <are> hard", Color: "yellow", StartSelector: "p[1]"},
+				{ID: "h2", Text: "A takeaway", Color: "green", StartSelector: "p[2]"},
+			},
+		},
+		{
+			Bookmark: readdeck.Bookmark{ID: "b2", Title: "Another one", Created: created, Type: "article"},
+			Highlights: []readdeck.Highlight{
+				{ID: "h3", Text: "Something else", Color: "blue"},
+			},
+		},
+	}
+}
+
+func newTestGenerator(t *testing.T) *Generator {
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
+	generator, err := NewGenerator(formatter, "Team highlights")
+	require.NoError(t, err)
+	return generator
+}
+
+func pagesByPath(pages []Page) map[string]string {
+	result := make(map[string]string, len(pages))
+	for _, page := range pages {
+		result[page.Path] = string(page.Content)
+	}
+	return result
+}
+
+func TestGenerator_Build(t *testing.T) {
+	pages, err := newTestGenerator(t).Build(testNotes())
+	require.NoError(t, err)
+
+	byPath := pagesByPath(pages)
+	assert.Len(t, byPath, 7)
+
+	index := byPath["index.html"]
+	assert.Contains(t, index, "3 highlights from 2 sources.")
+	assert.Contains(t, index, `<h3 id="site-paulgraham-com">paulgraham.com</h3>`)
+	assert.Contains(t, index, `<h3 id="author-paul-graham">Paul Graham</h3>`)
+	assert.Contains(t, index, `<h3 id="label-startups">startups</h3>`)
+	assert.Contains(t, index, "Unknown site")
+	assert.Contains(t, index, `<a href="bookmarks/1742760960-schlep-blindness.html">Schlep Blindness</a>`)
+
+	bookmark := byPath["bookmarks/1742760960-schlep-blindness.html"]
+	assert.Contains(t, bookmark, `<link rel="stylesheet" href="../style.css">`)
+	assert.Contains(t, bookmark, `<a class="tag" href="../tags/startups.html">startups</a>`)
+	assert.Contains(t, bookmark, "<h2>Key takeaways</h2>")
+	assert.Contains(t, bookmark, `<blockquote id="h1" class="highlight color-yellow" title="General highlights"><p>Schleps &lt;are&gt; hard</p></blockquote>`)
+	assert.Less(t, strings.Index(bookmark, "Key takeaways"), strings.Index(bookmark, "General highlights</h2>"))
+
+	assert.Contains(t, byPath["tags/startups.html"], `<a href="../bookmarks/1742760960-schlep-blindness.html">Schlep Blindness</a>`)
+
+	search := byPath["search-index.js"]
+	assert.True(t, strings.HasPrefix(search, "window.SEARCH_INDEX = ["))
+	assert.Contains(t, search, `"url":"bookmarks/1742760960-schlep-blindness.html#h1"`)
+	// Markup in highlights can't break out of the script
+	assert.Contains(t, search, `"text":"Schleps \u003care\u003e hard"`)
+
+	assert.Contains(t, byPath["style.css"], ".color-green")
+	assert.Contains(t, byPath["search.js"], "SEARCH_INDEX")
+}
+
+func TestGenerator_Build_CollidingTags(t *testing.T) {
+	created := time.Date(2025, 3, 23, 20, 16, 0, 0, time.UTC)
+	notes := []model.Note{
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b1", Title: "Models", Created: created, Labels: []string{"ai", "C++"}},
+			Highlights: []readdeck.Highlight{{ID: "h1", Text: "Attention", Color: "yellow"}},
+		},
+		{
+			Bookmark:   readdeck.Bookmark{ID: "b2", Title: "Pointers", Created: created, Labels: []string{"AI", "C"}},
+			Highlights: []readdeck.Highlight{{ID: "h2", Text: "Undefined behaviour", Color: "yellow"}},
+		},
+	}
+
+	pages, err := newTestGenerator(t).Build(notes)
+	require.NoError(t, err)
+	byPath := pagesByPath(pages)
+
+	assert.Contains(t, byPath["tags/ai.html"], "Pointers")
+	assert.NotContains(t, byPath["tags/ai.html"], "Models")
+	assert.Contains(t, byPath["tags/ai-2.html"], "Models")
+	assert.Contains(t, byPath["tags/c.html"], "Pointers")
+	assert.Contains(t, byPath["tags/c-2.html"], "Models")
+
+	assert.Contains(t, byPath["bookmarks/1742760960-models.html"], `<a class="tag" href="../tags/ai-2.html">ai</a>`)
+	assert.Contains(t, byPath["bookmarks/1742760960-pointers.html"], `<a class="tag" href="../tags/ai.html">AI</a>`)
+
+	index := byPath["index.html"]
+	assert.Contains(t, index, `<h3 id="label-ai">AI</h3>`)
+	assert.Contains(t, index, `<h3 id="label-ai-2">ai</h3>`)
+
+	// Rendering again gives the same pages
+	again, err := newTestGenerator(t).Build(notes)
+	require.NoError(t, err)
+	assert.Equal(t, pages, again)
+}
+
+func TestWrite_Incremental(t *testing.T) {
+	dir := t.TempDir()
+	generator := newTestGenerator(t)
+	notes := testNotes()
+
+	pages, err := generator.Build(notes)
+	require.NoError(t, err)
+
+	result, err := Write(dir, pages)
+	require.NoError(t, err)
+	assert.Equal(t, WriteResult{Written: 7}, result)
+
+	// Nothing changed
+	result, err = Write(dir, pages)
+	require.NoError(t, err)
+	assert.Equal(t, WriteResult{Unchanged: 7}, result)
+
+	// A new highlight on the second bookmark, and the label of the first one is gone
+	notes[0].Bookmark.Labels = nil
+	notes[1].Highlights = append(notes[1].Highlights, readdeck.Highlight{ID: "h4", Text: "New", Color: "red"})
+	pages, err = generator.Build(notes)
+	require.NoError(t, err)
+
+	result, err = Write(dir, pages)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 2, result.Unchanged)
+	assert.Equal(t, 4, result.Written)
+
+	_, err = os.Stat(filepath.Join(dir, "tags", "startups.html"))
+	assert.True(t, os.IsNotExist(err))
+}
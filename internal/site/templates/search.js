@@ -0,0 +1,35 @@
+// Filters the highlights of search-index.js, every word of the query has to match
+(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+  var index = window.SEARCH_INDEX || [];
+  var root = input.getAttribute("data-root") || "";
+
+  function render(query) {
+    results.innerHTML = "";
+    var words = query.toLowerCase().split(/\s+/).filter(Boolean);
+    if (words.length === 0) {
+      return;
+    }
+
+    var matches = index.filter(function (entry) {
+      var haystack = (entry.text + " " + entry.title + " " + entry.tags.join(" ")).toLowerCase();
+      return words.every(function (word) { return haystack.indexOf(word) >= 0; });
+    }).slice(0, 50);
+
+    matches.forEach(function (entry) {
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = root + entry.url;
+      link.textContent = entry.title;
+      var snippet = document.createElement("span");
+      snippet.className = "snippet";
+      snippet.textContent = entry.text.length > 200 ? entry.text.slice(0, 200) + "…" : entry.text;
+      item.appendChild(link);
+      item.appendChild(snippet);
+      results.appendChild(item);
+    });
+  }
+
+  input.addEventListener("input", function () { render(input.value); });
+})();
@@ -0,0 +1,98 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The manifest lists the files of the previous build, so pages of removed bookmarks or labels are cleaned up
+const manifestName = ".readdeck-site.json"
+
+type WriteResult struct {
+	Written   int
+	Unchanged int
+	Removed   int
+}
+
+// Write regenerates the site incrementally: files with the same content are left alone,
+// so their modification time and any sync tooling are not disturbed
+func Write(dir string, pages []Page) (WriteResult, error) {
+	var result WriteResult
+
+	previous, err := readManifest(dir)
+	if err != nil {
+		return result, err
+	}
+
+	current := make(map[string]bool, len(pages))
+	for _, page := range pages {
+		current[page.Path] = true
+		path := filepath.Join(dir, filepath.FromSlash(page.Path))
+
+		existing, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(existing, page.Content) {
+			result.Unchanged++
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return result, fmt.Errorf("could not create directory for %s: %w", page.Path, err)
+		}
+		if err := os.WriteFile(path, page.Content, 0644); err != nil {
+			return result, fmt.Errorf("could not write %s: %w", page.Path, err)
+		}
+		result.Written++
+	}
+
+	for _, path := range previous {
+		if current[path] {
+			continue
+		}
+		err := os.Remove(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("could not remove %s: %w", path, err)
+		}
+		result.Removed++
+	}
+
+	return result, writeManifest(dir, current)
+}
+
+func readManifest(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read site manifest: %w", err)
+	}
+
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil, fmt.Errorf("could not read site manifest: %w", err)
+	}
+	return paths, nil
+}
+
+func writeManifest(dir string, current map[string]bool) error {
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	data, err := json.MarshalIndent(paths, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not write site manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), data, 0644); err != nil {
+		return fmt.Errorf("could not write site manifest: %w", err)
+	}
+	return nil
+}
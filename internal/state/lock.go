@@ -0,0 +1,67 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned when another process holds the lock
+var ErrLocked = errors.New("locked by another process")
+
+// Lock is an advisory lock on a file in the state directory. It only keeps out
+// processes that take the same lock, eg. two exports writing to the same vault.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// Lock takes the lock without waiting for it. When another process holds it, the
+// error wraps ErrLocked and mentions the PID of that process if it is known.
+func (s *Store) Lock(name string) (*Lock, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	path := s.Path(name)
+	file, err := lockFile(path)
+	if errors.Is(err, ErrLocked) {
+		if pid := readPID(path); pid != 0 {
+			return nil, fmt.Errorf("%s is %w (pid %d)", name, ErrLocked, pid)
+		}
+		return nil, fmt.Errorf("%s is %w", name, ErrLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not lock %s: %w", name, err)
+	}
+
+	// The PID is only informative, the lock itself is what keeps other processes out
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{file: file, path: path}, nil
+}
+
+// Release gives the lock back, the lock file is left in place
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	// Clear the PID first, so a stale one is never reported
+	l.file.Truncate(0)
+	err := unlockFile(l.file, l.path)
+	l.file = nil
+	return err
+}
+
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
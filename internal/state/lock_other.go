@@ -0,0 +1,40 @@
+//go:build !unix
+
+package state
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// lockFile creates the file exclusively, the lock is held for as long as the file exists.
+// A file left behind by a process that is gone is taken over.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if !errors.Is(err, fs.ErrExist) {
+		return file, err
+	}
+
+	pid := readPID(path)
+	if pid == 0 || pid == os.Getpid() {
+		return nil, ErrLocked
+	}
+	if _, err := os.FindProcess(pid); err == nil {
+		return nil, ErrLocked
+	}
+
+	if err := os.Remove(path); err != nil {
+		return nil, ErrLocked
+	}
+	file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, ErrLocked
+	}
+	return file, err
+}
+
+func unlockFile(file *os.File, path string) error {
+	file.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,35 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Lock(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+	store := NewStore(dir)
+
+	lock, err := store.Lock("export.lock")
+	require.NoError(t, err)
+
+	_, err = store.Lock("export.lock")
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.Contains(t, err.Error(), fmt.Sprintf("pid %d", os.Getpid()))
+
+	// Other locks are independent
+	other, err := store.Lock("other.lock")
+	require.NoError(t, err)
+	require.NoError(t, other.Release())
+
+	require.NoError(t, lock.Release())
+	require.NoError(t, lock.Release())
+
+	lock, err = store.Lock("export.lock")
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/util"
+)
+
+// Store keeps small JSON documents, eg. what was exported before, in a single directory
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// Load decodes the document into v. A document that does not exist yet leaves v untouched.
+func (s *Store) Load(name string, v interface{}) error {
+	data, err := os.ReadFile(s.Path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read state %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("could not decode state %s: %w", name, err)
+	}
+	return nil
+}
+
+// Save writes the document to a temporary file first and renames it,
+// so an interrupted run never leaves a half written state behind
+func (s *Store) Save(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode state %s: %w", name, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	// The state is the user's own, it stays private
+	if err := util.WriteFileAtomic(s.Path(name), data, 0600); err != nil {
+		return fmt.Errorf("could not write state %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDocument struct {
+	Names []string `json:"names"`
+}
+
+func TestStore_LoadMissing(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	document := testDocument{Names: []string{"untouched"}}
+	require.NoError(t, store.Load("missing.json", &document))
+	assert.Equal(t, []string{"untouched"}, document.Names)
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+	store := NewStore(dir)
+
+	require.NoError(t, store.Save("doc.json", testDocument{Names: []string{"a", "b"}}))
+	require.NoError(t, store.Save("doc.json", testDocument{Names: []string{"c"}}))
+
+	var document testDocument
+	require.NoError(t, store.Load("doc.json", &document))
+	assert.Equal(t, []string{"c"}, document.Names)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestStore_LoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "doc.json"), []byte("{"), 0644))
+
+	var document testDocument
+	assert.Error(t, NewStore(dir).Load("doc.json", &document))
+}
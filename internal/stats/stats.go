@@ -0,0 +1,291 @@
+package stats
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+)
+
+const day = 24 * time.Hour
+
+type Options struct {
+	// Top is the number of sites, authors and labels to keep
+	Top int
+	// Weeks is the number of recent weeks to count, the current one included
+	Weeks int
+	// Streaks is the number of longest streaks to keep
+	Streaks int
+	// Location decides on which day a highlight was made
+	Location *time.Location
+}
+
+func DefaultOptions() Options {
+	return Options{Top: 5, Weeks: 12, Streaks: 3, Location: time.Local}
+}
+
+// Stats summarises the highlighting habits over every exported highlight
+type Stats struct {
+	Highlights int `json:"highlights"`
+	Bookmarks  int `json:"bookmarks"`
+	// AveragePerBookmark is the average number of highlights of a highlighted bookmark
+	AveragePerBookmark float64   `json:"average_per_bookmark"`
+	First              time.Time `json:"first,omitempty"`
+	Last               time.Time `json:"last,omitempty"`
+
+	// Months runs from the month of the first highlight until the month of the last one
+	Months []Period `json:"months"`
+	Weeks  []Period `json:"weeks"`
+	Colors []Color  `json:"colors"`
+
+	Sites   []Count `json:"sites"`
+	Authors []Count `json:"authors"`
+	Labels  []Count `json:"labels"`
+
+	// Streaks are runs of days with at least one highlight, longest first
+	Streaks       []Streak `json:"streaks"`
+	CurrentStreak Streak   `json:"current_streak"`
+}
+
+type Period struct {
+	// Name is 2006-01 for months and the ISO week (2006-W01) for weeks
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	Highlights int       `json:"highlights"`
+}
+
+type Color struct {
+	Color      string `json:"color"`
+	Name       string `json:"name"`
+	Highlights int    `json:"highlights"`
+}
+
+type Count struct {
+	Name       string `json:"name"`
+	Highlights int    `json:"highlights"`
+	Bookmarks  int    `json:"bookmarks"`
+}
+
+type Streak struct {
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+	Days  int       `json:"days"`
+}
+
+func Compute(entries []library.Entry, colors repository.ColorConfig, opts Options, now time.Time) Stats {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	stats := Stats{
+		Highlights: len(entries),
+		Months:     []Period{},
+		Weeks:      weeks(entries, opts, now),
+		Colors:     colorCounts(entries, colors),
+		Streaks:    []Streak{},
+	}
+	if len(entries) == 0 {
+		stats.Sites, stats.Authors, stats.Labels = []Count{}, []Count{}, []Count{}
+		return stats
+	}
+
+	bookmarks := make(map[string]bool)
+	stats.First, stats.Last = entries[0].Created, entries[0].Created
+	for _, e := range entries {
+		bookmarks[e.BookmarkID] = true
+		if e.Created.Before(stats.First) {
+			stats.First = e.Created
+		}
+		if e.Created.After(stats.Last) {
+			stats.Last = e.Created
+		}
+	}
+	stats.Bookmarks = len(bookmarks)
+	stats.AveragePerBookmark = float64(stats.Highlights) / float64(stats.Bookmarks)
+
+	stats.Months = months(entries, stats.First, stats.Last, opts.Location)
+	stats.Sites = top(entries, opts.Top, func(e library.Entry) []string { return []string{site(e.URL)} })
+	stats.Authors = top(entries, opts.Top, func(e library.Entry) []string { return e.Authors })
+	stats.Labels = top(entries, opts.Top, func(e library.Entry) []string { return e.Labels })
+	stats.Streaks, stats.CurrentStreak = streaks(entries, opts, now)
+
+	return stats
+}
+
+func months(entries []library.Entry, first, last time.Time, loc *time.Location) []Period {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Created.In(loc).Format("2006-01")]++
+	}
+
+	var result []Period
+	first, last = first.In(loc), last.In(loc)
+	end := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, loc)
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, loc); !month.After(end); month = month.AddDate(0, 1, 0) {
+		name := month.Format("2006-01")
+		result = append(result, Period{Name: name, Start: month, Highlights: counts[name]})
+	}
+	return result
+}
+
+func weeks(entries []library.Entry, opts Options, now time.Time) []Period {
+	result := []Period{}
+	if opts.Weeks <= 0 {
+		return result
+	}
+
+	// Weeks start on monday, like ISO weeks
+	today := startOfDay(now, opts.Location)
+	current := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	start := current.AddDate(0, 0, -7*(opts.Weeks-1))
+
+	counts := make([]int, opts.Weeks)
+	for _, e := range entries {
+		created := e.Created.In(opts.Location)
+		if created.Before(start) {
+			continue
+		}
+		index := int(startOfDay(created, opts.Location).Sub(start).Round(day) / (7 * day))
+		if index < opts.Weeks {
+			counts[index]++
+		}
+	}
+
+	for i, count := range counts {
+		week := start.AddDate(0, 0, 7*i)
+		year, number := week.ISOWeek()
+		result = append(result, Period{Name: fmt.Sprintf("%d-W%02d", year, number), Start: week, Highlights: count})
+	}
+	return result
+}
+
+// colorCounts lists the colours in the order of the configuration, unknown colours last
+func colorCounts(entries []library.Entry, colors repository.ColorConfig) []Color {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Color]++
+	}
+
+	result := []Color{}
+	for _, color := range colors.ColorOrder {
+		if counts[color] > 0 {
+			result = append(result, Color{Color: color, Name: colorName(colors, color), Highlights: counts[color]})
+			delete(counts, color)
+		}
+	}
+
+	var others []string
+	for color := range counts {
+		others = append(others, color)
+	}
+	sort.Strings(others)
+	for _, color := range others {
+		result = append(result, Color{Color: color, Name: colorName(colors, color), Highlights: counts[color]})
+	}
+	return result
+}
+
+func colorName(colors repository.ColorConfig, color string) string {
+	if name, ok := colors.ColorNames[color]; ok {
+		return name
+	}
+	if color == "" {
+		return "No colour"
+	}
+	return color
+}
+
+func top(entries []library.Entry, n int, keys func(library.Entry) []string) []Count {
+	counts := make(map[string]*Count)
+	bookmarks := make(map[string]map[string]bool)
+
+	for _, e := range entries {
+		for _, key := range keys(e) {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if counts[key] == nil {
+				counts[key] = &Count{Name: key}
+				bookmarks[key] = make(map[string]bool)
+			}
+			counts[key].Highlights++
+			bookmarks[key][e.BookmarkID] = true
+		}
+	}
+
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		count.Bookmarks = len(bookmarks[key])
+		result = append(result, *count)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Highlights != result[j].Highlights {
+			return result[i].Highlights > result[j].Highlights
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// streaks finds the longest runs of days with highlights, and the run that is still going on.
+// A streak is still going on when the last highlight was made today or yesterday.
+func streaks(entries []library.Entry, opts Options, now time.Time) ([]Streak, Streak) {
+	days := make(map[time.Time]bool)
+	for _, e := range entries {
+		days[startOfDay(e.Created, opts.Location)] = true
+	}
+
+	sorted := make([]time.Time, 0, len(days))
+	for d := range days {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var all []Streak
+	for _, d := range sorted {
+		if len(all) > 0 && all[len(all)-1].End.AddDate(0, 0, 1).Equal(d) {
+			all[len(all)-1].End = d
+			all[len(all)-1].Days++
+			continue
+		}
+		all = append(all, Streak{Start: d, End: d, Days: 1})
+	}
+
+	var current Streak
+	if len(all) > 0 {
+		last := all[len(all)-1]
+		today := startOfDay(now, opts.Location)
+		if !last.End.Before(today.AddDate(0, 0, -1)) {
+			current = last
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Days > all[j].Days })
+	if opts.Streaks >= 0 && len(all) > opts.Streaks {
+		all = all[:opts.Streaks]
+	}
+	return all, current
+}
+
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func site(siteUrl string) string {
+	parsed, err := url.Parse(siteUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/library"
+	"github.com/mathieudr/readdeck-highlight-exporter/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(id, bookmark string, created time.Time, color string) library.Entry {
+	return library.Entry{HighlightID: id, BookmarkID: bookmark, Created: created, Color: color}
+}
+
+func TestCompute(t *testing.T) {
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	graham := func(e library.Entry) library.Entry {
+		e.Authors = []string{"Paul Graham"}
+		e.Labels = []string{"startups"}
+		e.URL = "https://www.paulgraham.com/schlep.html"
+		return e
+	}
+
+	entries := []library.Entry{
+		graham(entry("h1", "b1", at(1, 30), "yellow")),
+		graham(entry("h2", "b1", at(1, 31), "yellow")),
+		graham(entry("h3", "b1", at(3, 1), "green")),
+		entry("h4", "b2", at(3, 2), "purple"),
+		entry("h5", "b2", at(3, 3), "yellow"),
+		entry("h6", "b3", at(3, 10), "yellow"),
+		entry("h7", "b3", at(3, 11), ""),
+	}
+
+	opts := Options{Top: 5, Weeks: 3, Streaks: 2, Location: time.UTC}
+	stats := Compute(entries, repository.DefaultColorConfig(), opts, at(3, 12))
+
+	assert.Equal(t, 7, stats.Highlights)
+	assert.Equal(t, 3, stats.Bookmarks)
+	assert.InDelta(t, 7.0/3, stats.AveragePerBookmark, 0.001)
+	assert.Equal(t, at(1, 30), stats.First)
+	assert.Equal(t, at(3, 11), stats.Last)
+
+	var months []string
+	var monthCounts []int
+	for _, m := range stats.Months {
+		months = append(months, m.Name)
+		monthCounts = append(monthCounts, m.Highlights)
+	}
+	assert.Equal(t, []string{"2025-01", "2025-02", "2025-03"}, months)
+	assert.Equal(t, []int{2, 0, 5}, monthCounts)
+
+	// 12 March 2025 is a wednesday, in week 11
+	require.Len(t, stats.Weeks, 3)
+	assert.Equal(t, "2025-W09", stats.Weeks[0].Name)
+	assert.Equal(t, time.Date(2025, 2, 24, 0, 0, 0, 0, time.UTC), stats.Weeks[0].Start)
+	assert.Equal(t, []int{2, 1, 2}, []int{stats.Weeks[0].Highlights, stats.Weeks[1].Highlights, stats.Weeks[2].Highlights})
+
+	assert.Equal(t, []Color{
+		{Color: "green", Name: "Key takeaways", Highlights: 1},
+		{Color: "yellow", Name: "General highlights", Highlights: 4},
+		{Color: "", Name: "No colour", Highlights: 1},
+		{Color: "purple", Name: "purple", Highlights: 1},
+	}, stats.Colors)
+
+	assert.Equal(t, []Count{{Name: "paulgraham.com", Highlights: 3, Bookmarks: 1}}, stats.Sites)
+	assert.Equal(t, []Count{{Name: "Paul Graham", Highlights: 3, Bookmarks: 1}}, stats.Authors)
+	assert.Equal(t, []Count{{Name: "startups", Highlights: 3, Bookmarks: 1}}, stats.Labels)
+
+	assert.Equal(t, []Streak{
+		{Start: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Days: 3},
+		{Start: time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), Days: 2},
+	}, stats.Streaks)
+	assert.Equal(t, 2, stats.CurrentStreak.Days)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), stats.CurrentStreak.Start)
+
+	// The streak is broken once a day goes by without highlights
+	stats = Compute(entries, repository.DefaultColorConfig(), opts, at(3, 13))
+	assert.Equal(t, 0, stats.CurrentStreak.Days)
+}
+
+func TestCompute_Empty(t *testing.T) {
+	stats := Compute(nil, repository.DefaultColorConfig(), DefaultOptions(), time.Now())
+
+	assert.Equal(t, 0, stats.Highlights)
+	assert.Empty(t, stats.Months)
+	assert.Len(t, stats.Weeks, 12)
+	assert.NotNil(t, stats.Sites)
+	assert.Equal(t, 0, stats.CurrentStreak.Days)
+}
@@ -102,7 +102,7 @@ func TestGenerateFirstThreeNotes(t *testing.T) {
 	}
 
 	// Setup repository with absolute path
-	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig())
+	formatter := repository.NewHighlightFormatter(repository.DefaultColorConfig(), readdeck.OrderPosition)
 	parser := repository.NewYAMLNoteParser()
 	generator := repository.NewYAMLNoteGenerator(formatter, baseURL)
 	updater := repository.NewYAMLNoteUpdater(generator, parser)
@@ -2,6 +2,8 @@ package util
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -18,6 +20,36 @@ func GenerateId(title string, timestamp time.Time) string {
 
 var slugRegex = regexp.MustCompile("[^a-zA-Z0-9]+")
 
+// Slug turns the input into a lower case, dash separated file name
+func Slug(input string) string {
+	return sluggify(input)
+}
+
+// WriteFileAtomic replaces the file at path through a temporary file in the same directory,
+// so readers never see a half written file and a failed write keeps the previous one.
+// The directory must exist.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Temporary files are always private
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
 func sluggify(input string) string {
 	processedString := slugRegex.ReplaceAllString(input, " ")
 	processedString = strings.TrimSpace(processedString)
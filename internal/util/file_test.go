@@ -1,6 +1,8 @@
 package util_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -52,3 +54,25 @@ func TestGenerateId(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feed.xml")
+
+	assert.NoError(t, util.WriteFileAtomic(path, []byte("first"), 0644))
+	assert.NoError(t, util.WriteFileAtomic(path, []byte("second"), 0644))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// No temporary files are left behind, even when the write fails
+	assert.Error(t, util.WriteFileAtomic(filepath.Join(dir, "missing", "feed.xml"), []byte("third"), 0644))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Debouncer collects bookmark IDs and hands them over in a single batch once no new IDs
+// came in for the delay, or once the first ID waited for maxWait. Batches never overlap:
+// IDs that come in while a batch runs go to the next one.
+type Debouncer struct {
+	delay   time.Duration
+	maxWait time.Duration
+	flush   func(ids []string)
+
+	mu      sync.Mutex
+	pending map[string]bool
+	first   time.Time
+	timer   *time.Timer
+	closed  bool
+
+	running sync.Mutex
+}
+
+func NewDebouncer(delay, maxWait time.Duration, flush func(ids []string)) *Debouncer {
+	return &Debouncer{
+		delay:   delay,
+		maxWait: maxWait,
+		flush:   flush,
+		pending: make(map[string]bool),
+	}
+}
+
+func (d *Debouncer) Add(ids ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || len(ids) == 0 {
+		return
+	}
+
+	for _, id := range ids {
+		d.pending[id] = true
+	}
+	if d.first.IsZero() {
+		d.first = time.Now()
+	}
+
+	wait := d.delay
+	if d.maxWait > 0 {
+		wait = max(min(wait, d.maxWait-time.Since(d.first)), 0)
+	}
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(wait, d.fire)
+}
+
+// Pending is the number of IDs waiting for the next batch
+func (d *Debouncer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// Close stops the debouncer and waits for the batch that is running.
+// It returns the number of IDs that were still waiting, they are dropped.
+func (d *Debouncer) Close() int {
+	d.mu.Lock()
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	dropped := len(d.pending)
+	d.mu.Unlock()
+
+	d.running.Lock()
+	defer d.running.Unlock()
+	return dropped
+}
+
+func (d *Debouncer) fire() {
+	d.running.Lock()
+	defer d.running.Unlock()
+
+	d.mu.Lock()
+	if d.closed || len(d.pending) == 0 {
+		d.mu.Unlock()
+		return
+	}
+
+	ids := make([]string, 0, len(d.pending))
+	for id := range d.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	d.pending = make(map[string]bool)
+	d.first = time.Time{}
+	d.mu.Unlock()
+
+	d.flush(ids)
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxPayloadSize = 1 << 20
+
+// ExportFunc exports the highlights of the given bookmarks
+type ExportFunc func(ctx context.Context, bookmarkIDs []string) error
+
+type Options struct {
+	// Secret has to be sent along with every notification, in the X-Webhook-Secret
+	// header or as a bearer token
+	Secret string
+	// Debounce is the quiet time after the last notification before exporting
+	Debounce time.Duration
+	// MaxWait caps the wait when notifications keep coming in
+	MaxWait time.Duration
+}
+
+// Payload is a notification about changed bookmarks
+type Payload struct {
+	Event       string   `json:"event"`
+	BookmarkID  string   `json:"bookmark_id"`
+	BookmarkIDs []string `json:"bookmark_ids"`
+}
+
+func (p Payload) IDs() []string {
+	var ids []string
+	for _, id := range append([]string{p.BookmarkID}, p.BookmarkIDs...) {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+type Status struct {
+	Status     string     `json:"status"`
+	Pending    int        `json:"pending"`
+	Exports    int        `json:"exports"`
+	LastExport *time.Time `json:"last_export,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Server receives webhook notifications and exports the bookmarks they are about,
+// in batches, with the export function
+type Server struct {
+	secret    string
+	debouncer *Debouncer
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewServer creates a server whose exports run with ctx, cancelling it stops them
+func NewServer(ctx context.Context, opts Options, export ExportFunc) *Server {
+	server := &Server{secret: opts.Secret, status: Status{Status: "ok"}}
+	server.debouncer = NewDebouncer(opts.Debounce, opts.MaxWait, func(ids []string) {
+		err := export(ctx, ids)
+		server.recordExport(err)
+	})
+	return server
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", s.handleWebhook)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	return mux
+}
+
+// Close stops accepting notifications and waits for the running export.
+// It returns the number of bookmarks that were waiting to be exported.
+func (s *Server) Close() int {
+	return s.debouncer.Close()
+}
+
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.Pending = s.debouncer.Pending()
+	return status
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	var payload Payload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPayloadSize)).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := payload.IDs()
+	if len(ids) == 0 {
+		http.Error(w, "no bookmark_id or bookmark_ids in the payload", http.StatusBadRequest)
+		return
+	}
+
+	s.debouncer.Add(ids...)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status())
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	given := r.Header.Get("X-Webhook-Secret")
+	if given == "" {
+		given, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return s.secret != "" && subtle.ConstantTimeCompare([]byte(given), []byte(s.secret)) == 1
+}
+
+func (s *Server) recordExport(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Exports++
+	now := time.Now().UTC()
+	s.status.LastExport = &now
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is the export function of the tests, it keeps the batches it was called with
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *recorder) export(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, ids)
+	return r.err
+}
+
+func (r *recorder) Batches() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string{}, r.batches...)
+}
+
+// send posts a notification like Readdeck would
+func send(t *testing.T, url, secret, body string) int {
+	request, err := http.NewRequest("POST", url+"/webhook", strings.NewReader(body))
+	require.NoError(t, err)
+	if secret != "" {
+		request.Header.Set("X-Webhook-Secret", secret)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func TestServer_Webhook(t *testing.T) {
+	exports := &recorder{}
+	server := NewServer(context.Background(), Options{Secret: "s3cret", Debounce: 50 * time.Millisecond}, exports.export)
+	sender := httptest.NewServer(server.Handler())
+	defer sender.Close()
+
+	tests := []struct {
+		name     string
+		secret   string
+		body     string
+		expected int
+	}{
+		{name: "missing secret", body: `{"bookmark_id": "b1"}`, expected: http.StatusUnauthorized},
+		{name: "wrong secret", secret: "guess", body: `{"bookmark_id": "b1"}`, expected: http.StatusUnauthorized},
+		{name: "invalid payload", secret: "s3cret", body: `{"bookmark_id":`, expected: http.StatusBadRequest},
+		{name: "no bookmarks", secret: "s3cret", body: `{"event": "bookmark.updated"}`, expected: http.StatusBadRequest},
+		{name: "bookmark", secret: "s3cret", body: `{"event": "annotation.created", "bookmark_id": "b2"}`, expected: http.StatusAccepted},
+		{name: "bookmarks", secret: "s3cret", body: `{"bookmark_ids": ["b1", "b2"]}`, expected: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, send(t, sender.URL, tt.secret, tt.body))
+		})
+	}
+
+	// The burst is exported once, with every bookmark it was about
+	assert.Eventually(t, func() bool { return len(exports.Batches()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, [][]string{{"b1", "b2"}}, exports.Batches())
+	assert.Equal(t, 0, server.Close())
+}
+
+func TestServer_BearerToken(t *testing.T) {
+	server := NewServer(context.Background(), Options{Secret: "s3cret", Debounce: time.Hour}, (&recorder{}).export)
+
+	request := httptest.NewRequest("POST", "/webhook", strings.NewReader(`{"bookmark_id": "b1"}`))
+	request.Header.Set("Authorization", "Bearer s3cret")
+	response := httptest.NewRecorder()
+	server.Handler().ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Equal(t, 1, server.Close(), "the pending bookmark is dropped")
+}
+
+func TestServer_Health(t *testing.T) {
+	exports := &recorder{err: errors.New("readdeck is down")}
+	server := NewServer(context.Background(), Options{Secret: "s3cret", Debounce: 10 * time.Millisecond}, exports.export)
+	sender := httptest.NewServer(server.Handler())
+	defer sender.Close()
+
+	// Before the first export there is no time to report
+	before, err := http.Get(sender.URL + "/health")
+	require.NoError(t, err)
+	body, err := io.ReadAll(before.Body)
+	before.Body.Close()
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "last_export")
+
+	require.Equal(t, http.StatusAccepted, send(t, sender.URL, "s3cret", `{"bookmark_id": "b1"}`))
+	assert.Eventually(t, func() bool { return server.Status().Exports == 1 }, time.Second, 10*time.Millisecond)
+
+	response, err := http.Get(sender.URL + "/health")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	var status Status
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "ok", status.Status)
+	assert.Equal(t, 1, status.Exports)
+	assert.Equal(t, "readdeck is down", status.LastError)
+	require.NotNil(t, status.LastExport)
+	assert.False(t, status.LastExport.IsZero())
+}
+
+func TestDebouncer_MaxWait(t *testing.T) {
+	exports := &recorder{}
+	debouncer := NewDebouncer(time.Hour, 30*time.Millisecond, func(ids []string) { exports.export(context.Background(), ids) })
+
+	// Notifications that keep coming in don't hold the export back longer than maxWait
+	debouncer.Add("b1")
+	debouncer.Add("b2")
+	assert.Eventually(t, func() bool { return len(exports.Batches()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"b1", "b2"}}, exports.Batches())
+
+	// A new burst starts a new wait
+	debouncer.Add("b3")
+	assert.Eventually(t, func() bool { return len(exports.Batches()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, debouncer.Close())
+}